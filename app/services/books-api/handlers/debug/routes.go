@@ -0,0 +1,21 @@
+package debug
+
+import (
+	"net/http"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	"go.uber.org/zap"
+)
+
+type RoutesHandler struct {
+	Logger *zap.SugaredLogger
+	Routes []web.Route
+}
+
+// List returns all the routes served by the api, together with their middleware.
+func (h RoutesHandler) List(w http.ResponseWriter, _ *http.Request) {
+	err := response(w, http.StatusOK, h.Routes)
+	if err != nil {
+		h.Logger.Errorw("routes", "ERROR", err)
+	}
+}
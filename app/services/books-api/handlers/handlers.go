@@ -11,9 +11,14 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tchorzewski1991/bds/app/services/books-api/handlers/debug"
+	"github.com/tchorzewski1991/bds/app/services/books-api/handlers/opds"
 	v1 "github.com/tchorzewski1991/bds/app/services/books-api/handlers/v1"
 	v2 "github.com/tchorzewski1991/bds/app/services/books-api/handlers/v2"
+	"github.com/tchorzewski1991/bds/app/services/books-api/handlers/wellknown"
 	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/blob"
+	"github.com/tchorzewski1991/bds/business/sys/oidc"
 	"github.com/tchorzewski1991/bds/business/web/v1/mid"
 	"go.uber.org/zap"
 )
@@ -22,6 +27,8 @@ type DebugMuxConfig struct {
 	Build  string
 	Logger *zap.SugaredLogger
 	DB     *sqlx.DB
+	// Routes are listed by the routes endpoint, as registered by the api.
+	Routes []web.Route
 }
 
 func DebugMux(cfg DebugMuxConfig) http.Handler {
@@ -45,6 +52,10 @@ func DebugMux(cfg DebugMuxConfig) http.Handler {
 	mux.HandleFunc("/debug/readiness", ch.Readiness)
 	mux.HandleFunc("/debug/liveness", ch.Liveness)
 
+	// Setup route introspection.
+	rh := debug.RoutesHandler{Logger: cfg.Logger, Routes: cfg.Routes}
+	mux.HandleFunc("/debug/routes", rh.List)
+
 	return mux
 }
 
@@ -52,24 +63,37 @@ type ApiMuxConfig struct {
 	Shutdown chan os.Signal
 	Logger   *zap.SugaredLogger
 	DB       *sqlx.DB
+	Blobs    blob.Store
+	Auth     *auth.Auth
+	OIDC     *oidc.Provider
 }
 
-func ApiMux(cfg ApiMuxConfig) http.Handler {
+func ApiMux(cfg ApiMuxConfig) *web.App {
 
 	// Initialize new web app with all necessary dependencies.
 	app := web.NewApp(
 		cfg.Shutdown,
+		cfg.Logger,
 		mid.Metrics(),
 		mid.Logger(cfg.Logger),
 		mid.Errors(cfg.Logger),
 		mid.Panics(),
 	)
 
+	// JSON stays the default, other formats are sent only when asked for.
+	app.RegisterEncoder(web.XML{}, web.CSV{}, web.MessagePack{})
+
 	// Setup v1 routes.
-	v1.Routes(app, v1.Config{Logger: cfg.Logger, DB: cfg.DB})
+	v1.Routes(app, v1.Config{Logger: cfg.Logger, DB: cfg.DB, Blobs: cfg.Blobs, Auth: cfg.Auth, OIDC: cfg.OIDC})
 
 	// Setup v2 routes.
 	v2.Routes(app, v2.Config{Logger: cfg.Logger})
 
+	// Setup OPDS catalog routes.
+	opds.Routes(app, opds.Config{Logger: cfg.Logger, DB: cfg.DB, Blobs: cfg.Blobs})
+
+	// Setup well-known routes.
+	wellknown.Routes(app, wellknown.Config{Auth: cfg.Auth})
+
 	return app
 }
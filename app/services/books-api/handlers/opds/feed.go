@@ -0,0 +1,105 @@
+package opds
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Media types defined by the OPDS 1.2 specification.
+const (
+	navigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	acquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	openSearchType  = "application/opensearchdescription+xml"
+)
+
+// Link relations defined by Atom, OPDS and OpenSearch.
+const (
+	relSelf      = "self"
+	relStart     = "start"
+	relUp        = "up"
+	relNext      = "next"
+	relPrevious  = "previous"
+	relFirst     = "first"
+	relSearch    = "search"
+	relAlternate = "alternate"
+	relSubsect   = "subsection"
+	relNew       = "http://opds-spec.org/sort/new"
+	relImage     = "http://opds-spec.org/image"
+	relThumbnail = "http://opds-spec.org/image/thumbnail"
+
+	// Files are downloaded without authentication, hence open access.
+	relAcquisition = "http://opds-spec.org/acquisition/open-access"
+)
+
+// encoding/xml doesn't support namespace prefixes, so they are kept in local names.
+type feed struct {
+	XMLName      xml.Name  `xml:"feed"`
+	Xmlns        string    `xml:"xmlns,attr"`
+	XmlnsDC      string    `xml:"xmlns:dc,attr"`
+	XmlnsOPDS    string    `xml:"xmlns:opds,attr"`
+	XmlnsSearch  string    `xml:"xmlns:opensearch,attr"`
+	XmlnsThr     string    `xml:"xmlns:thr,attr"`
+	ID           string    `xml:"id"`
+	Title        string    `xml:"title"`
+	Updated      time.Time `xml:"updated"`
+	Author       author    `xml:"author"`
+	ItemsPerPage int       `xml:"opensearch:itemsPerPage,omitempty"`
+	StartIndex   int       `xml:"opensearch:startIndex,omitempty"`
+	Links        []link    `xml:"link"`
+	Entries      []entry   `xml:"entry"`
+}
+
+type author struct {
+	Name string `xml:"name"`
+}
+
+type link struct {
+	Rel   string `xml:"rel,attr,omitempty"`
+	Href  string `xml:"href,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Title string `xml:"title,attr,omitempty"`
+	Count int    `xml:"thr:count,attr,omitempty"`
+}
+
+type content struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type entry struct {
+	ID         string    `xml:"id"`
+	Title      string    `xml:"title"`
+	Updated    time.Time `xml:"updated"`
+	Authors    []author  `xml:"author,omitempty"`
+	Publisher  string    `xml:"dc:publisher,omitempty"`
+	Issued     string    `xml:"dc:issued,omitempty"`
+	Identifier string    `xml:"dc:identifier,omitempty"`
+	Content    *content  `xml:"content,omitempty"`
+	Links      []link    `xml:"link"`
+}
+
+func newFeed(id, title string, now time.Time) feed {
+	return feed{
+		Xmlns:       "http://www.w3.org/2005/Atom",
+		XmlnsDC:     "http://purl.org/dc/terms/",
+		XmlnsOPDS:   "http://opds-spec.org/2010/catalog",
+		XmlnsSearch: "http://a9.com/-/spec/opensearch/1.1/",
+		XmlnsThr:    "http://purl.org/syndication/thread/1.0",
+		ID:          id,
+		Title:       title,
+		Updated:     now,
+		Author:      author{Name: "bds"},
+	}
+}
+
+type openSearchDescription struct {
+	XMLName     xml.Name `xml:"OpenSearchDescription"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	ShortName   string   `xml:"ShortName"`
+	Description string   `xml:"Description"`
+	Encoding    string   `xml:"InputEncoding"`
+	URL         struct {
+		Type     string `xml:"type,attr"`
+		Template string `xml:"template,attr"`
+	} `xml:"Url"`
+}
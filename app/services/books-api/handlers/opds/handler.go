@@ -0,0 +1,336 @@
+package opds
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/bookfile"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+// rowsPerPage is the number of entries in a single page of the feed.
+const rowsPerPage = 20
+
+type handler struct {
+	book book.Core
+	file bookfile.Core
+}
+
+// Root returns the navigation feed being the entry point of the catalog.
+func (h handler) Root(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	now := time.Now().UTC()
+
+	f := newFeed("urn:bds:opds:root", "Books Data System", now)
+	f.Links = append(commonLinks(), link{Rel: relSelf, Href: prefix + "/", Type: navigationType})
+
+	f.Entries = []entry{
+		navigationEntry("urn:bds:opds:recent", "Recently added", "The most recently added books.", prefix+"/recent", acquisitionType, now, relNew),
+		navigationEntry("urn:bds:opds:authors", "By author", "Browse books by author.", prefix+"/authors", navigationType, now, relSubsect),
+		navigationEntry("urn:bds:opds:publishers", "By publisher", "Browse books by publisher.", prefix+"/publishers", navigationType, now, relSubsect),
+	}
+
+	return response(ctx, w, navigationType, f)
+}
+
+// Recent returns the acquisition feed of the most recently added books.
+func (h handler) Recent(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page, err := pageParam(r)
+	if err != nil {
+		return err
+	}
+
+	books, err := h.book.QueryRecent(ctx, page, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query recent books: %w", err)
+	}
+
+	f, err := h.acquisitionFeed(ctx, "urn:bds:opds:recent", "Recently added", prefix+"/recent", url.Values{}, page, books)
+	if err != nil {
+		return err
+	}
+
+	return response(ctx, w, acquisitionType, f)
+}
+
+// Search returns the acquisition feed of books matching the searchTerms.
+func (h handler) Search(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	page, err := pageParam(r)
+	if err != nil {
+		return err
+	}
+
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return v1.NewRequestError(errors.New("q param can't be blank"), http.StatusBadRequest)
+	}
+
+	books, err := h.book.Query(ctx, book.QueryFilter{Search: q}, page, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to search books: %w", err)
+	}
+
+	f, err := h.acquisitionFeed(ctx, "urn:bds:opds:search:"+url.QueryEscape(q), "Search: "+q, prefix+"/search", url.Values{"q": {q}}, page, books)
+	if err != nil {
+		return err
+	}
+
+	return response(ctx, w, acquisitionType, f)
+}
+
+// OpenSearch returns the OpenSearch description document pointing at the Search feed.
+func (h handler) OpenSearch(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	d := openSearchDescription{
+		Xmlns:       "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:   "bds",
+		Description: "Search books by title, author or ISBN.",
+		Encoding:    "UTF-8",
+	}
+	d.URL.Type = acquisitionType
+	d.URL.Template = prefix + "/search?q={searchTerms}"
+
+	return response(ctx, w, openSearchType, d)
+}
+
+// Authors returns the navigation feed of authors.
+func (h handler) Authors(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.facets(ctx, w, r, "authors", "Authors", h.book.QueryAuthors)
+}
+
+// Publishers returns the navigation feed of publishers.
+func (h handler) Publishers(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.facets(ctx, w, r, "publishers", "Publishers", h.book.QueryPublishers)
+}
+
+// Author returns the acquisition feed of books written by the author. The name is matched
+// exactly, so the feed holds the books counted by the Authors feed.
+func (h handler) Author(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.facet(ctx, w, r, "authors", func(name string) book.QueryFilter {
+		return book.QueryFilter{ExactAuthor: name}
+	})
+}
+
+// Publisher returns the acquisition feed of books released by the publisher. The name is
+// matched exactly, so the feed holds the books counted by the Publishers feed.
+func (h handler) Publisher(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return h.facet(ctx, w, r, "publishers", func(name string) book.QueryFilter {
+		return book.QueryFilter{ExactPublisher: name}
+	})
+}
+
+// private
+
+type facetQuery func(ctx context.Context, page int, rowsPerPage int) ([]book.Facet, error)
+
+func (h handler) facets(ctx context.Context, w http.ResponseWriter, r *http.Request, kind, title string, query facetQuery) error {
+	page, err := pageParam(r)
+	if err != nil {
+		return err
+	}
+
+	facets, err := query(ctx, page, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query %s: %w", kind, err)
+	}
+
+	now := time.Now().UTC()
+	path := prefix + "/" + kind
+
+	f := newFeed("urn:bds:opds:"+kind, title, now)
+	f.Links = append(commonLinks(), link{Rel: relSelf, Href: pageURL(path, url.Values{}, page), Type: navigationType})
+	f.Links = append(f.Links, pagination(path, url.Values{}, page, len(facets), navigationType)...)
+
+	for _, facet := range facets {
+		e := navigationEntry(
+			"urn:bds:opds:"+kind+":"+url.PathEscape(facet.Name),
+			facet.Name,
+			fmt.Sprintf("%d books", facet.Count),
+			path+"/"+url.PathEscape(facet.Name),
+			acquisitionType,
+			now,
+			relSubsect,
+		)
+		e.Links[0].Count = facet.Count
+		f.Entries = append(f.Entries, e)
+	}
+
+	return response(ctx, w, navigationType, f)
+}
+
+func (h handler) facet(ctx context.Context, w http.ResponseWriter, r *http.Request, kind string, filter func(string) book.QueryFilter) error {
+	page, err := pageParam(r)
+	if err != nil {
+		return err
+	}
+
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+	name := params["name"]
+
+	books, err := h.book.Query(ctx, filter(name), page, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query books: %w", err)
+	}
+
+	path := prefix + "/" + kind + "/" + url.PathEscape(name)
+	f, err := h.acquisitionFeed(ctx, "urn:bds:opds:"+kind+":"+url.PathEscape(name), name, path, url.Values{}, page, books)
+	if err != nil {
+		return err
+	}
+	f.Links = append(f.Links, link{Rel: relUp, Href: prefix + "/" + kind, Type: navigationType})
+
+	return response(ctx, w, acquisitionType, f)
+}
+
+// acquisitionFeed lists the books together with links to their files.
+func (h handler) acquisitionFeed(ctx context.Context, id, title, path string, query url.Values, page int, books []book.Book) (feed, error) {
+	ids := make([]int, len(books))
+	for i, b := range books {
+		ids[i] = b.ID
+	}
+
+	files, err := h.file.QueryByBookIDs(ctx, ids)
+	if err != nil {
+		return feed{}, fmt.Errorf("unable to query files: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	f := newFeed(id, title, now)
+	f.ItemsPerPage = rowsPerPage
+	f.StartIndex = (page-1)*rowsPerPage + 1
+	f.Links = append(commonLinks(), link{Rel: relSelf, Href: pageURL(path, query, page), Type: acquisitionType})
+	f.Links = append(f.Links, pagination(path, query, page, len(books), acquisitionType)...)
+
+	for _, b := range books {
+		f.Entries = append(f.Entries, bookEntry(b, files[b.ID], now))
+	}
+
+	return f, nil
+}
+
+// bookEntry describes the book. Its EPUB files are linked for acquisition, the extracted
+// cover takes precedence over the cover URL.
+func bookEntry(b book.Book, files []bookfile.File, now time.Time) entry {
+	e := entry{
+		ID:         "urn:bds:book:" + strconv.Itoa(b.ID),
+		Title:      b.Title,
+		Updated:    now,
+		Identifier: "urn:isbn:" + b.Isbn,
+		Links: []link{
+			{Rel: relAlternate, Href: "/v1/books/" + strconv.Itoa(b.ID), Type: "application/json"},
+		},
+	}
+
+	if b.Author != nil && *b.Author != "" {
+		e.Authors = []author{{Name: *b.Author}}
+	}
+	if b.Publisher != nil {
+		e.Publisher = *b.Publisher
+	}
+	if b.PublicationYear != nil {
+		e.Issued = *b.PublicationYear
+	}
+	var cover bool
+	for _, f := range files {
+		href := "/v1/books/" + strconv.Itoa(b.ID) + "/files/" + f.ID
+
+		switch f.Kind {
+		case bookfile.KindEPUB:
+			e.Links = append(e.Links, link{Rel: relAcquisition, Href: href, Type: f.MediaType})
+		case bookfile.KindCover:
+			if !cover {
+				cover = true
+				e.Links = append(e.Links,
+					link{Rel: relImage, Href: href, Type: f.MediaType},
+					link{Rel: relThumbnail, Href: href, Type: f.MediaType},
+				)
+			}
+		}
+	}
+
+	if !cover && b.CoverURL != nil && *b.CoverURL != "" {
+		e.Links = append(e.Links,
+			link{Rel: relImage, Href: *b.CoverURL, Type: "image/jpeg"},
+			link{Rel: relThumbnail, Href: *b.CoverURL, Type: "image/jpeg"},
+		)
+	}
+
+	return e
+}
+
+func navigationEntry(id, title, text, href, typ string, now time.Time, rel string) entry {
+	return entry{
+		ID:      id,
+		Title:   title,
+		Updated: now,
+		Content: &content{Type: "text", Value: text},
+		Links:   []link{{Rel: rel, Href: href, Type: typ}},
+	}
+}
+
+func commonLinks() []link {
+	return []link{
+		{Rel: relStart, Href: prefix + "/", Type: navigationType},
+		{Rel: relSearch, Href: prefix + "/opensearch.xml", Type: openSearchType},
+	}
+}
+
+// pagination returns links to the neighbouring pages. The next page is assumed
+// to exist whenever the current one is full.
+func pagination(path string, query url.Values, page, count int, typ string) []link {
+	links := []link{{Rel: relFirst, Href: pageURL(path, query, 1), Type: typ}}
+
+	if page > 1 {
+		links = append(links, link{Rel: relPrevious, Href: pageURL(path, query, page-1), Type: typ})
+	}
+	if count == rowsPerPage {
+		links = append(links, link{Rel: relNext, Href: pageURL(path, query, page+1), Type: typ})
+	}
+
+	return links
+}
+
+func pageURL(path string, query url.Values, page int) string {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+func pageParam(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("page")
+	if v == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.Atoi(v)
+	if err != nil || page < 1 {
+		return 0, v1.NewRequestError(fmt.Errorf("page param is not valid: %s", v), http.StatusBadRequest)
+	}
+
+	return page, nil
+}
+
+func response(ctx context.Context, w http.ResponseWriter, contentType string, data any) error {
+	out, err := xml.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling feed: %w", err)
+	}
+
+	return web.RawResponse(ctx, w, http.StatusOK, contentType, append([]byte(xml.Header), out...))
+}
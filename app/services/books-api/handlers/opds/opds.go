@@ -0,0 +1,39 @@
+// Package opds provides the OPDS 1.2 catalog of books for e-reader apps.
+package opds
+
+import (
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/bookfile"
+	"github.com/tchorzewski1991/bds/business/sys/blob"
+	"go.uber.org/zap"
+)
+
+const prefix = "/opds"
+
+type Config struct {
+	Logger *zap.SugaredLogger
+	DB     *sqlx.DB
+	Blobs  blob.Store
+}
+
+// Routes binds all the routes of the OPDS catalog.
+func Routes(app *web.App, cfg Config) {
+	h := handler{
+		book: book.NewCore(cfg.DB, cfg.Logger),
+		file: bookfile.NewCore(cfg.DB, cfg.Logger, cfg.Blobs),
+	}
+	g := app.Group(prefix)
+
+	g.Handle(http.MethodGet, "/", h.Root)
+	g.Handle(http.MethodGet, "/recent", h.Recent)
+	g.Handle(http.MethodGet, "/search", h.Search)
+	g.Handle(http.MethodGet, "/opensearch.xml", h.OpenSearch)
+	g.Handle(http.MethodGet, "/authors", h.Authors)
+	g.Handle(http.MethodGet, "/authors/:name", h.Author)
+	g.Handle(http.MethodGet, "/publishers", h.Publishers)
+	g.Handle(http.MethodGet, "/publishers/:name", h.Publisher)
+}
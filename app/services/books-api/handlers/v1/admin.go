@@ -0,0 +1,278 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/pkg/errors"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/role"
+	"github.com/tchorzewski1991/bds/business/core/user"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+type adminHandler struct {
+	user user.Core
+	role role.Core
+}
+
+// adminUser is the representation of the user visible to admins.
+type adminUser struct {
+	UUID          string     `json:"uuid"`
+	Email         string     `json:"email"`
+	Permissions   []string   `json:"permissions"`
+	Roles         []string   `json:"roles"`
+	Branch        string     `json:"branch"`
+	VerifiedAt    *time.Time `json:"verified_at"`
+	DisabledAt    *time.Time `json:"disabled_at"`
+	TOTPEnabledAt *time.Time `json:"totp_enabled_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// usersQuery holds the query params of the user listing. Search matches the email.
+type usersQuery struct {
+	Page   int    `query:"page"`
+	Rows   int    `query:"rows"`
+	Search string `query:"search"`
+}
+
+type usersPage struct {
+	Page  int         `json:"page"`
+	Rows  int         `json:"rows"`
+	Users []adminUser `json:"users"`
+}
+
+// List lets CSV encode the users alone, without paging.
+func (p usersPage) List() any {
+	return p.Users
+}
+
+func (h adminHandler) QueryUsers(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var q usersQuery
+	err := web.DecodeQuery(r, &q)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	page, rowsPerPage := paging(q.Page, q.Rows)
+
+	users, err := h.user.Query(ctx, q.Search, page, rowsPerPage)
+	if err != nil {
+		return fmt.Errorf("unable to query users: %w", err)
+	}
+
+	result := make([]adminUser, len(users))
+	for i, u := range users {
+		result[i] = toAdminUser(u)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, usersPage{
+		Page:  page,
+		Rows:  rowsPerPage,
+		Users: result,
+	})
+}
+
+func (h adminHandler) QueryUserByUUID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.QueryByUUID(ctx, params["uuid"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) UpdateUser(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	var uu user.UpdateUser
+	err := web.Decode(r, &uu)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	usr, err := h.user.Update(ctx, params["uuid"], uu)
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) DisableUser(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.Disable(ctx, params["uuid"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) EnableUser(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.Enable(ctx, params["uuid"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) GrantPermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.GrantPermission(ctx, params["uuid"], params["permission"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) RevokePermission(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.RevokePermission(ctx, params["uuid"], params["permission"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) AssignRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.AssignRole(ctx, params["uuid"], params["role"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) UnassignRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.UnassignRole(ctx, params["uuid"], params["role"])
+	if err != nil {
+		return userError(err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+func (h adminHandler) QueryRoles(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	roles, err := h.role.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query roles: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, roles)
+}
+
+func (h adminHandler) QueryRoleByName(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	rl, err := h.role.QueryByName(ctx, params["name"])
+	if err != nil {
+		if errors.Is(err, role.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web.Response(ctx, w, http.StatusOK, rl)
+}
+
+// SaveRole creates the role or replaces the existing one.
+func (h adminHandler) SaveRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	var nr role.NewRole
+	err := web.Decode(r, &nr)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	rl, err := h.role.Save(ctx, params["name"], nr)
+	if err != nil {
+		var fieldErr role.FieldError
+		if errors.As(err, &fieldErr) {
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		}
+		return err
+	}
+
+	return web.Response(ctx, w, http.StatusOK, rl)
+}
+
+func (h adminHandler) DeleteRole(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	err := h.role.Delete(ctx, params["name"])
+	if err != nil {
+		if errors.Is(err, role.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web.Response(ctx, w, http.StatusNoContent, nil)
+}
+
+// private
+
+// userError translates errors of the user core into request errors.
+func userError(err error) error {
+	var fieldErr user.FieldError
+	switch {
+	case errors.As(err, &fieldErr):
+		return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+	case errors.Is(err, user.ErrInvalidUUID):
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	case errors.Is(err, user.ErrNotFound), errors.Is(err, user.ErrRoleNotFound):
+		return v1.NewRequestError(err, http.StatusNotFound)
+	case errors.Is(err, user.ErrEmailTaken):
+		return v1.NewRequestError(user.ErrEmailTaken, http.StatusConflict)
+	default:
+		return err
+	}
+}
+
+func toAdminUser(u user.User) adminUser {
+	au := adminUser{
+		UUID:        u.UUID,
+		Email:       u.Email,
+		Permissions: u.Permissions,
+		Roles:       u.Roles,
+		Branch:      u.Branch,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+	}
+	if au.Permissions == nil {
+		au.Permissions = []string{}
+	}
+	if au.Roles == nil {
+		au.Roles = []string{}
+	}
+	if !u.VerifiedAt.IsZero() {
+		au.VerifiedAt = &u.VerifiedAt
+	}
+	if u.Disabled() {
+		au.DisabledAt = &u.DisabledAt
+	}
+	if !u.TOTPEnabledAt.IsZero() {
+		au.TOTPEnabledAt = &u.TOTPEnabledAt
+	}
+	return au
+}
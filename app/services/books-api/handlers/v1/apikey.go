@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/pkg/errors"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/apikey"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+type apiKeyHandler struct {
+	apikey apikey.Core
+}
+
+// createdKey is the only representation of the key holding the secret.
+type createdKey struct {
+	apikey.Key
+	Secret string `json:"key"`
+}
+
+// Create returns the new key only once, it can't be retrieved later.
+func (h apiKeyHandler) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+
+	var nk apikey.NewKey
+	err = web.Decode(r, &nk)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	// Keys created with other keys are not attributed to any user.
+	var createdBy string
+	if claims.Kind == auth.KindAccess {
+		createdBy = claims.Subject
+	}
+
+	key, raw, err := h.apikey.Create(ctx, nk, createdBy)
+	if err != nil {
+		var fieldErr apikey.FieldError
+		if errors.As(err, &fieldErr) {
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		}
+		return fmt.Errorf("create api key err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusCreated, createdKey{
+		Key:    key,
+		Secret: raw,
+	})
+}
+
+func (h apiKeyHandler) Query(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	keys, err := h.apikey.Query(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to query api keys: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, keys)
+}
+
+func (h apiKeyHandler) QueryByID(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	key, err := h.apikey.QueryByID(ctx, params["id"])
+	if err != nil {
+		if errors.Is(err, apikey.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web.Response(ctx, w, http.StatusOK, key)
+}
+
+func (h apiKeyHandler) Revoke(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	key, err := h.apikey.Revoke(ctx, params["id"])
+	if err != nil {
+		if errors.Is(err, apikey.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	return web.Response(ctx, w, http.StatusOK, key)
+}
@@ -1,15 +1,21 @@
 package v1
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/dimfeld/httptreemux/v5"
 	"github.com/pkg/errors"
+	"github.com/tchorzewski1991/bds/base/barcode"
+	"github.com/tchorzewski1991/bds/base/isbn"
 	"github.com/tchorzewski1991/bds/base/web"
 	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/book/formats"
 	v1 "github.com/tchorzewski1991/bds/business/web/v1"
 )
 
@@ -18,42 +24,29 @@ type bookHandler struct {
 }
 
 func (h bookHandler) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
-	query := r.URL.Query()
-
-	var err error
-	var page int
-	var rowsPerPage int
-
-	if v := query.Get("page"); v != "" {
-		page, err = strconv.Atoi(v)
-		if err != nil {
-			return v1.NewRequestError(fmt.Errorf("page param is not valid: %w", err), http.StatusBadRequest)
-		}
-	}
-	if page < 1 {
-		page = 1
+	var q bookQuery
+	err := web.DecodeQuery(r, &q)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
 	}
 
-	if v := query.Get("rows"); v != "" {
-		rowsPerPage, err = strconv.Atoi(v)
-		if err != nil {
-			return v1.NewRequestError(fmt.Errorf("rows param is not valid: %w", err), http.StatusBadRequest)
-		}
-	}
-	if rowsPerPage < 1 || rowsPerPage > 20 {
-		rowsPerPage = 20
+	page, rowsPerPage := paging(q.Page, q.Rows)
+
+	enc, err := negotiateFormat(r, web.MediaTypes(ctx))
+	if err != nil {
+		return err
 	}
 
-	books, err := h.book.Query(ctx, page, rowsPerPage)
+	books, err := h.book.Query(ctx, q.filter(), page, rowsPerPage)
 	if err != nil {
 		return fmt.Errorf("unable to query books: %w", err)
 	}
 
-	return web.Response(ctx, w, http.StatusOK, struct {
-		Page  int         `json:"page"`
-		Rows  int         `json:"rows"`
-		Books []book.Book `json:"books"`
-	}{
+	if enc != nil {
+		return encodeBooks(ctx, w, enc, books...)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, booksPage{
 		Page:  page,
 		Rows:  rowsPerPage,
 		Books: books,
@@ -68,6 +61,11 @@ func (h bookHandler) QueryByID(ctx context.Context, w http.ResponseWriter, r *ht
 		return v1.NewRequestError(fmt.Errorf("id param is not valid: %w", err), http.StatusBadRequest)
 	}
 
+	enc, err := negotiateFormat(r, web.MediaTypes(ctx))
+	if err != nil {
+		return err
+	}
+
 	b, err := h.book.QueryByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, book.ErrNotFound) {
@@ -76,6 +74,10 @@ func (h bookHandler) QueryByID(ctx context.Context, w http.ResponseWriter, r *ht
 		return err
 	}
 
+	if enc != nil {
+		return encodeBooks(ctx, w, enc, b)
+	}
+
 	return web.Response(ctx, w, http.StatusOK, b)
 }
 
@@ -88,10 +90,6 @@ func (h bookHandler) Create(ctx context.Context, w http.ResponseWriter, r *http.
 
 	b, err := h.book.Create(ctx, nb)
 	if err != nil {
-		var fieldErr book.FieldError
-		if errors.As(err, &fieldErr) {
-			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
-		}
 		if errors.Is(err, book.ErrNotUnique) {
 			return v1.NewRequestError(err, http.StatusConflict)
 		}
@@ -101,3 +99,197 @@ func (h bookHandler) Create(ctx context.Context, w http.ResponseWriter, r *http.
 
 	return web.Response(ctx, w, http.StatusCreated, b)
 }
+
+// Export streams the whole catalogue, optionally filtered, as a downloadable file.
+// JSON is used unless one of the bibliographic formats has been requested.
+func (h bookHandler) Export(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var q bookQuery
+	err := web.DecodeQuery(r, &q)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+	filter := q.filter()
+
+	// Only JSON is streamed next to the bibliographic formats.
+	enc, err := negotiateFormat(r, []string{web.JSON{}.MediaType()})
+	if err != nil {
+		return err
+	}
+
+	if enc == nil {
+		w.Header().Set("Content-Disposition", `attachment; filename="books.json"`)
+		return web.StreamResponse(ctx, w, http.StatusOK, "application/json", func(out io.Writer) error {
+			return exportJSON(ctx, out, h.book, filter)
+		})
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="books.%s"`, enc.Extension()))
+	return web.StreamResponse(ctx, w, http.StatusOK, enc.MediaType(), func(out io.Writer) error {
+		bw := enc.NewWriter(out)
+		err := h.book.Each(ctx, filter, bw.Write)
+		if err != nil {
+			return err
+		}
+		return bw.Close()
+	})
+}
+
+func (h bookHandler) BarcodeSVG(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	code, err := h.barcode(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = code.SVG(&buf, barcode.DefaultOptions)
+	if err != nil {
+		return fmt.Errorf("rendering barcode svg: %w", err)
+	}
+
+	return web.RawResponse(ctx, w, http.StatusOK, "image/svg+xml", buf.Bytes())
+}
+
+func (h bookHandler) BarcodePNG(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	code, err := h.barcode(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = code.PNG(&buf, barcode.DefaultOptions)
+	if err != nil {
+		return fmt.Errorf("rendering barcode png: %w", err)
+	}
+
+	return web.RawResponse(ctx, w, http.StatusOK, "image/png", buf.Bytes())
+}
+
+// private
+
+type booksPage struct {
+	Page  int         `json:"page"`
+	Rows  int         `json:"rows"`
+	Books []book.Book `json:"books"`
+}
+
+// List gives the rows of the page to list encoders, e.g. CSV.
+func (p booksPage) List() any {
+	return p.Books
+}
+
+// bookQuery holds the query params of book listings. Paging is ignored by the export.
+type bookQuery struct {
+	Page      int    `query:"page"`
+	Rows      int    `query:"rows"`
+	Isbn      string `query:"isbn"`
+	Title     string `query:"title" validate:"max=500"`
+	Author    string `query:"author" validate:"max=300"`
+	Publisher string `query:"publisher" validate:"max=300"`
+}
+
+func (q bookQuery) filter() book.QueryFilter {
+	return book.QueryFilter{
+		Isbn:      q.Isbn,
+		Title:     q.Title,
+		Author:    q.Author,
+		Publisher: q.Publisher,
+	}
+}
+
+// negotiateFormat returns the bibliographic encoder requested by the client either
+// with the format query param or with the Accept header. It returns nil encoder
+// when one of the generic formats, JSON the first, should be used instead.
+func negotiateFormat(r *http.Request, generic []string) (formats.Encoder, error) {
+	if name := r.URL.Query().Get("format"); name != "" && name != "json" {
+		enc, err := formats.Lookup(name)
+		if err != nil {
+			return nil, v1.NewRequestError(fmt.Errorf("%w: %s", err, name), http.StatusBadRequest)
+		}
+		return enc, nil
+	}
+
+	offers := append(append([]string{}, generic...), formats.MediaTypes()...)
+
+	mt := web.Negotiate(r.Header.Get("Accept"), offers)
+	if mt == "" {
+		return nil, &web.NotAcceptableError{Offers: offers}
+	}
+	for _, g := range generic {
+		if mt == g {
+			return nil, nil
+		}
+	}
+
+	return formats.ByMediaType(mt)
+}
+
+func encodeBooks(ctx context.Context, w http.ResponseWriter, enc formats.Encoder, books ...book.Book) error {
+	var buf bytes.Buffer
+	err := formats.Encode(&buf, enc, books...)
+	if err != nil {
+		return fmt.Errorf("encoding books as %s: %w", enc.Name(), err)
+	}
+
+	return web.RawResponse(ctx, w, http.StatusOK, enc.MediaType(), buf.Bytes())
+}
+
+// exportJSON writes books as JSON array without keeping the whole catalogue in memory.
+func exportJSON(ctx context.Context, w io.Writer, core book.Core, filter book.QueryFilter) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	first := true
+
+	err := core.Each(ctx, filter, func(b book.Book) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		return enc.Encode(b)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "]")
+	return err
+}
+
+// barcode loads the book pointed by the id param and encodes its ISBN-13 as EAN-13 symbol.
+func (h bookHandler) barcode(ctx context.Context, r *http.Request) (barcode.EAN13, error) {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		return barcode.EAN13{}, v1.NewRequestError(fmt.Errorf("id param is not valid: %w", err), http.StatusBadRequest)
+	}
+
+	b, err := h.book.QueryByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, book.ErrNotFound) {
+			return barcode.EAN13{}, v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return barcode.EAN13{}, err
+	}
+
+	return encodeISBN(b)
+}
+
+func encodeISBN(b book.Book) (barcode.EAN13, error) {
+	isbn13, err := isbn.To13(b.Isbn)
+	if err != nil {
+		err = fmt.Errorf("book %d: %w", b.ID, err)
+		return barcode.EAN13{}, v1.NewRequestError(err, http.StatusUnprocessableEntity)
+	}
+
+	code, err := barcode.Encode(isbn13)
+	if err != nil {
+		return barcode.EAN13{}, fmt.Errorf("encoding barcode: %w", err)
+	}
+
+	return code, nil
+}
@@ -0,0 +1,195 @@
+package v1_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	v1 "github.com/tchorzewski1991/bds/app/services/books-api/handlers/v1"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/book/formats"
+	"go.uber.org/zap"
+)
+
+func TestQueryBooksCSV(t *testing.T) {
+	db := sqlx.NewDb(sql.OpenDB(booksConnector{}), "postgres")
+	defer db.Close()
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	app.RegisterEncoder(web.XML{}, web.CSV{}, web.MessagePack{})
+	v1.Routes(app, v1.Config{Logger: zap.NewNop().Sugar(), DB: db})
+
+	tests := []struct {
+		name        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body: `{"page":1,"rows":20,"books":[` +
+				`{"id":1,"isbn":"9780000000002","title":"Dune","author":"Frank Herbert","publication_year":"1965","publisher":null,"cover_url":null},` +
+				`{"id":2,"isbn":"9780000000019","title":"Solaris, 2nd ed.","author":null,"publication_year":null,"publisher":"MON","cover_url":null}]}`,
+		},
+		{
+			name:        "csv",
+			accept:      "text/csv",
+			contentType: "text/csv",
+			body: "id,isbn,title,author,publication_year,publisher,cover_url\n" +
+				"1,9780000000002,Dune,Frank Herbert,1965,,\n" +
+				"2,9780000000019,\"Solaris, 2nd ed.\",,,MON,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/books", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected content type %q, got %q", tt.contentType, got)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("expected body\n%s\ngot\n%s", tt.body, got)
+			}
+		})
+	}
+}
+
+// TestExportBooks checks that the export streams the same bytes the encoders produce
+// for the whole catalogue.
+func TestExportBooks(t *testing.T) {
+	db := sqlx.NewDb(sql.OpenDB(booksConnector{}), "postgres")
+	defer db.Close()
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	v1.Routes(app, v1.Config{Logger: zap.NewNop().Sugar(), DB: db})
+
+	author, year, publisher := "Frank Herbert", "1965", "MON"
+	books := []book.Book{
+		{ID: 1, Isbn: "9780000000002", Title: "Dune", Author: &author, PublicationYear: &year},
+		{ID: 2, Isbn: "9780000000019", Title: "Solaris, 2nd ed.", Publisher: &publisher},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		format string
+	}{
+		{name: "marc param", query: "?format=marc", format: "marc"},
+		{name: "marcxml param", query: "?format=marcxml", format: "marcxml"},
+		{name: "dc param", query: "?format=dc", format: "dc"},
+		{name: "bibtex param", query: "?format=bibtex", format: "bibtex"},
+		{name: "ris param", query: "?format=ris", format: "ris"},
+		{name: "marc accept", accept: "application/marc", format: "marc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, err := formats.Lookup(tt.format)
+			if err != nil {
+				t.Fatalf("looking up encoder: %v", err)
+			}
+			var want bytes.Buffer
+			if err := formats.Encode(&want, enc, books...); err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/books/export"+tt.query, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+			}
+			if got := w.Header().Get("Content-Type"); got != enc.MediaType() {
+				t.Errorf("expected content type %q, got %q", enc.MediaType(), got)
+			}
+			disposition := `attachment; filename="books.` + enc.Extension() + `"`
+			if got := w.Header().Get("Content-Disposition"); got != disposition {
+				t.Errorf("expected content disposition %q, got %q", disposition, got)
+			}
+			if !bytes.Equal(w.Body.Bytes(), want.Bytes()) {
+				t.Errorf("expected body\n%q\ngot\n%q", want.String(), w.Body.String())
+			}
+		})
+	}
+}
+
+// booksConnector serves the books table out of memory, so listings can be tested
+// without the database. Every query reads all the rows.
+type booksConnector struct{}
+
+func (c booksConnector) Connect(context.Context) (driver.Conn, error) { return booksConn{}, nil }
+func (c booksConnector) Driver() driver.Driver                        { return nil }
+
+type booksConn struct{}
+
+func (booksConn) Prepare(query string) (driver.Stmt, error) { return booksStmt{query: query}, nil }
+func (booksConn) Close() error                              { return nil }
+func (booksConn) Begin() (driver.Tx, error)                 { return nil, errors.New("transactions are not supported") }
+
+type booksStmt struct {
+	query string
+}
+
+func (s booksStmt) Close() error  { return nil }
+func (s booksStmt) NumInput() int { return -1 }
+
+func (s booksStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (s booksStmt) Query([]driver.Value) (driver.Rows, error) {
+	if !strings.HasPrefix(s.query, "select * from books") {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+
+	created := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &booksRows{rows: [][]driver.Value{
+		{int64(1), "9780000000002", "Dune", "Frank Herbert", "1965", nil, nil, created, nil},
+		{int64(2), "9780000000019", "Solaris, 2nd ed.", nil, nil, "MON", nil, created, nil},
+	}}, nil
+}
+
+type booksRows struct {
+	rows [][]driver.Value
+}
+
+func (r *booksRows) Columns() []string {
+	return []string{"id", "isbn", "title", "author", "publication_year", "publisher", "cover_url", "created_at", "updated_at"}
+}
+
+func (r *booksRows) Close() error { return nil }
+
+func (r *booksRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,232 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/tchorzewski1991/bds/base/epub"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/bookfile"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+// maxUploadSize limits the size of uploaded files. It's applied to upload routes with web.BodyLimit.
+const maxUploadSize = 100 << 20
+
+type fileHandler struct {
+	book book.Core
+	file bookfile.Core
+}
+
+// epubQuery holds the query params of the EPUB import.
+type epubQuery struct {
+	Create bool `query:"create"`
+}
+
+// epubImport holds the book read out of the EPUB. Created and Files are set only
+// when the book has been created.
+type epubImport struct {
+	Book      book.NewBook    `json:"book"`
+	Language  string          `json:"language,omitempty"`
+	Conflicts []book.Conflict `json:"conflicts"`
+	Created   *book.Book      `json:"created,omitempty"`
+	Files     []bookfile.File `json:"files,omitempty"`
+}
+
+// Upload attaches the EPUB edition to the book.
+func (h fileHandler) Upload(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		return v1.NewRequestError(fmt.Errorf("id param is not valid: %w", err), http.StatusBadRequest)
+	}
+
+	_, err = h.book.QueryByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, book.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return err
+	}
+
+	data, err := readUpload(r)
+	if err != nil {
+		return err
+	}
+
+	files, err := h.file.AttachEPUB(ctx, id, data)
+	if err != nil {
+		if errors.Is(err, bookfile.ErrInvalidEPUB) {
+			return v1.NewRequestError(err, http.StatusUnprocessableEntity)
+		}
+		return fmt.Errorf("unable to attach file: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusCreated, files)
+}
+
+// Query returns files attached to the book.
+func (h fileHandler) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		return v1.NewRequestError(fmt.Errorf("id param is not valid: %w", err), http.StatusBadRequest)
+	}
+
+	files, err := h.file.QueryByBookID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to query files: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, files)
+}
+
+// Download sends the content of the file back to the client. Only raster images are
+// sent inline, any other file is sent as the attachment, so media types stored with
+// the file can't make browsers render it as a page.
+func (h fileHandler) Download(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		return v1.NewRequestError(fmt.Errorf("id param is not valid: %w", err), http.StatusBadRequest)
+	}
+
+	f, rc, err := h.file.Open(ctx, id, params["file_id"])
+	if err != nil {
+		if errors.Is(err, bookfile.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return fmt.Errorf("unable to open file: %w", err)
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(f.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	switch {
+	case f.Kind == bookfile.KindEPUB:
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="book-%d.epub"`, id))
+	case !epub.IsCoverMediaType(f.MediaType):
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="book-%d-%s"`, id, f.ID))
+	}
+
+	return web.StreamResponse(ctx, w, http.StatusOK, f.MediaType, func(out io.Writer) error {
+		_, err := io.Copy(out, rc)
+		return err
+	})
+}
+
+// FromEPUB pre-fills the new book out of the EPUB metadata and reports existing books
+// it conflicts with. When create param is set the book is created together with
+// the attached file, unless conflicts have been found. The book is deleted again when
+// attaching the file fails, so no book is left without its file.
+func (h fileHandler) FromEPUB(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var q epubQuery
+	err := web.DecodeQuery(r, &q)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	data, err := readUpload(r)
+	if err != nil {
+		return err
+	}
+
+	md, err := bookfile.Inspect(data)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusUnprocessableEntity)
+	}
+
+	nb := bookfile.NewBook(md)
+
+	conflicts, err := h.book.QueryConflicts(ctx, nb)
+	if err != nil {
+		return fmt.Errorf("unable to query conflicts: %w", err)
+	}
+
+	result := epubImport{
+		Book:      nb,
+		Language:  md.Language,
+		Conflicts: conflicts,
+	}
+
+	if !q.Create {
+		return web.Response(ctx, w, http.StatusOK, result)
+	}
+
+	if len(conflicts) > 0 {
+		return web.Response(ctx, w, http.StatusConflict, result)
+	}
+
+	b, err := h.book.Create(ctx, nb)
+	if err != nil {
+		if errors.Is(err, book.ErrNotUnique) {
+			return v1.NewRequestError(err, http.StatusConflict)
+		}
+		return err
+	}
+	result.Created = &b
+
+	result.Files, err = h.file.AttachEPUB(ctx, b.ID, data)
+	if err != nil {
+		if dErr := h.book.Delete(ctx, b.ID); dErr != nil {
+			return fmt.Errorf("unable to attach file: %v, deleting book %d failed: %w", err, b.ID, dErr)
+		}
+		if errors.Is(err, bookfile.ErrInvalidEPUB) {
+			return v1.NewRequestError(err, http.StatusUnprocessableEntity)
+		}
+		return fmt.Errorf("unable to attach file: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusCreated, result)
+}
+
+// private
+
+// readUpload reads the uploaded file either from the raw request body or
+// from the file field of the multipart form.
+func readUpload(r *http.Request) ([]byte, error) {
+	var src io.Reader = r.Body
+
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mt {
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, v1.NewRequestError(err, http.StatusBadRequest)
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return nil, v1.NewRequestError(errors.New("file field is missing"), http.StatusBadRequest)
+			}
+			if part.FormName() == "file" {
+				src = part
+				break
+			}
+		}
+	case epub.MediaType, "application/octet-stream", "":
+	default:
+		return nil, v1.NewRequestError(fmt.Errorf("content type %s is not supported", mt), http.StatusUnsupportedMediaType)
+	}
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, v1.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		}
+		return nil, v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,170 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/tchorzewski1991/bds/base/barcode"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+type labelHandler struct {
+	book book.Core
+}
+
+// labelsRequest limits the number of labels printed on a single sheet to 200.
+type labelsRequest struct {
+	BookIDs []int  `json:"book_ids" validate:"required,max=200"`
+	Format  string `json:"format" validate:"enum=html svg"`
+}
+
+type label struct {
+	Book    book.Book
+	Barcode template.HTML
+}
+
+// Create renders a printable sheet of shelf labels for the requested books.
+// The sheet is rendered as HTML document by default or as a single SVG document.
+func (h labelHandler) Create(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req labelsRequest
+	err := web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	books, err := h.book.QueryByIDs(ctx, req.BookIDs)
+	if err != nil {
+		if errors.Is(err, book.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return fmt.Errorf("unable to query books: %w", err)
+	}
+
+	labels := make([]label, len(books))
+	for i, b := range books {
+		code, err := encodeISBN(b)
+		if err != nil {
+			return err
+		}
+		labels[i] = label{
+			Book:    b,
+			Barcode: template.HTML(code.SVGElement(barcode.DefaultOptions)), // nolint:gosec
+		}
+	}
+
+	var buf bytes.Buffer
+
+	switch req.Format {
+	case "", "html":
+		err = htmlSheet.Execute(&buf, labels)
+		if err != nil {
+			return fmt.Errorf("rendering html sheet: %w", err)
+		}
+		return web.RawResponse(ctx, w, http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+
+	case "svg":
+		err = svgSheet.Execute(&buf, svgLayout(labels))
+		if err != nil {
+			return fmt.Errorf("rendering svg sheet: %w", err)
+		}
+		return web.RawResponse(ctx, w, http.StatusOK, "image/svg+xml", buf.Bytes())
+	}
+
+	return v1.NewFieldError("format", "must be one of: html, svg")
+}
+
+// private
+
+// Dimensions of a single label cell on the SVG sheet, in pixels.
+const (
+	labelColumns = 3
+	labelWidth   = 260
+	labelHeight  = 180
+)
+
+type svgLabel struct {
+	label
+	X int
+	Y int
+}
+
+type svgPage struct {
+	Width  int
+	Height int
+	Labels []svgLabel
+}
+
+func svgLayout(labels []label) svgPage {
+	rows := (len(labels) + labelColumns - 1) / labelColumns
+
+	page := svgPage{
+		Width:  labelColumns * labelWidth,
+		Height: rows * labelHeight,
+		Labels: make([]svgLabel, len(labels)),
+	}
+	for i, l := range labels {
+		page.Labels[i] = svgLabel{
+			label: l,
+			X:     (i % labelColumns) * labelWidth,
+			Y:     (i / labelColumns) * labelHeight,
+		}
+	}
+
+	return page
+}
+
+var funcs = template.FuncMap{
+	"deref": func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	},
+}
+
+var htmlSheet = template.Must(template.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Labels</title>
+<style>
+  @page { size: A4; margin: 10mm; }
+  body { margin: 0; font-family: sans-serif; }
+  .sheet { display: grid; grid-template-columns: repeat(3, 1fr); gap: 4mm; }
+  .label { border: 1px dashed #ccc; padding: 2mm; text-align: center; break-inside: avoid; }
+  .title { font-weight: bold; font-size: 10pt; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+  .author { font-size: 8pt; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+  .label svg { max-width: 100%; height: auto; }
+</style>
+</head>
+<body>
+<div class="sheet">
+{{- range .}}
+  <div class="label">
+    <div class="title">{{.Book.Title}}</div>
+    <div class="author">{{deref .Book.Author}}</div>
+    {{.Barcode}}
+  </div>
+{{- end}}
+</div>
+</body>
+</html>
+`))
+
+var svgSheet = template.Must(template.New("svg").Funcs(funcs).Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<rect width="{{.Width}}" height="{{.Height}}" fill="#fff"/>
+{{- range .Labels}}
+<g transform="translate({{.X}} {{.Y}})">
+  <text x="130" y="16" font-family="sans-serif" font-size="12" font-weight="bold" text-anchor="middle">{{.Book.Title}}</text>
+  <text x="130" y="30" font-family="sans-serif" font-size="10" text-anchor="middle">{{deref .Book.Author}}</text>
+  <g transform="translate(4 38)">{{.Barcode}}</g>
+</g>
+{{- end}}
+</svg>
+`))
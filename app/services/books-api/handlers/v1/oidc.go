@@ -0,0 +1,109 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/user"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/oidc"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+// stateCookie binds the login to the browser which started it, so the callback
+// can't be completed with a state issued to someone else.
+const stateCookie = "bds_oidc_state"
+
+type oidcHandler struct {
+	auth     *auth.Auth
+	user     user.Core
+	provider *oidc.Provider
+}
+
+// Login redirects the user to the identity provider.
+func (h oidcHandler) Login(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	authURL, state, err := h.provider.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin oidc login err: %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     "/" + version + "/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return web.Redirect(ctx, w, r, authURL, http.StatusFound)
+}
+
+// Callback finishes the login and issues the access token for the linked user.
+func (h oidcHandler) Callback(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+
+	if e := query.Get("error"); e != "" {
+		return v1.NewRequestError(fmt.Errorf("identity provider refused the login: %s", e), http.StatusUnauthorized)
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		return v1.NewRequestError(oidc.ErrInvalidState, http.StatusBadRequest)
+	}
+
+	// The cookie is no longer needed, whatever the outcome.
+	http.SetCookie(w, &http.Cookie{
+		Name:   stateCookie,
+		Path:   "/" + version + "/auth/oidc",
+		MaxAge: -1,
+	})
+
+	tkn, err := h.provider.Finish(ctx, state, query.Get("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, oidc.ErrInvalidState):
+			return v1.NewRequestError(oidc.ErrInvalidState, http.StatusBadRequest)
+		case errors.Is(err, oidc.ErrInvalidToken):
+			return v1.NewRequestError(oidc.ErrInvalidToken, http.StatusUnauthorized)
+		default:
+			return fmt.Errorf("finish oidc login err: %w", err)
+		}
+	}
+
+	claims, err := h.user.LoginExternal(ctx, user.ExternalIdentity{
+		Issuer:        tkn.Issuer,
+		Subject:       tkn.Subject,
+		Email:         tkn.Email,
+		EmailVerified: tkn.EmailVerified,
+		Permissions:   tkn.Permissions,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrEmailNotVerified), errors.Is(err, user.ErrDisabled):
+			return v1.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("external login err: %w", err)
+		}
+	}
+
+	access, err := h.auth.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generate token err: %w", err)
+	}
+
+	refresh, err := h.user.IssueRefreshToken(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("issue refresh token err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, tokenResponse{
+		Token:        access,
+		RefreshToken: refresh,
+	})
+}
@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/base/web/openapi"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+// docsPage renders the API description fetched from openapi.json. It's self-contained,
+// so the docs work without access to any CDN.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// Spec describes the v1 routes registered in the app. Routes taking mid.Authenticate
+// accept both the access token and the API key.
+func Spec(routes []web.Route) *openapi.Document {
+	cfg := openapi.Config{
+		Title:       "Book Data Service",
+		Version:     "1",
+		Description: "Catalogue of books with their files, together with users and access management.",
+		Prefix:      "/" + version,
+		Error:       v1.ErrorResponse{},
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"bearerAuth": {
+				Type:         "http",
+				Scheme:       "bearer",
+				BearerFormat: "JWT",
+			},
+			"apiKey": {
+				Type: "apiKey",
+				In:   "header",
+				Name: "X-API-Key",
+			},
+		},
+		Secured: func(rt web.Route) bool {
+			for _, m := range rt.Middleware {
+				if m == "mid.Authenticate" {
+					return true
+				}
+			}
+			return false
+		},
+	}
+
+	return openapi.New(cfg, routes)
+}
+
+type docsHandler struct {
+	spec *openapi.Document
+}
+
+// Spec returns the OpenAPI description of the v1 routes.
+func (h docsHandler) Spec(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	return web.Response(ctx, w, http.StatusOK, h.spec)
+}
+
+// Page returns the HTML page browsing the OpenAPI description.
+func (h docsHandler) Page(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	return web.RawResponse(ctx, w, http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
@@ -0,0 +1,57 @@
+package v1_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/tchorzewski1991/bds/app/services/books-api/handlers/v1"
+	"github.com/tchorzewski1991/bds/base/web"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "update the golden file")
+
+// TestOpenAPI fails when the served description drifts from the committed one.
+// Run go test -update after changing routes or the types they use, then review the diff.
+// Routes of the identity provider are left out, as they need the provider to be configured.
+func TestOpenAPI(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	v1.Routes(app, v1.Config{Logger: zap.NewNop().Sugar()})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	var got bytes.Buffer
+	err := json.Indent(&got, w.Body.Bytes(), "", "  ")
+	if err != nil {
+		t.Fatalf("invalid description: %v", err)
+	}
+	got.WriteByte('\n')
+
+	golden := filepath.Join("testdata", "openapi.golden.json")
+
+	if *update {
+		err = os.WriteFile(golden, got.Bytes(), 0o644)
+		if err != nil {
+			t.Fatalf("updating golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading golden file: %v", err)
+	}
+
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("description differs from %s, run go test -update and review the diff:\n%s", golden, got.String())
+	}
+}
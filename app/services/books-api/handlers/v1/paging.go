@@ -0,0 +1,16 @@
+package v1
+
+// maxRowsPerPage limits the number of rows returned by a single page of listings.
+const maxRowsPerPage = 20
+
+// paging returns the page and the number of rows per page requested by the client,
+// falling back to the first page of maxRowsPerPage rows.
+func paging(page int, rowsPerPage int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if rowsPerPage < 1 || rowsPerPage > maxRowsPerPage {
+		rowsPerPage = maxRowsPerPage
+	}
+	return page, rowsPerPage
+}
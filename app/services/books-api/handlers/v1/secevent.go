@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/secevent"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+type securityEventHandler struct {
+	events secevent.Core
+}
+
+// eventsQuery holds the query params of the security event listing.
+type eventsQuery struct {
+	Page     int       `query:"page"`
+	Rows     int       `query:"rows"`
+	Type     string    `query:"type"`
+	Outcome  string    `query:"outcome" validate:"enum=success failure"`
+	UserUUID string    `query:"user_uuid"`
+	IP       string    `query:"ip"`
+	From     time.Time `query:"from"`
+	To       time.Time `query:"to"`
+}
+
+type eventsPage struct {
+	Page   int              `json:"page"`
+	Rows   int              `json:"rows"`
+	Events []secevent.Event `json:"events"`
+}
+
+// List makes CSV write one event per record.
+func (p eventsPage) List() any {
+	return p.Events
+}
+
+// Query returns security events, the most recent first. Events can be filtered by type,
+// outcome, user_uuid and ip, and limited to the from - to period given in RFC 3339.
+func (h securityEventHandler) Query(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var q eventsQuery
+	err := web.DecodeQuery(r, &q)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	page, rowsPerPage := paging(q.Page, q.Rows)
+
+	filter := secevent.QueryFilter{
+		Type:     q.Type,
+		Outcome:  q.Outcome,
+		UserUUID: q.UserUUID,
+		IP:       q.IP,
+		From:     q.From,
+		To:       q.To,
+	}
+
+	events, err := h.events.Query(ctx, filter, page, rowsPerPage)
+	if err != nil {
+		var fieldErr secevent.FieldError
+		if errors.As(err, &fieldErr) {
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		}
+		return fmt.Errorf("unable to query security events: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, eventsPage{
+		Page:   page,
+		Rows:   rowsPerPage,
+		Events: events,
+	})
+}
@@ -1,19 +1,134 @@
 package v1
 
 import (
+	"archive/zip"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/dimfeld/httptreemux/v5"
 	"github.com/pkg/errors"
 	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/denylist"
 	"github.com/tchorzewski1991/bds/business/core/user"
 	"github.com/tchorzewski1991/bds/business/sys/auth"
 	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+	"github.com/tchorzewski1991/bds/business/web/v1/mid"
 )
 
 type userHandler struct {
-	user user.Core
+	auth     *auth.Auth
+	user     user.Core
+	denylist denylist.Core
+}
+
+// tokenResponse is returned whenever new access token is issued.
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// mfaResponse is returned instead of tokenResponse when user has to confirm the second factor.
+type mfaResponse struct {
+	MFARequired    bool   `json:"mfa_required"`
+	ChallengeToken string `json:"challenge_token"`
+}
+
+type registerResponse struct {
+	UUID  string `json:"uuid"`
+	Email string `json:"email"`
+}
+
+type verifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// logoutRequest is optional, the refresh token is revoked only when it's given.
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type resetPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type confirmResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type completeMFARequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+type confirmTOTPRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// messageResponse is returned when the outcome is deliberately vague, e.g. to not reveal accounts.
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+func (h userHandler) Register(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var nu user.NewUser
+	err := web.Decode(r, &nu)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	usr, err := h.user.Register(ctx, nu)
+	if err != nil {
+		var fieldErr user.FieldError
+		if errors.As(err, &fieldErr) {
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		}
+		if errors.Is(err, user.ErrEmailTaken) {
+			return v1.NewRequestError(user.ErrEmailTaken, http.StatusConflict)
+		}
+		return fmt.Errorf("register user err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusCreated, registerResponse{
+		UUID:  usr.UUID,
+		Email: usr.Email,
+	})
+}
+
+func (h userHandler) Verify(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req verifyRequest
+	err := web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	err = h.user.Verify(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidToken) {
+			return v1.NewRequestError(err, http.StatusBadRequest)
+		}
+		return fmt.Errorf("verify user err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusNoContent, nil)
 }
 
 func (h userHandler) Token(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
@@ -24,24 +139,107 @@ func (h userHandler) Token(ctx context.Context, w http.ResponseWriter, r *http.R
 
 	claims, err := h.user.Authenticate(ctx, email, pass)
 	if err != nil {
+		var lockedErr *user.LockedError
 		switch {
-		case errors.Is(err, user.ErrNotFound):
-			return v1.NewRequestError(err, http.StatusNotFound)
+		case errors.As(err, &lockedErr):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			return v1.NewRequestError(err, http.StatusTooManyRequests)
 		case errors.Is(err, user.ErrNotAuthenticated):
 			return v1.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, user.ErrNotVerified), errors.Is(err, user.ErrDisabled):
+			return v1.NewRequestError(err, http.StatusForbidden)
 		default:
 			return fmt.Errorf("authenticate user err: %w", err)
 		}
 	}
 
-	tkn, err := auth.GenerateToken(claims)
+	tkn, err := h.auth.GenerateToken(claims)
 	if err != nil {
 		return fmt.Errorf("generate token err: %w", err)
 	}
 
-	return web.Response(ctx, w, http.StatusOK, struct {
-		Token string `json:"token"`
-	}{tkn})
+	// The challenge token is exchanged for the access token by CompleteMFA.
+	if claims.Kind == auth.KindMFA {
+		return web.Response(ctx, w, http.StatusOK, mfaResponse{
+			MFARequired:    true,
+			ChallengeToken: tkn,
+		})
+	}
+
+	refresh, err := h.user.IssueRefreshToken(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("issue refresh token err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, tokenResponse{
+		Token:        tkn,
+		RefreshToken: refresh,
+	})
+}
+
+// Refresh rotates the refresh token and issues a new access token.
+func (h userHandler) Refresh(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req refreshRequest
+	err := web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	claims, refresh, err := h.user.Refresh(ctx, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidToken), errors.Is(err, user.ErrTokenReused):
+			return v1.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, user.ErrDisabled):
+			return v1.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("refresh token err: %w", err)
+		}
+	}
+
+	tkn, err := h.auth.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generate token err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, tokenResponse{
+		Token:        tkn,
+		RefreshToken: refresh,
+	})
+}
+
+// Logout revokes the access token used for the request and, when given,
+// the family of the refresh token.
+func (h userHandler) Logout(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+	if claims.Kind != auth.KindAccess {
+		return v1.NewRequestError(errors.New("user access token is required"), http.StatusForbidden)
+	}
+
+	var req logoutRequest
+	if r.ContentLength != 0 {
+		err = web.Decode(r, &req)
+		if err != nil {
+			return v1.NewRequestError(err, http.StatusBadRequest)
+		}
+	}
+
+	if req.RefreshToken != "" {
+		err = h.user.RevokeRefreshToken(ctx, claims.Subject, req.RefreshToken)
+		if err != nil && !errors.Is(err, user.ErrInvalidToken) {
+			return fmt.Errorf("revoke refresh token err: %w", err)
+		}
+	}
+
+	err = h.denylist.Revoke(ctx, claims)
+	if err != nil {
+		return fmt.Errorf("revoke token err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusNoContent, nil)
 }
 
 func (h userHandler) Profile(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
@@ -61,11 +259,320 @@ func (h userHandler) Profile(ctx context.Context, w http.ResponseWriter, _ *http
 		return fmt.Errorf("get user profile err: %w", err)
 	}
 
-	return web.Response(ctx, w, http.StatusOK, struct {
-		UUID  string `json:"uuid"`
-		Email string `json:"email"`
+	return web.Response(ctx, w, http.StatusOK, usr.Profile())
+}
+
+// UpdateProfile changes the profile and preferences of the caller.
+func (h userHandler) UpdateProfile(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+
+	var up user.UpdateProfile
+	err = web.Decode(r, &up)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	usr, err := h.user.UpdateProfile(ctx, claims.Subject, up)
+	if err != nil {
+		var fieldErr user.FieldError
+		switch {
+		case errors.As(err, &fieldErr):
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		case errors.Is(err, user.ErrNotFound):
+			return v1.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("update profile err: %w", err)
+		}
+	}
+
+	return web.Response(ctx, w, http.StatusOK, usr.Profile())
+}
+
+// Export sends ZIP archive with everything stored about the caller, one JSON file per kind of data.
+func (h userHandler) Export(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+	if claims.Kind != auth.KindAccess {
+		return v1.NewRequestError(errors.New("user access token is required"), http.StatusForbidden)
+	}
+
+	export, err := h.user.Export(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return fmt.Errorf("export user err: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data any
 	}{
-		UUID:  usr.UUID,
-		Email: usr.Email,
+		{"profile.json", export.Profile},
+		{"identities.json", export.Identities},
+		{"tokens.json", export.Tokens},
+		{"api_keys.json", export.APIKeys},
+		{"mail.json", export.Mail},
+		{"security_events.json", export.Events},
+	}
+
+	w.Header().Set("Content-Disposition", `attachment; filename="bds-export.zip"`)
+	return web.StreamResponse(ctx, w, http.StatusOK, "application/zip", func(out io.Writer) error {
+		zw := zip.NewWriter(out)
+		for _, f := range files {
+			fw, err := zw.Create(f.name)
+			if err != nil {
+				return err
+			}
+			enc := json.NewEncoder(fw)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(f.data)
+			if err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	})
+}
+
+// Erase anonymizes the caller. The access token used for the request stops working immediately.
+func (h userHandler) Erase(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+	if claims.Kind != auth.KindAccess {
+		return v1.NewRequestError(errors.New("user access token is required"), http.StatusForbidden)
+	}
+
+	err = h.user.Erase(ctx, claims.Subject)
+	if err != nil {
+		if errors.Is(err, user.ErrNotFound) {
+			return v1.NewRequestError(err, http.StatusNotFound)
+		}
+		return fmt.Errorf("erase user err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusNoContent, nil)
+}
+
+func (h userHandler) ChangePassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+	if claims.Kind != auth.KindAccess {
+		return v1.NewRequestError(errors.New("user access token is required"), http.StatusForbidden)
+	}
+
+	var req changePasswordRequest
+	err = web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	err = h.user.ChangePassword(ctx, claims.Subject, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		var fieldErr user.FieldError
+		switch {
+		case errors.As(err, &fieldErr):
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		case errors.Is(err, user.ErrNotAuthenticated):
+			return v1.NewRequestError(errors.New("current password is not valid"), http.StatusForbidden)
+		case errors.Is(err, user.ErrNotFound):
+			return v1.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("change password err: %w", err)
+		}
+	}
+
+	return web.Response(ctx, w, http.StatusNoContent, nil)
+}
+
+func (h userHandler) ResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req resetPasswordRequest
+	err := web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	err = h.user.RequestPasswordReset(ctx, req.Email)
+	if err != nil {
+		var fieldErr user.FieldError
+		if errors.As(err, &fieldErr) {
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		}
+		return fmt.Errorf("request password reset err: %w", err)
+	}
+
+	// The response is the same whether the account exists or not.
+	return web.Response(ctx, w, http.StatusAccepted, messageResponse{
+		Message: "if the account exists, the reset token has been sent to its email",
 	})
 }
+
+func (h userHandler) ConfirmResetPassword(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req confirmResetPasswordRequest
+	err := web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	err = h.user.ResetPassword(ctx, req.Token, req.NewPassword)
+	if err != nil {
+		var fieldErr user.FieldError
+		switch {
+		case errors.As(err, &fieldErr):
+			return v1.NewRequestError(fieldErr, http.StatusUnprocessableEntity)
+		case errors.Is(err, user.ErrInvalidToken):
+			return v1.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return fmt.Errorf("reset password err: %w", err)
+		}
+	}
+
+	return web.Response(ctx, w, http.StatusNoContent, nil)
+}
+
+// CompleteMFA exchanges the challenge token and the second factor code for the access token.
+func (h userHandler) CompleteMFA(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	var req completeMFARequest
+	err := web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	challenge, err := h.auth.ValidateToken(req.ChallengeToken)
+	if err != nil {
+		return v1.NewRequestError(user.ErrInvalidToken, http.StatusUnauthorized)
+	}
+
+	claims, err := h.user.CompleteMFA(ctx, challenge, req.Code)
+	if err != nil {
+		var lockedErr *user.LockedError
+		switch {
+		case errors.As(err, &lockedErr):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(lockedErr.RetryAfter.Seconds()))))
+			return v1.NewRequestError(err, http.StatusTooManyRequests)
+		case errors.Is(err, user.ErrInvalidToken), errors.Is(err, user.ErrInvalidCode):
+			return v1.NewRequestError(err, http.StatusUnauthorized)
+		case errors.Is(err, user.ErrDisabled):
+			return v1.NewRequestError(err, http.StatusForbidden)
+		default:
+			return fmt.Errorf("complete mfa err: %w", err)
+		}
+	}
+
+	tkn, err := h.auth.GenerateToken(claims)
+	if err != nil {
+		return fmt.Errorf("generate token err: %w", err)
+	}
+
+	refresh, err := h.user.IssueRefreshToken(ctx, claims.Subject)
+	if err != nil {
+		return fmt.Errorf("issue refresh token err: %w", err)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, tokenResponse{
+		Token:        tkn,
+		RefreshToken: refresh,
+	})
+}
+
+// EnrollTOTP starts the TOTP enrolment. The secret is returned as otpauth:// URI
+// and QR code, ready to be scanned by authenticator app.
+func (h userHandler) EnrollTOTP(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+	if claims.Kind != auth.KindAccess {
+		return v1.NewRequestError(errors.New("user access token is required"), http.StatusForbidden)
+	}
+
+	enrollment, err := h.user.EnrollTOTP(ctx, claims.Subject)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrTOTPEnabled):
+			return v1.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, user.ErrNotFound):
+			return v1.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("enroll totp err: %w", err)
+		}
+	}
+
+	return web.Response(ctx, w, http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP enables two-factor authentication and returns recovery codes.
+func (h userHandler) ConfirmTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	claims, err := auth.GetClaims(ctx)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusForbidden)
+	}
+	if claims.Kind != auth.KindAccess {
+		return v1.NewRequestError(errors.New("user access token is required"), http.StatusForbidden)
+	}
+
+	var req confirmTOTPRequest
+	err = web.Decode(r, &req)
+	if err != nil {
+		return v1.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	codes, err := h.user.ConfirmTOTP(ctx, claims.Subject, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidCode):
+			return v1.NewRequestError(err, http.StatusUnprocessableEntity)
+		case errors.Is(err, user.ErrTOTPEnabled), errors.Is(err, user.ErrTOTPNotEnrolled):
+			return v1.NewRequestError(err, http.StatusConflict)
+		case errors.Is(err, user.ErrNotFound):
+			return v1.NewRequestError(err, http.StatusNotFound)
+		default:
+			return fmt.Errorf("confirm totp err: %w", err)
+		}
+	}
+
+	return web.Response(ctx, w, http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// QueryByUUID returns the user loaded by LoadUser. Access is decided by user.Policy.
+func (h userHandler) QueryByUUID(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	resource, err := auth.GetResource(ctx)
+	if err != nil {
+		return fmt.Errorf("query user err: %w", err)
+	}
+
+	usr, ok := resource.Value.(user.User)
+	if !ok {
+		return fmt.Errorf("query user err: unexpected resource %T", resource.Value)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, toAdminUser(usr))
+}
+
+// LoadUser loads the user from the uuid param, so the policy can be enforced on it.
+func (h userHandler) LoadUser(ctx context.Context, r *http.Request) (auth.Resource, error) {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	usr, err := h.user.QueryByUUID(ctx, params["uuid"])
+	if err != nil {
+		switch {
+		case errors.Is(err, user.ErrInvalidUUID):
+			return auth.Resource{}, v1.NewRequestError(err, http.StatusBadRequest)
+		case errors.Is(err, user.ErrNotFound):
+			return auth.Resource{}, mid.NotFound("user")
+		default:
+			return auth.Resource{}, fmt.Errorf("load user err: %w", err)
+		}
+	}
+
+	return user.Resource(usr), nil
+}
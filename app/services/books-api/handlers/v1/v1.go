@@ -4,9 +4,19 @@ import (
 	"net/http"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/base/epub"
 	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/apikey"
 	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/bookfile"
+	"github.com/tchorzewski1991/bds/business/core/denylist"
+	"github.com/tchorzewski1991/bds/business/core/role"
+	"github.com/tchorzewski1991/bds/business/core/secevent"
 	"github.com/tchorzewski1991/bds/business/core/user"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/blob"
+	"github.com/tchorzewski1991/bds/business/sys/mail"
+	"github.com/tchorzewski1991/bds/business/sys/oidc"
 	"github.com/tchorzewski1991/bds/business/web/v1/mid"
 	"go.uber.org/zap"
 )
@@ -16,21 +26,289 @@ const version = "v1"
 type Config struct {
 	Logger *zap.SugaredLogger
 	DB     *sqlx.DB
+	Blobs  blob.Store
+	Auth   *auth.Auth
+	// OIDC is optional. Sign in with identity provider is available only when it's set.
+	OIDC *oidc.Provider
 }
 
-// Routes binds all the routes for API version 1.
+// Routes binds all the routes for API version 1, documented in the OpenAPI description
+// served at /v1/openapi.json.
 func Routes(app *web.App, cfg Config) {
+	g := app.Group("/" + version)
+
+	// Security events are recorded by the user core and the authentication middleware.
+	sh := securityEventHandler{events: secevent.NewCore(cfg.DB, cfg.Logger)}
+
+	uh := userHandler{
+		auth:     cfg.Auth,
+		user:     user.NewCore(cfg.DB, cfg.Logger, mail.NewOutbox(cfg.DB, cfg.Logger), sh.events, denylist.DefaultSyncInterval),
+		denylist: denylist.NewCore(cfg.DB, cfg.Logger, denylist.DefaultSyncInterval),
+	}
+
+	kh := apiKeyHandler{apikey: apikey.NewCore(cfg.DB, cfg.Logger)}
+
+	// Both verifiers cache what they check, so tokens don't hit the DB on every request.
+	authenticate := mid.Authenticate(mid.AuthConfig{
+		Auth:      cfg.Auth,
+		Verifiers: []auth.Verifier{uh.denylist, uh.user},
+		APIKeys:   kh.apikey,
+		Events:    sh.events,
+	})
+
+	// Routes registered on authed require the client to be authenticated.
+	authed := g.Group("", authenticate)
+
 	// Setup book routes.
 	bh := bookHandler{book: book.NewCore(cfg.DB, cfg.Logger)}
-	app.Handle(http.MethodPost, version, "/books", bh.Create)
-	app.Handle(http.MethodGet, version, "/books", bh.Query)
-	app.Handle(http.MethodGet, version, "/books/:id", bh.QueryByID)
+	authed.Handle(http.MethodPost, "/books", bh.Create, mid.Authorize("books.write")).Describe(web.Doc{
+		Summary:   "Create a book",
+		Request:   book.NewBook{},
+		Responses: map[int]any{http.StatusCreated: book.Book{}},
+	})
+	g.Handle(http.MethodGet, "/books", bh.Query).Describe(web.Doc{
+		Summary:     "List books",
+		Description: "Bibliographic formats can be requested with the format param or the Accept header.",
+		Query:       bookQuery{},
+		Responses:   map[int]any{http.StatusOK: booksPage{}},
+	})
+	g.Handle(http.MethodGet, "/books/export", bh.Export).Describe(web.Doc{
+		Summary:     "Export the catalogue",
+		Description: "Bibliographic formats can be requested with the format param or the Accept header.",
+		Query:       bookQuery{},
+		Responses:   map[int]any{http.StatusOK: []book.Book{}},
+	})
+	g.Handle(http.MethodGet, "/books/:id", bh.QueryByID).Describe(web.Doc{
+		Summary:   "Get a book",
+		Responses: map[int]any{http.StatusOK: book.Book{}},
+	})
+	g.Handle(http.MethodGet, "/books/:id/barcode.svg", bh.BarcodeSVG).Describe(web.Doc{
+		Summary:   "Render the EAN-13 barcode of the book as SVG",
+		Responses: map[int]any{http.StatusOK: web.Raw("image/svg+xml")},
+	})
+	g.Handle(http.MethodGet, "/books/:id/barcode.png", bh.BarcodePNG).Describe(web.Doc{
+		Summary:   "Render the EAN-13 barcode of the book as PNG",
+		Responses: map[int]any{http.StatusOK: web.Raw("image/png")},
+	})
+
+	// Setup book file routes.
+	fh := fileHandler{book: bh.book, file: bookfile.NewCore(cfg.DB, cfg.Logger, cfg.Blobs)}
+	authed.Handle(http.MethodPost, "/books/epub", fh.FromEPUB, mid.Authorize("books.write"), web.BodyLimit(maxUploadSize)).Describe(web.Doc{
+		Summary:     "Import a book from EPUB",
+		Description: "The file can be sent as raw body or as the file field of multipart form.",
+		Query:       epubQuery{},
+		Request:     web.Raw(epub.MediaType),
+		Responses: map[int]any{
+			http.StatusOK:       epubImport{},
+			http.StatusCreated:  epubImport{},
+			http.StatusConflict: epubImport{},
+		},
+	})
+	authed.Handle(http.MethodPost, "/books/:id/files", fh.Upload, mid.Authorize("books.write"), web.BodyLimit(maxUploadSize)).Describe(web.Doc{
+		Summary:     "Attach the EPUB edition to the book",
+		Description: "The file can be sent as raw body or as the file field of multipart form.",
+		Request:     web.Raw(epub.MediaType),
+		Responses:   map[int]any{http.StatusCreated: []bookfile.File{}},
+	})
+	g.Handle(http.MethodGet, "/books/:id/files", fh.Query).Describe(web.Doc{
+		Summary:   "List files of the book",
+		Responses: map[int]any{http.StatusOK: []bookfile.File{}},
+	})
+	g.Handle(http.MethodGet, "/books/:id/files/:file_id", fh.Download).Describe(web.Doc{
+		Summary:   "Download the file",
+		Responses: map[int]any{http.StatusOK: web.Raw(epub.MediaType)},
+	})
+
+	// Setup label routes.
+	lh := labelHandler{book: bh.book}
+	authed.Handle(http.MethodPost, "/labels", lh.Create, mid.Authorize("labels.create")).Describe(web.Doc{
+		Summary:     "Render a sheet of shelf labels",
+		Description: "The sheet is rendered as HTML document by default or as SVG document with the svg format.",
+		Request:     labelsRequest{},
+		Responses:   map[int]any{http.StatusOK: web.Raw("text/html")},
+	})
 
 	// Setup user routes.
-	uh := userHandler{user: user.NewCore(cfg.DB, cfg.Logger)}
-	app.Handle(http.MethodPost, version, "/user/token", uh.Token)
-	app.Handle(http.MethodGet, version, "/user/profile", uh.Profile,
-		mid.Authenticate(),
-		mid.Authorize("user.profile"),
-	)
+	g.Handle(http.MethodPost, "/users", uh.Register).Describe(web.Doc{
+		Summary:   "Register a user",
+		Request:   user.NewUser{},
+		Responses: map[int]any{http.StatusCreated: registerResponse{}},
+	})
+	g.Handle(http.MethodPost, "/users/verify", uh.Verify).Describe(web.Doc{
+		Summary:   "Verify the email of the user",
+		Request:   verifyRequest{},
+		Responses: map[int]any{http.StatusNoContent: nil},
+	})
+	g.Handle(http.MethodPost, "/user/token", uh.Token).Describe(web.Doc{
+		Summary:     "Issue an access token",
+		Description: "Credentials are sent with HTTP basic authentication. Users with the second factor get the challenge token instead.",
+		Responses:   map[int]any{http.StatusOK: tokenResponse{}},
+	})
+	g.Handle(http.MethodPost, "/user/token/mfa", uh.CompleteMFA).Describe(web.Doc{
+		Summary:   "Exchange the challenge token and the second factor code for an access token",
+		Request:   completeMFARequest{},
+		Responses: map[int]any{http.StatusOK: tokenResponse{}},
+	})
+	g.Handle(http.MethodPost, "/user/token/refresh", uh.Refresh).Describe(web.Doc{
+		Summary:   "Rotate the refresh token",
+		Request:   refreshRequest{},
+		Responses: map[int]any{http.StatusOK: tokenResponse{}},
+	})
+	g.Handle(http.MethodPost, "/user/password/reset", uh.ResetPassword).Describe(web.Doc{
+		Summary:   "Request a password reset",
+		Request:   resetPasswordRequest{},
+		Responses: map[int]any{http.StatusAccepted: messageResponse{}},
+	})
+	g.Handle(http.MethodPost, "/user/password/reset/confirm", uh.ConfirmResetPassword).Describe(web.Doc{
+		Summary:   "Set a new password with the reset token",
+		Request:   confirmResetPasswordRequest{},
+		Responses: map[int]any{http.StatusNoContent: nil},
+	})
+
+	authed.Handle(http.MethodGet, "/users/:uuid", uh.QueryByUUID,
+		mid.Enforce(user.Policy, user.ActionRead, uh.LoadUser),
+	).Describe(web.Doc{
+		Summary:   "Get a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	authed.Handle(http.MethodPost, "/user/token/logout", uh.Logout).Describe(web.Doc{
+		Summary:   "Revoke the access token and optionally the refresh token",
+		Request:   logoutRequest{},
+		Responses: map[int]any{http.StatusNoContent: nil},
+	})
+	authed.Handle(http.MethodGet, "/user/profile", uh.Profile, mid.Authorize("user.profile")).Describe(web.Doc{
+		Summary:   "Get the profile of the caller",
+		Responses: map[int]any{http.StatusOK: user.Profile{}},
+	})
+	authed.Handle(http.MethodPut, "/user/profile", uh.UpdateProfile, mid.Authorize("user.profile")).Describe(web.Doc{
+		Summary:   "Update the profile of the caller",
+		Request:   user.UpdateProfile{},
+		Responses: map[int]any{http.StatusOK: user.Profile{}},
+	})
+	authed.Handle(http.MethodGet, "/user/export", uh.Export).Describe(web.Doc{
+		Summary:   "Export everything stored about the caller",
+		Responses: map[int]any{http.StatusOK: web.Raw("application/zip")},
+	})
+	authed.Handle(http.MethodDelete, "/user", uh.Erase).Describe(web.Doc{
+		Summary:   "Erase the account of the caller",
+		Responses: map[int]any{http.StatusNoContent: nil},
+	})
+	authed.Handle(http.MethodPut, "/user/password", uh.ChangePassword).Describe(web.Doc{
+		Summary:   "Change the password of the caller",
+		Request:   changePasswordRequest{},
+		Responses: map[int]any{http.StatusNoContent: nil},
+	})
+	authed.Handle(http.MethodPost, "/user/mfa/totp", uh.EnrollTOTP).Describe(web.Doc{
+		Summary:   "Start the TOTP enrolment",
+		Responses: map[int]any{http.StatusOK: user.TOTPEnrollment{}},
+	})
+	authed.Handle(http.MethodPost, "/user/mfa/totp/confirm", uh.ConfirmTOTP).Describe(web.Doc{
+		Summary:   "Enable TOTP and get recovery codes",
+		Request:   confirmTOTPRequest{},
+		Responses: map[int]any{http.StatusOK: recoveryCodesResponse{}},
+	})
+
+	// Setup identity provider routes.
+	if cfg.OIDC != nil {
+		oh := oidcHandler{auth: cfg.Auth, user: uh.user, provider: cfg.OIDC}
+		g.Handle(http.MethodGet, "/auth/oidc/login", oh.Login).Describe(web.Doc{
+			Summary:   "Sign in with the identity provider",
+			Responses: map[int]any{http.StatusFound: nil},
+		})
+		g.Handle(http.MethodGet, "/auth/oidc/callback", oh.Callback).Describe(web.Doc{
+			Summary:   "Finish signing in with the identity provider",
+			Responses: map[int]any{http.StatusOK: tokenResponse{}},
+		})
+	}
+
+	// Setup admin routes.
+	ah := adminHandler{user: uh.user, role: role.NewCore(cfg.DB, cfg.Logger)}
+	admin := authed.Group("/admin", mid.Authorize("users.admin"))
+	admin.Handle(http.MethodGet, "/users", ah.QueryUsers).Describe(web.Doc{
+		Summary:   "List users",
+		Query:     usersQuery{},
+		Responses: map[int]any{http.StatusOK: usersPage{}},
+	})
+	admin.Handle(http.MethodGet, "/users/:uuid", ah.QueryUserByUUID).Describe(web.Doc{
+		Summary:   "Get a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodPut, "/users/:uuid", ah.UpdateUser).Describe(web.Doc{
+		Summary:   "Update a user",
+		Request:   user.UpdateUser{},
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodPost, "/users/:uuid/disable", ah.DisableUser).Describe(web.Doc{
+		Summary:   "Disable a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodPost, "/users/:uuid/enable", ah.EnableUser).Describe(web.Doc{
+		Summary:   "Enable a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodPut, "/users/:uuid/permissions/:permission", ah.GrantPermission).Describe(web.Doc{
+		Summary:   "Grant a permission to a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodDelete, "/users/:uuid/permissions/:permission", ah.RevokePermission).Describe(web.Doc{
+		Summary:   "Revoke a permission from a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodPut, "/users/:uuid/roles/:role", ah.AssignRole).Describe(web.Doc{
+		Summary:   "Assign a role to a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodDelete, "/users/:uuid/roles/:role", ah.UnassignRole).Describe(web.Doc{
+		Summary:   "Unassign a role from a user",
+		Responses: map[int]any{http.StatusOK: adminUser{}},
+	})
+	admin.Handle(http.MethodGet, "/roles", ah.QueryRoles).Describe(web.Doc{
+		Summary:   "List roles",
+		Responses: map[int]any{http.StatusOK: []role.Role{}},
+	})
+	admin.Handle(http.MethodGet, "/roles/:name", ah.QueryRoleByName).Describe(web.Doc{
+		Summary:   "Get a role",
+		Responses: map[int]any{http.StatusOK: role.Role{}},
+	})
+	admin.Handle(http.MethodPut, "/roles/:name", ah.SaveRole).Describe(web.Doc{
+		Summary:   "Create or replace a role",
+		Request:   role.NewRole{},
+		Responses: map[int]any{http.StatusOK: role.Role{}},
+	})
+	admin.Handle(http.MethodDelete, "/roles/:name", ah.DeleteRole).Describe(web.Doc{
+		Summary:   "Delete a role",
+		Responses: map[int]any{http.StatusNoContent: nil},
+	})
+
+	// Setup API key routes.
+	admin.Handle(http.MethodPost, "/api-keys", kh.Create).Describe(web.Doc{
+		Summary:     "Create an API key",
+		Description: "The key is returned only once, it can't be retrieved later.",
+		Request:     apikey.NewKey{},
+		Responses:   map[int]any{http.StatusCreated: createdKey{}},
+	})
+	admin.Handle(http.MethodGet, "/api-keys", kh.Query).Describe(web.Doc{
+		Summary:   "List API keys",
+		Responses: map[int]any{http.StatusOK: []apikey.Key{}},
+	})
+	admin.Handle(http.MethodGet, "/api-keys/:id", kh.QueryByID).Describe(web.Doc{
+		Summary:   "Get an API key",
+		Responses: map[int]any{http.StatusOK: apikey.Key{}},
+	})
+	admin.Handle(http.MethodDelete, "/api-keys/:id", kh.Revoke).Describe(web.Doc{
+		Summary:   "Revoke an API key",
+		Responses: map[int]any{http.StatusOK: apikey.Key{}},
+	})
+
+	// Setup security event routes.
+	admin.Handle(http.MethodGet, "/security-events", sh.Query).Describe(web.Doc{
+		Summary:   "List security events",
+		Query:     eventsQuery{},
+		Responses: map[int]any{http.StatusOK: eventsPage{}},
+	})
+
+	// Setup API description routes. They go last, so the description covers all the routes above.
+	dh := docsHandler{spec: Spec(app.Routes())}
+	g.Handle(http.MethodGet, "/openapi.json", dh.Spec).Describe(web.Doc{Hidden: true})
+	g.Handle(http.MethodGet, "/docs", dh.Page).Describe(web.Doc{Hidden: true})
 }
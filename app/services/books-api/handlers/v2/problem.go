@@ -0,0 +1,29 @@
+package v2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/tchorzewski1991/bds/base/web"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+	v2 "github.com/tchorzewski1991/bds/business/web/v2"
+)
+
+// Problems returns the error catalogue: every type of problem the API reports.
+func Problems(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	return web.Response(ctx, w, http.StatusOK, v2.Catalogue())
+}
+
+// Problem returns the catalogue entry the type of problem resolves to.
+func Problem(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	params := httptreemux.ContextParams(r.Context()) // nolint:contextcheck
+
+	pt, ok := v2.LookupType(params["type"])
+	if !ok {
+		return v1.NewRequestError(fmt.Errorf("problem type %s not found", params["type"]), http.StatusNotFound)
+	}
+
+	return web.Response(ctx, w, http.StatusOK, pt)
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 
 	"github.com/tchorzewski1991/bds/base/web"
+	v1mid "github.com/tchorzewski1991/bds/business/web/v1/mid"
+	"github.com/tchorzewski1991/bds/business/web/v2/mid"
 	"go.uber.org/zap"
 )
 
@@ -13,7 +15,15 @@ type Config struct {
 	Logger *zap.SugaredLogger
 }
 
-// Routes binds all the routes for API version 2.
-func Routes(app *web.App, _ Config) {
-	app.Handle(http.MethodGet, version, "/books", List)
+// Routes binds all the routes for API version 2. Errors are answered with problem details,
+// panics are recovered within the group, so they are reported the same way. So are
+// requests to unknown v2 paths and methods.
+func Routes(app *web.App, cfg Config) {
+	g := app.Group("/"+version, mid.Problems(cfg.Logger), v1mid.Panics())
+	g.HandleUnmatched()
+	g.Handle(http.MethodGet, "/books", List)
+
+	// Setup error catalogue routes. Types of problems resolve to them.
+	g.Handle(http.MethodGet, "/problems", Problems)
+	g.Handle(http.MethodGet, "/problems/:type", Problem)
 }
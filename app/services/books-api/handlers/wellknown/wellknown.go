@@ -0,0 +1,33 @@
+// Package wellknown provides the /.well-known endpoints described by RFC 8615.
+package wellknown
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+)
+
+const prefix = "/.well-known"
+
+type Config struct {
+	Auth *auth.Auth
+}
+
+// Routes binds all the well-known routes.
+func Routes(app *web.App, cfg Config) {
+	h := handler{auth: cfg.Auth}
+
+	app.Group(prefix).Handle(http.MethodGet, "/jwks.json", h.JWKS)
+}
+
+type handler struct {
+	auth *auth.Auth
+}
+
+// JWKS publishes public keys, so other services can verify tokens offline.
+func (h handler) JWKS(ctx context.Context, w http.ResponseWriter, _ *http.Request) error {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	return web.Response(ctx, w, http.StatusOK, h.auth.JWKS())
+}
@@ -15,7 +15,11 @@ import (
 	"github.com/emadolsky/automaxprocs/maxprocs"
 	"github.com/tchorzewski1991/bds/app/services/books-api/handlers"
 	"github.com/tchorzewski1991/bds/base/logger"
+	"github.com/tchorzewski1991/bds/business/core/secevent"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/blob"
 	"github.com/tchorzewski1991/bds/business/sys/database"
+	"github.com/tchorzewski1991/bds/business/sys/oidc"
 	_ "go.uber.org/automaxprocs"
 	"go.uber.org/zap"
 )
@@ -23,6 +27,10 @@ import (
 var build = "develop"
 var service = "BOOKS-API"
 
+// devKeysDir holds the signing key created by make devkey. It's used only by
+// development builds, others have to be given the keys dir explicitly.
+const devKeysDir = "infra/keys/"
+
 func main() {
 	// ================================================================================================================
 	// Set GOMAXPROCS
@@ -79,6 +87,28 @@ func run(logger *zap.SugaredLogger) error {
 			Host string `conf:"default:localhost"`
 			Name string `conf:"default:bds"`
 		}
+		Blob struct {
+			Dir string `conf:"default:/tmp/bds/blobs"`
+		}
+		Auth struct {
+			// KeysDir has to be set outside development, keys are never shipped with the service.
+			KeysDir   string
+			ActiveKID string
+		}
+		OIDC struct {
+			Issuer       string
+			ClientID     string
+			ClientSecret string   `conf:"mask"`
+			RedirectURL  string   `conf:"default:http://localhost:3000/v1/auth/oidc/callback"`
+			Scopes       []string `conf:"default:email;profile"`
+			// PermissionMap rules have the claim=value:permission,permission form.
+			PermissionMap []string
+		}
+		SecurityEvents struct {
+			// Retention of zero keeps events forever.
+			Retention     time.Duration `conf:"default:2160h"`
+			PurgeInterval time.Duration `conf:"default:1h"`
+		}
 	}{
 		Version: conf.Version{
 			Build: build,
@@ -121,6 +151,86 @@ func run(logger *zap.SugaredLogger) error {
 		_ = db.Close()
 	}()
 
+	// ================================================================================================================
+	// Blob storage support
+
+	logger.Infow("Starting blob storage", "dir", cfg.Blob.Dir)
+
+	blobs, err := blob.NewFS(cfg.Blob.Dir)
+	if err != nil {
+		return fmt.Errorf("opening blob storage: %w", err)
+	}
+
+	// ================================================================================================================
+	// Authentication support
+
+	keysDir := cfg.Auth.KeysDir
+	if keysDir == "" {
+		if !development(build) {
+			return errors.New("auth keys dir has to be set outside development")
+		}
+		keysDir = devKeysDir
+	}
+
+	logger.Infow("Starting authentication support", "keys_dir", keysDir)
+
+	a, err := auth.New(auth.Config{
+		KeysDir:   keysDir,
+		ActiveKID: cfg.Auth.ActiveKID,
+	})
+	if err != nil {
+		return fmt.Errorf("constructing auth: %w", err)
+	}
+
+	// ================================================================================================================
+	// Identity provider support
+
+	var provider *oidc.Provider
+
+	if cfg.OIDC.Issuer != "" {
+		logger.Infow("Starting identity provider support", "issuer", cfg.OIDC.Issuer)
+
+		mapping, err := oidc.ParseMapping(cfg.OIDC.PermissionMap)
+		if err != nil {
+			return fmt.Errorf("parsing oidc permission map: %w", err)
+		}
+
+		provider = oidc.New(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+			Mapping:      mapping,
+			Client:       &http.Client{Timeout: 10 * time.Second},
+		})
+	}
+
+	// ================================================================================================================
+	// Security events retention
+
+	logger.Infow("Starting security events retention", "retention", cfg.SecurityEvents.Retention)
+
+	retainCtx, stopRetain := context.WithCancel(context.Background())
+	defer stopRetain()
+
+	go secevent.NewCore(db, logger).Retain(retainCtx, cfg.SecurityEvents.Retention, cfg.SecurityEvents.PurgeInterval)
+
+	// ================================================================================================================
+	// Setup App routes
+
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT)
+
+	apiMux := handlers.ApiMux(handlers.ApiMuxConfig{
+		Shutdown: shutdown,
+		Logger:   logger,
+		DB:       db,
+		Blobs:    blobs,
+		Auth:     a,
+		OIDC:     provider,
+	})
+
 	// ================================================================================================================
 	// Start Debug service
 
@@ -130,6 +240,7 @@ func run(logger *zap.SugaredLogger) error {
 		Build:  build,
 		Logger: logger,
 		DB:     db,
+		Routes: apiMux.Routes(),
 	})
 
 	go func() {
@@ -141,15 +252,6 @@ func run(logger *zap.SugaredLogger) error {
 	// ================================================================================================================
 	// Starting App
 
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGTERM, syscall.SIGINT)
-
-	apiMux := handlers.ApiMux(handlers.ApiMuxConfig{
-		Shutdown: shutdown,
-		Logger:   logger,
-		DB:       db,
-	})
-
 	apiSrv := http.Server{
 		Addr:         cfg.Api.Host,
 		Handler:      apiMux,
@@ -185,3 +287,8 @@ func run(logger *zap.SugaredLogger) error {
 
 	return nil
 }
+
+// development reports whether the build comes from go run or make build.
+func development(build string) bool {
+	return build == "develop" || build == "local"
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+func main() {
+
+	var alg string
+	flag.StringVar(&alg, "alg", "RS256", "Algorithm of the key: RS256 or EdDSA")
+
+	var dir string
+	flag.StringVar(&dir, "dir", "infra/keys/", "Folder where the key is written")
+
+	var kid string
+	flag.StringVar(&kid, "kid", "", "ID of the key (random by default)")
+
+	flag.Parse()
+
+	if kid == "" {
+		kid = uuid.NewString()
+	}
+
+	var key any
+	var err error
+
+	switch alg {
+	case "RS256":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		fmt.Println("ERR: unsupported algorithm:", alg)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Println("ERR: generating key:", err)
+		os.Exit(1)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		fmt.Println("ERR: marshaling key:", err)
+		os.Exit(1)
+	}
+
+	path := filepath.Join(dir, kid+".pem")
+
+	// The key is never overwritten, as it could invalidate all issued tokens.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		fmt.Println("ERR: creating key file:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	err = pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err != nil {
+		fmt.Println("ERR: writing key:", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(path)
+}
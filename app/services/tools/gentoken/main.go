@@ -25,6 +25,12 @@ func main() {
 	var perm string
 	flag.StringVar(&perm, "perm", "", "Permissions of the user")
 
+	var keys string
+	flag.StringVar(&keys, "keys", "infra/keys/", "Folder with PEM keys")
+
+	var kid string
+	flag.StringVar(&kid, "kid", "", "ID of the key signing the JWT (required when there are many private keys)")
+
 	flag.Parse()
 
 	if sub == "" {
@@ -65,7 +71,13 @@ func main() {
 		},
 		Permissions: perms,
 	}
-	tkn, err := auth.GenerateToken(claims)
+	a, err := auth.New(auth.Config{KeysDir: keys, ActiveKID: kid})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	tkn, err := a.GenerateToken(claims)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
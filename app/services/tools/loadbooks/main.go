@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bufio"
 	"context"
-	"encoding/csv"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/book/formats"
 	"github.com/tchorzewski1991/bds/business/sys/database"
 )
 
@@ -34,6 +38,18 @@ func run() error {
 	var bufferSize int
 	flag.IntVar(&bufferSize, "buffer", 10_000, "The size of books buffer used within single db tx.")
 
+	var format string
+	flag.StringVar(&format, "format", "auto", "The source format: auto, "+strings.Join(formats.DecoderNames(), ", ")+".")
+
+	var columns string
+	flag.StringVar(&columns, "columns", "", "CSV column mapping, e.g. isbn=0,title=Book-Title (Book-Crossing layout by default).")
+
+	var header bool
+	flag.BoolVar(&header, "header", true, "Whether the first CSV row contains column names.")
+
+	var comma string
+	flag.StringVar(&comma, "comma", ",", "CSV field delimiter.")
+
 	flag.Parse()
 
 	if source == "" {
@@ -48,6 +64,15 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("source file does not exist: %w", err)
 	}
+	defer func() { _ = f.Close() }()
+
+	src := bufio.NewReader(f)
+
+	dec, err := decoder(src, format, columns, header, comma)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Using %s decoder\n", dec.Name())
 
 	db, err := database.Open(database.Config{
 		User: "postgres",
@@ -67,12 +92,7 @@ func run() error {
 		return fmt.Errorf("cannot check db status: %w", err)
 	}
 
-	r := csv.NewReader(f)
-
-	_, err = r.Read()
-	if err != nil {
-		return fmt.Errorf("cannot read source: %w", err)
-	}
+	r := dec.NewReader(src)
 
 	stats := struct {
 		total   int
@@ -82,7 +102,7 @@ func run() error {
 	}{0, 0, 0, 0}
 
 	bufferPos := 0
-	buffer := make([][]string, 0, bufferSize)
+	buffer := make([]book.NewBook, 0, bufferSize)
 
 	var tx *sqlx.Tx
 
@@ -107,11 +127,11 @@ func run() error {
 		return success, failure
 	}
 
-	var row []string
+	var nb book.NewBook
 
 	for {
 		if len(buffer) < bufferSize {
-			row, err = r.Read()
+			nb, err = r.Read()
 			if errors.Is(err, io.EOF) {
 				success, failure := releaseBuffer()
 				stats.success += success
@@ -120,11 +140,16 @@ func run() error {
 				break
 			}
 			if err != nil {
+				// Malformed records are skipped, any other error means we can't continue reading.
+				var recErr *formats.RecordError
+				if !errors.As(err, &recErr) {
+					return fmt.Errorf("cannot read source: %w", err)
+				}
 				stats.retries += 1
 				continue
 			}
 
-			buffer = append(buffer, row)
+			buffer = append(buffer, nb)
 			bufferPos += 1
 			continue
 		}
@@ -135,7 +160,7 @@ func run() error {
 		stats.failure += failure
 		stats.total += success + failure
 
-		buffer = make([][]string, 0, bufferSize)
+		buffer = make([]book.NewBook, 0, bufferSize)
 		bufferPos = 0
 
 		if stats.total%10_000 == 0 {
@@ -155,25 +180,76 @@ type Book struct {
 	Author          string `db:"author"`
 	PublicationYear string `db:"publication_year"`
 	Publisher       string `db:"publisher"`
+	CoverURL        string `db:"cover_url"`
+}
+
+// decoder selects the decoder for the source, either by name or by detecting the format.
+func decoder(src *bufio.Reader, format, columns string, header bool, comma string) (formats.Decoder, error) {
+	var dec formats.Decoder
+	var err error
+
+	switch format {
+	case "auto":
+		// Errors are ignored as short files are still worth detecting.
+		head, _ := src.Peek(512)
+		dec, err = formats.Detect(head)
+		if err != nil {
+			return nil, fmt.Errorf("cannot detect source format: %w", err)
+		}
+	default:
+		dec, err = formats.LookupDecoder(format)
+		if err != nil {
+			return nil, fmt.Errorf("format %s is not supported: %w", format, err)
+		}
+	}
+
+	if _, ok := dec.(formats.CSV); !ok {
+		return dec, nil
+	}
+
+	cfg := formats.DefaultCSV
+	cfg.Header = header
+
+	if columns != "" {
+		cfg.Mapping, err = formats.ParseMapping(columns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r, size := utf8.DecodeRuneInString(comma)
+	if size == 0 || size != len(comma) {
+		return nil, errors.New("comma must be a single character")
+	}
+	cfg.Comma = r
+
+	return cfg, nil
 }
 
-func save(tx *sqlx.Tx, entry []string) error {
+// save validates the book with the same rules as the API and inserts it into the db.
+func save(tx *sqlx.Tx, nb book.NewBook) error {
 	const q = `
 		insert into books
-     		(isbn, title, author, publication_year, publisher)
+     		(isbn, title, author, publication_year, publisher, cover_url)
 		values
-		    (:isbn, :title, :author, :publication_year, :publisher)
+		    (:isbn, :title, :author, :publication_year, :publisher, nullif(:cover_url, ''))
 	`
 
+	err := book.Validate(nb)
+	if err != nil {
+		return err
+	}
+
 	data := Book{
-		Isbn:            entry[0],
-		Title:           entry[1],
-		Author:          entry[2],
-		PublicationYear: entry[3],
-		Publisher:       entry[4],
+		Isbn:            nb.Isbn,
+		Title:           nb.Title,
+		Author:          nb.Author,
+		PublicationYear: nb.PublicationYear,
+		Publisher:       nb.Publisher,
+		CoverURL:        nb.CoverURL,
 	}
 
-	_, err := tx.NamedExec(q, data)
+	_, err = tx.NamedExec(q, data)
 	if err != nil {
 		return err
 	}
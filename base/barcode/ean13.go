@@ -0,0 +1,117 @@
+// Package barcode provides EAN-13 barcode encoding with SVG and PNG rendering.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidCode = errors.New("code is not a valid EAN-13")
+
+// Modules is the number of modules (narrowest bar widths) in EAN-13 symbol.
+const Modules = 95
+
+// EAN13 represents an encoded EAN-13 symbol.
+type EAN13 struct {
+	// Code holds the 13 digits of the symbol.
+	Code string
+
+	// Bars holds one entry per module. True means the module is dark.
+	Bars [Modules]bool
+}
+
+// Encode encodes 13 digits (or 12 digits without the check digit) as EAN-13.
+func Encode(code string) (EAN13, error) {
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return EAN13{}, ErrInvalidCode
+		}
+	}
+
+	switch len(code) {
+	case 12:
+		code += string(checkDigit(code))
+	case 13:
+		if checkDigit(code[:12]) != code[12] {
+			return EAN13{}, fmt.Errorf("%w: check digit mismatch", ErrInvalidCode)
+		}
+	default:
+		return EAN13{}, ErrInvalidCode
+	}
+
+	e := EAN13{Code: code}
+
+	pos := 0
+	put := func(pattern string) {
+		for i := 0; i < len(pattern); i++ {
+			e.Bars[pos] = pattern[i] == '1'
+			pos++
+		}
+	}
+
+	// The first digit is not encoded directly. It selects the parity
+	// of the six digits forming the left half of the symbol.
+	parity := parities[code[0]-'0']
+
+	put(guard)
+	for i := 1; i <= 6; i++ {
+		d := code[i] - '0'
+		if parity[i-1] == 'G' {
+			put(gCodes[d])
+			continue
+		}
+		put(lCodes[d])
+	}
+	put(center)
+	for i := 7; i <= 12; i++ {
+		put(rCodes[code[i]-'0'])
+	}
+	put(guard)
+
+	return e, nil
+}
+
+// IsGuard reports whether module at position i belongs to one of the guard patterns.
+// Guard bars are usually rendered a bit longer than the data bars.
+func IsGuard(i int) bool {
+	return i < 3 || (i >= 45 && i < 50) || i >= 92
+}
+
+// private
+
+const (
+	guard  = "101"
+	center = "01010"
+)
+
+var lCodes = [10]string{
+	"0001101", "0011001", "0010011", "0111101", "0100011",
+	"0110001", "0101111", "0111011", "0110111", "0001011",
+}
+
+var gCodes = [10]string{
+	"0100111", "0110011", "0011011", "0100001", "0011101",
+	"0111001", "0000101", "0010001", "0001001", "0010111",
+}
+
+var rCodes = [10]string{
+	"1110010", "1100110", "1101100", "1000010", "1011100",
+	"1001110", "1010000", "1000100", "1001000", "1110100",
+}
+
+var parities = [10]string{
+	"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG",
+	"LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL",
+}
+
+func checkDigit(s string) byte {
+	var sum int
+	for i := 0; i < 12; i++ {
+		d := int(s[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
@@ -0,0 +1,103 @@
+package barcode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+		err  error
+	}{
+		{"full code", "9780306406157", "9780306406157", nil},
+		{"check digit added", "978030640615", "9780306406157", nil},
+		{"zero check digit", "400638133393", "4006381333931", nil},
+		{"check digit mismatch", "9780306406158", "", ErrInvalidCode},
+		{"too short", "97803064061", "", ErrInvalidCode},
+		{"not digits", "978030640615X", "", ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Encode(tt.code)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if e.Code != tt.want {
+				t.Fatalf("expected code %q, got %q", tt.want, e.Code)
+			}
+		})
+	}
+}
+
+func TestEncodeBars(t *testing.T) {
+	// 9 selects LGGLGL parity of the left half.
+	want := strings.Join([]string{
+		"101",
+		"0111011", "0001001", "0100111", "0111101", "0100111", "0101111",
+		"01010",
+		"1011100", "1110010", "1010000", "1100110", "1001110", "1000100",
+		"101",
+	}, "")
+
+	e, err := Encode("9780306406157")
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	var got strings.Builder
+	for _, dark := range e.Bars {
+		if dark {
+			got.WriteByte('1')
+			continue
+		}
+		got.WriteByte('0')
+	}
+
+	if got.String() != want {
+		t.Fatalf("unexpected bars:\n got %s\nwant %s", got.String(), want)
+	}
+}
+
+func TestCodeTables(t *testing.T) {
+	for d := 0; d < 10; d++ {
+		// R codes are complements of L codes, G codes are R codes reversed.
+		var complement, reversed strings.Builder
+		for i := 0; i < 7; i++ {
+			if lCodes[d][i] == '0' {
+				complement.WriteByte('1')
+			} else {
+				complement.WriteByte('0')
+			}
+			reversed.WriteByte(rCodes[d][6-i])
+		}
+
+		if rCodes[d] != complement.String() {
+			t.Errorf("digit %d: R code %s is not the complement of L code %s", d, rCodes[d], lCodes[d])
+		}
+		if gCodes[d] != reversed.String() {
+			t.Errorf("digit %d: G code %s is not the reversed R code %s", d, gCodes[d], rCodes[d])
+		}
+
+		// Every code has two bars and two spaces, 7 modules wide.
+		if n := strings.Count(lCodes[d], "01"); n != 2 || lCodes[d][0] != '0' {
+			t.Errorf("digit %d: L code %s doesn't have two bars", d, lCodes[d])
+		}
+	}
+}
+
+func TestIsGuard(t *testing.T) {
+	var guards int
+	for i := 0; i < Modules; i++ {
+		if IsGuard(i) {
+			guards++
+		}
+	}
+	if guards != 11 {
+		t.Fatalf("expected 11 guard modules, got %d", guards)
+	}
+}
@@ -0,0 +1,173 @@
+package barcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Options controls the size of the rendered symbol.
+type Options struct {
+	// Module is the width of a single module in pixels.
+	Module int
+
+	// Height is the height of the data bars in modules.
+	Height int
+}
+
+// DefaultOptions produces a symbol readable by common handheld scanners.
+var DefaultOptions = Options{Module: 2, Height: 60}
+
+// Quiet zones required by the EAN-13 specification, in modules.
+const (
+	quietLeft  = 11
+	quietRight = 7
+	textHeight = 9
+	guardExtra = 5
+)
+
+// SVG renders the symbol together with its human-readable digits as SVG document.
+func (e EAN13) SVG(w io.Writer, opts Options) error {
+	_, err := io.WriteString(w, e.SVGElement(opts))
+	return err
+}
+
+// SVGElement renders the symbol as standalone <svg> element, so it can be embedded in other documents.
+func (e EAN13) SVGElement(opts Options) string {
+	opts = withDefaults(opts)
+	m := opts.Module
+	width := (quietLeft + Modules + quietRight) * m
+	height := (opts.Height + textHeight) * m
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, width, height)
+
+	b.WriteString(`<g fill="#000">`)
+	for i := 0; i < Modules; i++ {
+		if !e.Bars[i] {
+			continue
+		}
+		h := opts.Height
+		if IsGuard(i) {
+			h += guardExtra
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="0" width="%d" height="%d"/>`, (quietLeft+i)*m, m, h*m)
+	}
+	b.WriteString(`</g>`)
+
+	fs := 8 * m
+	y := (opts.Height + textHeight - 1) * m
+	fmt.Fprintf(&b, `<g font-family="monospace" font-size="%d" text-anchor="middle" fill="#000">`, fs)
+	for i, x := range textPositions() {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d">%c</text>`, x*float64(m), y, e.Code[i])
+	}
+	b.WriteString(`</g></svg>`)
+
+	return b.String()
+}
+
+// PNG renders the symbol together with its human-readable digits as PNG image.
+func (e EAN13) PNG(w io.Writer, opts Options) error {
+	opts = withDefaults(opts)
+	m := opts.Module
+	width := (quietLeft + Modules + quietRight) * m
+	height := (opts.Height + textHeight) * m
+
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+
+	for i := 0; i < Modules; i++ {
+		if !e.Bars[i] {
+			continue
+		}
+		h := opts.Height
+		if IsGuard(i) {
+			h += guardExtra
+		}
+		fill(img, (quietLeft+i)*m, 0, m, h*m)
+	}
+
+	// Digits are drawn with 5x7 bitmap font, scaled to the module width.
+	top := (opts.Height + 1) * m
+	for i, x := range textPositions() {
+		left := int(x*float64(m)) - (glyphWidth*m)/2
+		drawDigit(img, e.Code[i], left, top, m)
+	}
+
+	return png.Encode(w, img)
+}
+
+// private
+
+func withDefaults(opts Options) Options {
+	if opts.Module < 1 {
+		opts.Module = DefaultOptions.Module
+	}
+	if opts.Height < 1 {
+		opts.Height = DefaultOptions.Height
+	}
+	return opts
+}
+
+// textPositions returns horizontal centers (in modules) of the 13 human-readable digits.
+func textPositions() [13]float64 {
+	var p [13]float64
+
+	// The first digit is placed in the left quiet zone.
+	p[0] = quietLeft / 2
+
+	// Left half digits start after the start guard.
+	for i := 1; i <= 6; i++ {
+		p[i] = quietLeft + 3 + float64(i-1)*7 + 3.5
+	}
+
+	// Right half digits start after the center guard.
+	for i := 7; i <= 12; i++ {
+		p[i] = quietLeft + 50 + float64(i-7)*7 + 3.5
+	}
+
+	return p
+}
+
+func fill(img *image.Gray, x, y, w, h int) {
+	for dy := 0; dy < h; dy++ {
+		for dx := 0; dx < w; dx++ {
+			img.SetGray(x+dx, y+dy, color.Gray{})
+		}
+	}
+}
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+var glyphs = [10][glyphHeight]string{
+	{"01110", "10001", "10011", "10101", "11001", "10001", "01110"},
+	{"00100", "01100", "00100", "00100", "00100", "00100", "01110"},
+	{"01110", "10001", "00001", "00010", "00100", "01000", "11111"},
+	{"11111", "00010", "00100", "00010", "00001", "10001", "01110"},
+	{"00010", "00110", "01010", "10010", "11111", "00010", "00010"},
+	{"11111", "10000", "11110", "00001", "00001", "10001", "01110"},
+	{"00110", "01000", "10000", "11110", "10001", "10001", "01110"},
+	{"11111", "00001", "00010", "00100", "01000", "01000", "01000"},
+	{"01110", "10001", "10001", "01110", "10001", "10001", "01110"},
+	{"01110", "10001", "10001", "01111", "00001", "00010", "01100"},
+}
+
+func drawDigit(img *image.Gray, digit byte, left, top, scale int) {
+	g := glyphs[digit-'0']
+	for row := 0; row < glyphHeight; row++ {
+		for col := 0; col < glyphWidth; col++ {
+			if g[row][col] == '1' {
+				fill(img, left+col*scale, top+row*scale, scale, scale)
+			}
+		}
+	}
+}
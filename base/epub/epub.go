@@ -0,0 +1,299 @@
+// Package epub provides reading of EPUB 2 and EPUB 3 package metadata.
+package epub
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotEPUB       = errors.New("file is not an epub")
+	ErrNoCover       = errors.New("epub has no cover")
+	ErrNoPackage     = errors.New("epub package document not found")
+	ErrEntryTooLarge = errors.New("epub entry is too large")
+)
+
+// MediaType is the media type of EPUB publications.
+const MediaType = "application/epub+zip"
+
+// CoverMediaTypes lists media types accepted for covers. Only raster images are safe
+// to be served inline, SVG and the like may carry scripts.
+var CoverMediaTypes = []string{"image/gif", "image/jpeg", "image/png", "image/webp"}
+
+// IsCoverMediaType reports whether the media type is one of CoverMediaTypes.
+// Parameters and case are ignored.
+func IsCoverMediaType(mediaType string) bool {
+	mt, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	for _, v := range CoverMediaTypes {
+		if mt == v {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxEntrySize limits the decompressed size of every entry read from the publication,
+// so small archives can't expand into gigabytes of memory.
+const MaxEntrySize = 16 << 20
+
+// Metadata holds the publication metadata found in the OPF package document.
+type Metadata struct {
+	Title       string
+	Creators    []string
+	Identifiers []Identifier
+	Publisher   string
+	Date        string
+	Language    string
+	Cover       *Cover
+}
+
+// Identifier represents dc:identifier entry. Scheme is empty when it is not declared.
+type Identifier struct {
+	Scheme string
+	Value  string
+}
+
+// Cover points at the cover image stored inside the publication.
+type Cover struct {
+	Path      string
+	MediaType string
+}
+
+// Book represents an opened EPUB publication.
+type Book struct {
+	zr       *zip.Reader
+	Metadata Metadata
+}
+
+// Open reads the container and package documents of the publication.
+func Open(r io.ReaderAt, size int64) (*Book, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotEPUB, err)
+	}
+
+	b := Book{zr: zr}
+
+	if mt, err := b.readFile("mimetype"); err == nil && strings.TrimSpace(string(mt)) != MediaType {
+		return nil, fmt.Errorf("%w: unexpected mimetype %q", ErrNotEPUB, mt)
+	}
+
+	opfPath, err := b.packagePath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := b.readFile(opfPath)
+	if err != nil {
+		if errors.Is(err, ErrEntryTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNoPackage, err)
+	}
+
+	var p opf
+	if err = xml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parsing package document: %w", err)
+	}
+
+	b.Metadata = p.metadata(path.Dir(opfPath))
+
+	return &b, nil
+}
+
+// OpenCover returns the content of the cover image. Reading more than MaxEntrySize
+// bytes fails with ErrEntryTooLarge.
+func (b *Book) OpenCover() (io.ReadCloser, error) {
+	if b.Metadata.Cover == nil {
+		return nil, ErrNoCover
+	}
+	f, err := b.open(b.Metadata.Cover.Path)
+	if err != nil {
+		if errors.Is(err, ErrEntryTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrNoCover, err)
+	}
+	return f, nil
+}
+
+// private
+
+type container struct {
+	Rootfiles []struct {
+		FullPath  string `xml:"full-path,attr"`
+		MediaType string `xml:"media-type,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opf struct {
+	Metadata struct {
+		Titles      []string `xml:"title"`
+		Creators    []string `xml:"creator"`
+		Identifiers []struct {
+			Scheme string `xml:"scheme,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"identifier"`
+		Publishers []string `xml:"publisher"`
+		Dates      []string `xml:"date"`
+		Languages  []string `xml:"language"`
+		Metas      []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+}
+
+func (b *Book) readFile(name string) ([]byte, error) {
+	f, err := b.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// open rejects entries declaring the size over MaxEntrySize upfront. The declared size
+// can't be trusted, so the content is limited while reading as well.
+func (b *Book) open(name string) (io.ReadCloser, error) {
+	f, err := b.zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() > MaxEntrySize {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s has %d bytes", ErrEntryTooLarge, name, info.Size())
+	}
+
+	return &limitedEntry{f: f, r: io.LimitReader(f, MaxEntrySize+1), name: name}, nil
+}
+
+type limitedEntry struct {
+	f    io.Closer
+	r    io.Reader
+	name string
+	read int64
+}
+
+func (e *limitedEntry) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	e.read += int64(n)
+	if e.read > MaxEntrySize {
+		return n, fmt.Errorf("%w: %s has more than %d bytes", ErrEntryTooLarge, e.name, MaxEntrySize)
+	}
+	return n, err
+}
+
+func (e *limitedEntry) Close() error {
+	return e.f.Close()
+}
+
+func (b *Book) packagePath() (string, error) {
+	data, err := b.readFile("META-INF/container.xml")
+	if err != nil {
+		if errors.Is(err, ErrEntryTooLarge) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %v", ErrNotEPUB, err)
+	}
+
+	var c container
+	if err = xml.Unmarshal(data, &c); err != nil {
+		return "", fmt.Errorf("parsing container: %w", err)
+	}
+
+	for _, rf := range c.Rootfiles {
+		if rf.MediaType == "" || rf.MediaType == "application/oebps-package+xml" {
+			return rf.FullPath, nil
+		}
+	}
+
+	return "", ErrNoPackage
+}
+
+func (p opf) metadata(dir string) Metadata {
+	md := p.Metadata
+
+	m := Metadata{
+		Title:     first(md.Titles),
+		Publisher: first(md.Publishers),
+		Date:      first(md.Dates),
+		Language:  first(md.Languages),
+	}
+
+	for _, c := range md.Creators {
+		if c = strings.TrimSpace(c); c != "" {
+			m.Creators = append(m.Creators, c)
+		}
+	}
+
+	for _, id := range md.Identifiers {
+		m.Identifiers = append(m.Identifiers, Identifier{
+			Scheme: strings.TrimSpace(id.Scheme),
+			Value:  strings.TrimSpace(id.Value),
+		})
+	}
+
+	// EPUB 3 marks the cover with manifest item property, EPUB 2 with meta element.
+	var coverID string
+	for _, meta := range md.Metas {
+		if meta.Name == "cover" {
+			coverID = meta.Content
+		}
+	}
+
+	for _, item := range p.Manifest {
+		isCover := item.ID == coverID && coverID != ""
+		for _, prop := range strings.Fields(item.Properties) {
+			if prop == "cover-image" {
+				isCover = true
+			}
+		}
+		mediaType, _, err := mime.ParseMediaType(item.MediaType)
+		if isCover && err == nil && IsCoverMediaType(mediaType) {
+			href, err := url.PathUnescape(item.Href)
+			if err != nil {
+				href = item.Href
+			}
+			m.Cover = &Cover{
+				Path:      path.Clean(path.Join(dir, href)),
+				MediaType: mediaType,
+			}
+			break
+		}
+	}
+
+	return m
+}
+
+func first(values []string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,141 @@
+package epub_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/epub"
+)
+
+const container = `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`
+
+const pkg = `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:title>Title</dc:title>
+    <dc:identifier>urn:isbn:9780306406157</dc:identifier>
+  </metadata>
+  <manifest><item id="cover" href="cover.png" media-type="image/png" properties="cover-image"/></manifest>
+</package>`
+
+func build(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"mimetype", "META-INF/container.xml", "OEBPS/content.opf", "OEBPS/cover.png"} {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	data := build(t, map[string][]byte{
+		"mimetype":               []byte(epub.MediaType),
+		"META-INF/container.xml": []byte(container),
+		"OEBPS/content.opf":      []byte(pkg),
+		"OEBPS/cover.png":        []byte("png"),
+	})
+
+	book, err := epub.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("opening: %v", err)
+	}
+	if book.Metadata.Title != "Title" {
+		t.Fatalf("expected title %q, got %q", "Title", book.Metadata.Title)
+	}
+	if book.Metadata.Cover == nil || book.Metadata.Cover.Path != "OEBPS/cover.png" {
+		t.Fatalf("unexpected cover %+v", book.Metadata.Cover)
+	}
+}
+
+func TestEntryTooLarge(t *testing.T) {
+	// Zeros compress very well, so the archive stays small while the entry doesn't.
+	bomb := make([]byte, epub.MaxEntrySize+1)
+
+	t.Run("package document", func(t *testing.T) {
+		data := build(t, map[string][]byte{
+			"META-INF/container.xml": []byte(container),
+			"OEBPS/content.opf":      bomb,
+		})
+
+		_, err := epub.Open(bytes.NewReader(data), int64(len(data)))
+		if !errors.Is(err, epub.ErrEntryTooLarge) {
+			t.Fatalf("expected %v, got %v", epub.ErrEntryTooLarge, err)
+		}
+	})
+
+	t.Run("cover", func(t *testing.T) {
+		data := build(t, map[string][]byte{
+			"META-INF/container.xml": []byte(container),
+			"OEBPS/content.opf":      []byte(pkg),
+			"OEBPS/cover.png":        bomb,
+		})
+
+		book, err := epub.Open(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("opening: %v", err)
+		}
+
+		_, err = book.OpenCover()
+		if !errors.Is(err, epub.ErrEntryTooLarge) {
+			t.Fatalf("expected %v, got %v", epub.ErrEntryTooLarge, err)
+		}
+	})
+
+}
+
+func TestCoverMediaType(t *testing.T) {
+	tests := []struct {
+		mediaType string
+		cover     bool
+	}{
+		{mediaType: "image/png", cover: true},
+		{mediaType: "image/JPEG", cover: true},
+		{mediaType: "image/webp; q=1", cover: true},
+		{mediaType: "image/svg+xml", cover: false},
+		{mediaType: "text/html", cover: false},
+		{mediaType: "image/", cover: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mediaType, func(t *testing.T) {
+			item := fmt.Sprintf(`<item id="cover" href="cover.png" media-type=%q properties="cover-image"/>`, tt.mediaType)
+			data := build(t, map[string][]byte{
+				"META-INF/container.xml": []byte(container),
+				"OEBPS/content.opf":      []byte(strings.Replace(pkg, `<item id="cover" href="cover.png" media-type="image/png" properties="cover-image"/>`, item, 1)),
+				"OEBPS/cover.png":        []byte("image"),
+			})
+
+			book, err := epub.Open(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("opening: %v", err)
+			}
+
+			if got := book.Metadata.Cover != nil; got != tt.cover {
+				t.Fatalf("expected cover %t, got %+v", tt.cover, book.Metadata.Cover)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+// Package isbn provides helpers for validating and converting ISBN numbers.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalid = errors.New("isbn is not valid")
+
+// Normalize strips separators commonly used while printing ISBN numbers.
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == 'x' || r == 'X':
+			b.WriteRune('X')
+		}
+	}
+	return b.String()
+}
+
+// Valid reports whether s is a valid ISBN-10 or ISBN-13 number.
+func Valid(s string) bool {
+	s = Normalize(s)
+	switch len(s) {
+	case 10:
+		return valid10(s)
+	case 13:
+		return valid13(s)
+	}
+	return false
+}
+
+// To13 converts s to the ISBN-13 form. ISBN-13 numbers are returned as is.
+func To13(s string) (string, error) {
+	s = Normalize(s)
+
+	switch {
+	case len(s) == 13 && valid13(s):
+		return s, nil
+	case len(s) == 10 && valid10(s):
+		s = "978" + s[:9]
+		return s + string(checkDigit13(s)), nil
+	}
+
+	return "", ErrInvalid
+}
+
+// To10 converts s to the ISBN-10 form. Only ISBN-13 numbers with 978 prefix have one.
+func To10(s string) (string, error) {
+	s = Normalize(s)
+
+	switch {
+	case len(s) == 10 && valid10(s):
+		return s, nil
+	case len(s) == 13 && valid13(s) && s[:3] == "978":
+		s = s[3:12]
+		return s + string(checkDigit10(s)), nil
+	}
+
+	return "", ErrInvalid
+}
+
+// private
+
+func valid10(s string) bool {
+	var sum int
+	for i := 0; i < 10; i++ {
+		c := s[i]
+		var d int
+		switch {
+		case c >= '0' && c <= '9':
+			d = int(c - '0')
+		case c == 'X' && i == 9:
+			d = 10
+		default:
+			return false
+		}
+		sum += d * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+func valid13(s string) bool {
+	for i := 0; i < 13; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return checkDigit13(s[:12]) == s[12]
+}
+
+// checkDigit13 calculates check digit for the first 12 digits of ISBN-13 (EAN-13).
+func checkDigit13(s string) byte {
+	var sum int
+	for i := 0; i < 12; i++ {
+		d := int(s[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// checkDigit10 calculates check digit for the first 9 digits of ISBN-10.
+func checkDigit10(s string) byte {
+	var sum int
+	for i := 0; i < 9; i++ {
+		sum += int(s[i]-'0') * (10 - i)
+	}
+	d := (11 - sum%11) % 11
+	if d == 10 {
+		return 'X'
+	}
+	return byte('0' + d)
+}
@@ -0,0 +1,73 @@
+package isbn_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/isbn"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		isbn  string
+		valid bool
+	}{
+		{"978-0-306-40615-7", true},
+		{"9780306406157", true},
+		{"0-306-40615-2", true},
+		{"0-8044-2957-X", true},
+		{"0-8044-2957-x", true},
+		{"978-0-306-40615-8", false},
+		{"0-306-40615-3", false},
+		{"X-306-40615-2", false},
+		{"978030640615", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isbn.Valid(tt.isbn); got != tt.valid {
+			t.Errorf("Valid(%q): expected %v, got %v", tt.isbn, tt.valid, got)
+		}
+	}
+}
+
+func TestTo13(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		err  error
+	}{
+		{"0-306-40615-2", "9780306406157", nil},
+		{"0-8044-2957-X", "9780804429573", nil},
+		{"978-0-306-40615-7", "9780306406157", nil},
+		{"0-306-40615-3", "", isbn.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := isbn.To13(tt.isbn)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("To13(%q): expected %q, %v, got %q, %v", tt.isbn, tt.want, tt.err, got, err)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+	tests := []struct {
+		isbn string
+		want string
+		err  error
+	}{
+		{"978-0-306-40615-7", "0306406152", nil},
+		{"9780804429573", "080442957X", nil},
+		{"0-306-40615-2", "0306406152", nil},
+		{"979-10-90636-07-1", "", isbn.ErrInvalid},
+		{"9780306406158", "", isbn.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := isbn.To10(tt.isbn)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("To10(%q): expected %q, %v, got %q, %v", tt.isbn, tt.want, tt.err, got, err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package qr provides QR code encoding (ISO/IEC 18004) with SVG rendering.
+// Only byte mode and versions 1 to 10 are supported, which is enough for
+// URIs and other short payloads.
+package qr
+
+import (
+	"errors"
+)
+
+var ErrTooLong = errors.New("data is too long to be encoded as qr code")
+
+// Level is the error correction level. Higher levels restore more of the damaged
+// symbol, but leave less space for data.
+type Level int
+
+// Error correction levels, restoring approximately 7%, 15%, 25% and 30% of the symbol.
+const (
+	L Level = iota
+	M
+	Q
+	H
+)
+
+// MaxVersion is the largest supported version. Version v symbol has 17+4v modules per side.
+const MaxVersion = 10
+
+// Code represents an encoded QR code symbol.
+type Code struct {
+	// Version of the symbol, from 1 to MaxVersion.
+	Version int
+
+	// Size is the number of modules per side.
+	Size int
+
+	// modules holds the symbol by rows. True means the module is dark.
+	modules [][]bool
+}
+
+// Dark reports whether module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode encodes data in byte mode, using the smallest version able to hold it.
+func Encode(data []byte, level Level) (*Code, error) {
+	if level < L || level > H {
+		return nil, errors.New("qr error correction level is not valid")
+	}
+
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		if 4+countBits(v)+8*len(data) <= 8*dataCodewords(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	codewords := addErrorCorrection(dataBits(data, version, level), version, level)
+
+	s := newSymbol(version)
+	s.drawFunctionPatterns()
+	s.drawCodewords(codewords)
+
+	// Mask which gives the lowest penalty is chosen, as it's the easiest for scanners.
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		s.applyMask(mask)
+		s.drawFormatBits(level, mask)
+		p := s.penalty()
+		if bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		// Masks are XOR based, so applying the same mask again reverts it.
+		s.applyMask(mask)
+	}
+	s.applyMask(best)
+	s.drawFormatBits(level, best)
+
+	return &Code{
+		Version: version,
+		Size:    s.size,
+		modules: s.modules,
+	}, nil
+}
+
+// private
+
+// blocks describes error correction of single version and level:
+// codewords per block and the number of blocks with given data codewords.
+type blocks struct {
+	ec     int
+	group1 [2]int
+	group2 [2]int
+}
+
+// blockTable is indexed by version-1 and level.
+var blockTable = [MaxVersion][4]blocks{
+	{{7, [2]int{1, 19}, [2]int{}}, {10, [2]int{1, 16}, [2]int{}}, {13, [2]int{1, 13}, [2]int{}}, {17, [2]int{1, 9}, [2]int{}}},
+	{{10, [2]int{1, 34}, [2]int{}}, {16, [2]int{1, 28}, [2]int{}}, {22, [2]int{1, 22}, [2]int{}}, {28, [2]int{1, 16}, [2]int{}}},
+	{{15, [2]int{1, 55}, [2]int{}}, {26, [2]int{1, 44}, [2]int{}}, {18, [2]int{2, 17}, [2]int{}}, {22, [2]int{2, 13}, [2]int{}}},
+	{{20, [2]int{1, 80}, [2]int{}}, {18, [2]int{2, 32}, [2]int{}}, {26, [2]int{2, 24}, [2]int{}}, {16, [2]int{4, 9}, [2]int{}}},
+	{{26, [2]int{1, 108}, [2]int{}}, {24, [2]int{2, 43}, [2]int{}}, {18, [2]int{2, 15}, [2]int{2, 16}}, {22, [2]int{2, 11}, [2]int{2, 12}}},
+	{{18, [2]int{2, 68}, [2]int{}}, {16, [2]int{4, 27}, [2]int{}}, {24, [2]int{4, 19}, [2]int{}}, {28, [2]int{4, 15}, [2]int{}}},
+	{{20, [2]int{2, 78}, [2]int{}}, {18, [2]int{4, 31}, [2]int{}}, {18, [2]int{2, 14}, [2]int{4, 15}}, {26, [2]int{4, 13}, [2]int{1, 14}}},
+	{{24, [2]int{2, 97}, [2]int{}}, {22, [2]int{2, 38}, [2]int{2, 39}}, {22, [2]int{4, 18}, [2]int{2, 19}}, {26, [2]int{4, 14}, [2]int{2, 15}}},
+	{{30, [2]int{2, 116}, [2]int{}}, {22, [2]int{3, 36}, [2]int{2, 37}}, {20, [2]int{4, 16}, [2]int{4, 17}}, {24, [2]int{4, 12}, [2]int{4, 13}}},
+	{{18, [2]int{2, 68}, [2]int{2, 69}}, {26, [2]int{4, 43}, [2]int{1, 44}}, {24, [2]int{6, 19}, [2]int{2, 20}}, {28, [2]int{6, 15}, [2]int{2, 16}}},
+}
+
+// alignmentCenters holds coordinates of alignment pattern centers, indexed by version-1.
+var alignmentCenters = [MaxVersion][]int{
+	nil,
+	{6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34},
+	{6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// formatLevel holds the level bits as encoded in format information.
+var formatLevel = [4]int{L: 1, M: 0, Q: 3, H: 2}
+
+func dataCodewords(version int, level Level) int {
+	b := blockTable[version-1][level]
+	return b.group1[0]*b.group1[1] + b.group2[0]*b.group2[1]
+}
+
+// countBits returns the length of the character count indicator in byte mode.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// dataBits builds data codewords: mode indicator, character count, data,
+// terminator and padding up to the capacity of the symbol.
+func dataBits(data []byte, version int, level Level) []byte {
+	var bb bitBuffer
+	bb.append(0b0100, 4)
+	bb.append(len(data), countBits(version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := 8 * dataCodewords(version, level)
+
+	// Terminator is up to 4 zero bits, then the stream is padded to full byte.
+	term := capacity - bb.len()
+	if term > 4 {
+		term = 4
+	}
+	bb.append(0, term)
+	bb.append(0, (8-bb.len()%8)%8)
+
+	for pad := 0xec; bb.len() < capacity; pad ^= 0xec ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	return bb.bytes()
+}
+
+// addErrorCorrection splits data into blocks, computes error correction codewords
+// for each of them and interleaves the result.
+func addErrorCorrection(data []byte, version int, level Level) []byte {
+	b := blockTable[version-1][level]
+	gen := generator(b.ec)
+
+	var dataBlocks, ecBlocks [][]byte
+	for _, g := range [][2]int{b.group1, b.group2} {
+		for i := 0; i < g[0]; i++ {
+			block := data[:g[1]]
+			data = data[g[1]:]
+			dataBlocks = append(dataBlocks, block)
+			ecBlocks = append(ecBlocks, remainder(block, gen))
+		}
+	}
+
+	var result []byte
+	for _, set := range [][][]byte{dataBlocks, ecBlocks} {
+		longest := 0
+		for _, block := range set {
+			if len(block) > longest {
+				longest = len(block)
+			}
+		}
+		for i := 0; i < longest; i++ {
+			for _, block := range set {
+				if i < len(block) {
+					result = append(result, block[i])
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (bb *bitBuffer) append(value, n int) {
+	for i := n - 1; i >= 0; i-- {
+		bb.bits = append(bb.bits, (value>>i)&1 == 1)
+	}
+}
+
+func (bb *bitBuffer) len() int {
+	return len(bb.bits)
+}
+
+func (bb *bitBuffer) bytes() []byte {
+	b := make([]byte, len(bb.bits)/8)
+	for i, bit := range bb.bits {
+		if bit {
+			b[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return b
+}
@@ -0,0 +1,48 @@
+package qr
+
+// Reed-Solomon error correction over GF(256) with the polynomial
+// x^8 + x^4 + x^3 + x^2 + 1, as required by the QR code specification.
+
+// generator returns coefficients of the generator polynomial of given degree,
+// from the highest power, without the leading 1.
+func generator(degree int) []byte {
+	gen := make([]byte, degree)
+	gen[degree-1] = 1
+
+	// Multiply by (x - a^i) for i from 0 to degree-1.
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < degree {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+
+	return gen
+}
+
+// remainder returns the error correction codewords of data.
+func remainder(data, gen []byte) []byte {
+	rem := make([]byte, len(gen))
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[len(rem)-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return rem
+}
+
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11d)
+		z ^= ((int(y) >> i) & 1) * int(x)
+	}
+	return byte(z)
+}
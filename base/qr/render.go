@@ -0,0 +1,45 @@
+package qr
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// QuietZone is the width of light border required around the symbol, in modules.
+const QuietZone = 4
+
+// DefaultModule is the default size of a single module in pixels.
+const DefaultModule = 4
+
+// SVG renders the symbol as SVG document. Module is the size of a single module in pixels.
+func (c *Code) SVG(w io.Writer, module int) error {
+	_, err := io.WriteString(w, c.SVGElement(module))
+	return err
+}
+
+// SVGElement renders the symbol as standalone <svg> element, so it can be embedded in other documents.
+func (c *Code) SVGElement(module int) string {
+	if module < 1 {
+		module = DefaultModule
+	}
+	n := c.Size + 2*QuietZone
+	size := n * module
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, n, n)
+
+	// Dark modules are drawn as a single path, one square per module.
+	b.WriteString(`<path fill="#000" d="`)
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&b, "M%d %dh1v1h-1z", x+QuietZone, y+QuietZone)
+			}
+		}
+	}
+	b.WriteString(`"/></svg>`)
+
+	return b.String()
+}
@@ -0,0 +1,286 @@
+package qr
+
+// symbol is the module matrix under construction. Modules are indexed by row (y), then column (x).
+type symbol struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newSymbol(version int) *symbol {
+	size := 17 + 4*version
+
+	s := symbol{
+		version:  version,
+		size:     size,
+		modules:  make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for y := 0; y < size; y++ {
+		s.modules[y] = make([]bool, size)
+		s.function[y] = make([]bool, size)
+	}
+
+	return &s
+}
+
+// set sets module which is part of function patterns, so it's never used for data.
+func (s *symbol) set(x, y int, dark bool) {
+	s.modules[y][x] = dark
+	s.function[y][x] = true
+}
+
+func (s *symbol) drawFunctionPatterns() {
+	// Timing patterns.
+	for i := 0; i < s.size; i++ {
+		s.set(6, i, i%2 == 0)
+		s.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with separators, in three corners.
+	s.drawFinder(3, 3)
+	s.drawFinder(s.size-4, 3)
+	s.drawFinder(3, s.size-4)
+
+	// Alignment patterns, except those overlapping finder patterns.
+	centers := alignmentCenters[s.version-1]
+	last := len(centers) - 1
+	for i, x := range centers {
+		for j, y := range centers {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			s.drawAlignment(x, y)
+		}
+	}
+
+	// Format bits are drawn for real once the mask is chosen.
+	// Until then, the areas are only reserved.
+	s.drawFormatBits(L, 0)
+	s.drawVersion()
+}
+
+func (s *symbol) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= s.size || y < 0 || y >= s.size {
+				continue
+			}
+			d := abs(dx)
+			if abs(dy) > d {
+				d = abs(dy)
+			}
+			s.set(x, y, d != 2 && d != 4)
+		}
+	}
+}
+
+func (s *symbol) drawAlignment(cx, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			d := abs(dx)
+			if abs(dy) > d {
+				d = abs(dy)
+			}
+			s.set(cx+dx, cy+dy, d != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of 15 bits describing level and mask,
+// protected with BCH code, together with the single always dark module.
+func (s *symbol) drawFormatBits(level Level, mask int) {
+	data := formatLevel[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	bit := func(i int) bool {
+		return (bits>>i)&1 == 1
+	}
+
+	// The copy around the top left finder pattern.
+	for i := 0; i <= 5; i++ {
+		s.set(8, i, bit(i))
+	}
+	s.set(8, 7, bit(6))
+	s.set(8, 8, bit(7))
+	s.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		s.set(14-i, 8, bit(i))
+	}
+
+	// The copy split between the other two finder patterns.
+	for i := 0; i < 8; i++ {
+		s.set(s.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		s.set(8, s.size-15+i, bit(i))
+	}
+	s.set(8, s.size-8, true)
+}
+
+// drawVersion draws both copies of 18 bits describing the version, used from version 7 onwards.
+func (s *symbol) drawVersion() {
+	if s.version < 7 {
+		return
+	}
+
+	rem := s.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := s.version<<12 | rem
+
+	for i := 0; i < 18; i++ {
+		dark := (bits>>i)&1 == 1
+		a, b := s.size-11+i%3, i/3
+		s.set(a, b, dark)
+		s.set(b, a, dark)
+	}
+}
+
+// drawCodewords places codewords in two modules wide columns, going upwards
+// and downwards in turns, starting from the bottom right corner.
+func (s *symbol) drawCodewords(codewords []byte) {
+	i := 0
+	for right := s.size - 1; right >= 1; right -= 2 {
+		// The vertical timing pattern is skipped as a whole column.
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < s.size; vert++ {
+			y := vert
+			if upward {
+				y = s.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if s.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				s.modules[y][x] = (codewords[i/8]>>(7-i%8))&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips data modules selected by the mask pattern.
+func (s *symbol) applyMask(mask int) {
+	for y := 0; y < s.size; y++ {
+		for x := 0; x < s.size; x++ {
+			if s.function[y][x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				s.modules[y][x] = !s.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol with the rules of the specification. Lower is better.
+func (s *symbol) penalty() int {
+	var p, dark int
+
+	for i := 0; i < s.size; i++ {
+		row := make([]bool, s.size)
+		col := make([]bool, s.size)
+		for j := 0; j < s.size; j++ {
+			row[j] = s.modules[i][j]
+			col[j] = s.modules[j][i]
+			if row[j] {
+				dark++
+			}
+		}
+		p += linePenalty(row) + linePenalty(col)
+	}
+
+	// Blocks of 2x2 modules of the same color.
+	for y := 0; y < s.size-1; y++ {
+		for x := 0; x < s.size-1; x++ {
+			c := s.modules[y][x]
+			if c == s.modules[y][x+1] && c == s.modules[y+1][x] && c == s.modules[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+
+	// Proportion of dark modules, by each 5% of deviation from the half.
+	total := s.size * s.size
+	deviation := abs(dark*20 - total*10)
+	p += (deviation / total) * 10
+
+	return p
+}
+
+var finderLike = [][]bool{
+	{true, false, true, true, true, false, true, false, false, false, false},
+	{false, false, false, false, true, false, true, true, true, false, true},
+}
+
+// linePenalty scores runs of the same color and patterns similar to finder patterns.
+func linePenalty(line []bool) int {
+	var p int
+
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += 3 + run - 5
+		}
+		run = 1
+	}
+
+	for i := 0; i+11 <= len(line); i++ {
+		for _, pattern := range finderLike {
+			match := true
+			for j, dark := range pattern {
+				if line[i+j] != dark {
+					match = false
+					break
+				}
+			}
+			if match {
+				p += 40
+			}
+		}
+	}
+
+	return p
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
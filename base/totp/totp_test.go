@@ -0,0 +1,167 @@
+package totp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// secret is the key of RFC test vectors, "12345678901234567890" encoded with base32.
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 Appendix D.
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	key, err := decode(secret)
+	if err != nil {
+		t.Fatalf("decoding secret: %v", err)
+	}
+
+	for c, code := range want {
+		if got := hotp(key, int64(c)); got != code {
+			t.Errorf("counter %d: expected %s, got %s", c, code, got)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	// RFC 6238 Appendix B, SHA1 codes truncated to Digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.unix), func(t *testing.T) {
+			got, err := Code(secret, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatalf("generating code: %v", err)
+			}
+			if got != tt.code {
+				t.Fatalf("expected %s, got %s", tt.code, got)
+			}
+
+			c, ok := Validate(secret, tt.code, time.Unix(tt.unix, 0))
+			if !ok {
+				t.Fatal("expected code to be valid")
+			}
+			if c != tt.unix/30 {
+				t.Fatalf("expected counter %d, got %d", tt.unix/30, c)
+			}
+		})
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	issued := time.Unix(1111111111, 0)
+	now := counter(issued)
+
+	code, err := Code(secret, issued)
+	if err != nil {
+		t.Fatalf("generating code: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		shift time.Duration
+		valid bool
+	}{
+		{"same period", 0, true},
+		{"previous period", -Period, true},
+		{"next period", Period, true},
+		{"two periods before", -2 * Period, false},
+		{"two periods after", 2 * Period, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ok := Validate(secret, code, issued.Add(tt.shift))
+			if ok != tt.valid {
+				t.Fatalf("expected valid %t, got %t", tt.valid, ok)
+			}
+			// The counter is the one of the period the code was issued for,
+			// not the one of the time of validation.
+			if ok && c != now {
+				t.Fatalf("expected counter %d, got %d", now, c)
+			}
+		})
+	}
+}
+
+// TestValidateCounter checks the counter lets callers refuse codes used before:
+// every code of the same period yields the same counter and older periods
+// yield smaller ones, even while they're still within the skew.
+func TestValidateCounter(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	current, _ := Code(secret, now)
+	previous, _ := Code(secret, now.Add(-Period))
+
+	first, ok := Validate(secret, current, now)
+	if !ok {
+		t.Fatal("expected current code to be valid")
+	}
+
+	again, ok := Validate(secret, current, now.Add(Period))
+	if !ok {
+		t.Fatal("expected current code to be valid within the skew")
+	}
+	if again != first {
+		t.Fatalf("expected reused code to yield counter %d, got %d", first, again)
+	}
+
+	older, ok := Validate(secret, previous, now)
+	if !ok {
+		t.Fatal("expected previous code to be valid within the skew")
+	}
+	if older >= first {
+		t.Fatalf("expected previous code to yield counter below %d, got %d", first, older)
+	}
+}
+
+func TestValidateInvalid(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"wrong code", secret, "000000"},
+		{"short code", secret, "05924"},
+		{"long code", secret, "89005924"},
+		{"empty secret", "", "005924"},
+		{"malformed secret", "not base32!", "005924"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := Validate(tt.secret, tt.code, now); ok {
+				t.Fatal("expected code to be refused")
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("generating secret: %v", err)
+	}
+
+	key, err := decode(s)
+	if err != nil {
+		t.Fatalf("decoding generated secret: %v", err)
+	}
+	if len(key) != secretSize {
+		t.Fatalf("expected %d bytes, got %d", secretSize, len(key))
+	}
+}
@@ -0,0 +1,342 @@
+// Package validate checks values against rules declared in struct tags.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/tchorzewski1991/bds/base/isbn"
+)
+
+// FieldErrors holds all the validation failures of the value, keyed by the JSON name of the field,
+// or the name given by the query tag for fields without the JSON name.
+// Fields of nested structs are named with dots, e.g. address.city.
+type FieldErrors map[string]string
+
+func (fe FieldErrors) Error() string {
+	fields := make([]string, 0, len(fe))
+	for f := range fe {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, len(fields))
+	for i, f := range fields {
+		msgs[i] = f + " " + fe[f]
+	}
+
+	return strings.Join(msgs, ", ")
+}
+
+// Details returns the failures keyed by the field name.
+func (fe FieldErrors) Details() map[string]string {
+	return fe
+}
+
+func IsFieldErrors(err error) bool {
+	var fe FieldErrors
+	return errors.As(err, &fe)
+}
+
+// GetFieldErrors returns the FieldErrors wrapped by err.
+func GetFieldErrors(err error) FieldErrors {
+	var fe FieldErrors
+	if !errors.As(err, &fe) {
+		return nil
+	}
+	return fe
+}
+
+// Struct checks v, which must be a struct or a pointer to struct, against the rules
+// declared in the validate tags of its fields. It returns FieldErrors with every
+// violation found, or nil. Rules are separated by commas:
+//
+//	required  the field can't be blank, nil or empty
+//	min=N     strings and slices must have at least N characters or entries, numbers must be at least N
+//	max=N     strings and slices must have at most N characters or entries, numbers must be at most N
+//	enum=A B  the field must be one of the space separated values
+//	isbn      the field must be a valid ISBN-10 or ISBN-13
+//	email     the field must be a bare email address
+//	regex=RE  the field must match the regular expression, it must be the last rule
+//
+// Rules other than required accept blank values, so optional fields are checked only when set.
+// For string slices min and max limit the number of entries, other rules apply to every entry.
+func Struct(v any) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fe := make(FieldErrors)
+	validateStruct(rv, "", fe)
+	if len(fe) == 0 {
+		return nil
+	}
+
+	return fe
+}
+
+// private
+
+// rule checks a single non-blank value and returns the message when it's not valid.
+// Rules of elements are applied to every element of string slices, instead of the slice itself.
+type rule struct {
+	check    func(v reflect.Value) (string, bool)
+	elements bool
+}
+
+type fieldRules struct {
+	index    int
+	name     string
+	required bool
+	rules    []rule
+	nested   bool
+}
+
+// rulesCache holds parsed rules of every validated struct type.
+var rulesCache sync.Map
+
+func validateStruct(rv reflect.Value, prefix string, fe FieldErrors) {
+	for _, fr := range rulesFor(rv.Type()) {
+		name := prefix + fr.name
+		fv := rv.Field(fr.index)
+
+		if blank(fv) {
+			if fr.required {
+				fe[name] = "can't be blank"
+			}
+			continue
+		}
+
+		for fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface {
+			fv = fv.Elem()
+		}
+
+		if fr.nested {
+			validateStruct(fv, name+".", fe)
+			continue
+		}
+
+		if msg, ok := check(fr.rules, fv); !ok {
+			fe[name] = msg
+		}
+	}
+}
+
+func check(rules []rule, v reflect.Value) (string, bool) {
+	stringSlice := v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String
+
+	for _, r := range rules {
+		if !stringSlice || !r.elements {
+			if msg, ok := r.check(v); !ok {
+				return msg, false
+			}
+			continue
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			if msg, ok := r.check(v.Index(i)); !ok {
+				return fmt.Sprintf("entry %q %s", v.Index(i).String(), msg), false
+			}
+		}
+	}
+
+	return "", true
+}
+
+func rulesFor(t reflect.Type) []fieldRules {
+	return collectRules(t, make(map[reflect.Type]bool))
+}
+
+// collectRules parses rules of t and of structs nested in it. Types already being
+// parsed are tracked in visiting, so mutually recursive types don't recurse forever.
+func collectRules(t reflect.Type, visiting map[reflect.Type]bool) []fieldRules {
+	if cached, ok := rulesCache.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	var result []fieldRules
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+
+		fr := fieldRules{index: i, name: name}
+
+		tag := f.Tag.Get("validate")
+		if tag == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() != reflect.Struct {
+				continue
+			}
+			// Rules of types being parsed aren't known yet, so they are visited anyway.
+			if visiting[ft] || len(collectRules(ft, visiting)) > 0 {
+				fr.nested = true
+				result = append(result, fr)
+			}
+			continue
+		}
+
+		fr.required, fr.rules = parseRules(t, f, tag)
+		result = append(result, fr)
+	}
+
+	rulesCache.Store(t, result)
+
+	return result
+}
+
+// parseRules panics on malformed tags, as they are programming errors.
+func parseRules(t reflect.Type, f reflect.StructField, tag string) (bool, []rule) {
+	var required bool
+	var rules []rule
+
+	for tag != "" {
+		var token string
+		if strings.HasPrefix(tag, "regex=") {
+			token, tag = tag, ""
+		} else {
+			token, tag, _ = strings.Cut(tag, ",")
+		}
+
+		name, arg, _ := strings.Cut(token, "=")
+		switch name {
+		case "required":
+			required = true
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(fmt.Sprintf("validate: %s.%s: %s needs a number", t, f.Name, name))
+			}
+			rules = append(rules, lengthRule(name, n))
+		case "enum":
+			rules = append(rules, enumRule(strings.Fields(arg)))
+		case "isbn":
+			rules = append(rules, stringRule("is not a valid ISBN", isbn.Valid))
+		case "email":
+			rules = append(rules, stringRule("is not a valid address", validEmail))
+		case "regex":
+			re := regexp.MustCompile(arg)
+			rules = append(rules, stringRule("has invalid format", re.MatchString))
+		default:
+			panic(fmt.Sprintf("validate: %s.%s: unknown validation rule %q", t, f.Name, name))
+		}
+	}
+
+	return required, rules
+}
+
+func lengthRule(name string, n int) rule {
+	return rule{check: func(v reflect.Value) (string, bool) {
+		var size float64
+		var unit string
+
+		switch v.Kind() {
+		case reflect.String:
+			size, unit = float64(utf8.RuneCountInString(v.String())), " characters long"
+		case reflect.Slice, reflect.Map, reflect.Array:
+			size, unit = float64(v.Len()), " entries"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			size = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			size = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			size = v.Float()
+		default:
+			return "", true
+		}
+
+		switch {
+		case name == "min" && size < float64(n):
+			return fmt.Sprintf("must be at least %d%s", n, unit), false
+		case name == "max" && size > float64(n):
+			return fmt.Sprintf("must be at most %d%s", n, unit), false
+		}
+		return "", true
+	}}
+}
+
+func enumRule(values []string) rule {
+	return stringRule("must be one of: "+strings.Join(values, ", "), func(s string) bool {
+		for _, v := range values {
+			if s == v {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// stringRule applies fn to strings, values of other kinds are accepted.
+func stringRule(msg string, fn func(string) bool) rule {
+	return rule{elements: true, check: func(v reflect.Value) (string, bool) {
+		if v.Kind() != reflect.String || fn(v.String()) {
+			return "", true
+		}
+		return msg, false
+	}}
+}
+
+func validEmail(s string) bool {
+	// ParseAddress accepts display names as well, like "John <john@example.com>".
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s {
+		return false
+	}
+	return strings.Contains(s[strings.LastIndex(s, "@")+1:], ".")
+}
+
+func blank(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// jsonName returns the name of the field in JSON, or empty string for fields left out of JSON.
+// Fields bound to query params with the query tag are named after the param.
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		name = f.Tag.Get("query")
+	}
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,175 @@
+package validate_test
+
+import (
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/validate"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type form struct {
+	Name    string   `json:"name" validate:"required,min=2,max=5"`
+	Age     int      `json:"age" validate:"min=18,max=130"`
+	Score   *float64 `json:"score" validate:"max=1"`
+	Kind    string   `json:"kind" validate:"enum=paper ebook"`
+	ISBN    string   `json:"isbn" validate:"isbn"`
+	Email   string   `json:"email" validate:"email"`
+	Code    string   `json:"code" validate:"regex=^[a-z]{2,3}(,[a-z]+)?$"`
+	Tags    []string `json:"tags" validate:"max=2,enum=new old"`
+	Page    int      `query:"page" validate:"min=1"`
+	Address *address `json:"address"`
+	Skipped string   `json:"-" validate:"required"`
+}
+
+func valid() form {
+	return form{Name: "Ann", Age: 30, Kind: "paper", ISBN: "9780306406157", Email: "ann@example.com", Code: "en", Page: 1}
+}
+
+func TestStruct(t *testing.T) {
+	one, two := 1.0, 2.0
+
+	tests := []struct {
+		name   string
+		change func(f *form)
+		field  string
+		msg    string
+	}{
+		{"valid", func(f *form) {}, "", ""},
+		{"required", func(f *form) { f.Name = "  " }, "name", "can't be blank"},
+		{"min length", func(f *form) { f.Name = "A" }, "name", "must be at least 2 characters long"},
+		{"max length counts characters", func(f *form) { f.Name = "Zoëes" }, "", ""},
+		{"max length", func(f *form) { f.Name = "Annabel" }, "name", "must be at most 5 characters long"},
+		{"min number", func(f *form) { f.Age = 17 }, "age", "must be at least 18"},
+		{"max number", func(f *form) { f.Age = 131 }, "age", "must be at most 130"},
+		{"zero number is checked", func(f *form) { f.Age = 0 }, "age", "must be at least 18"},
+		{"pointer within max", func(f *form) { f.Score = &one }, "", ""},
+		{"pointer over max", func(f *form) { f.Score = &two }, "score", "must be at most 1"},
+		{"enum", func(f *form) { f.Kind = "audio" }, "kind", "must be one of: paper, ebook"},
+		{"blank enum", func(f *form) { f.Kind = "" }, "", ""},
+		{"isbn-10", func(f *form) { f.ISBN = "0306406152" }, "", ""},
+		{"isbn", func(f *form) { f.ISBN = "9780306406158" }, "isbn", "is not a valid ISBN"},
+		{"email", func(f *form) { f.Email = "ann@localhost" }, "email", "is not a valid address"},
+		{"email with name", func(f *form) { f.Email = "Ann <ann@example.com>" }, "email", "is not a valid address"},
+		{"regex with comma", func(f *form) { f.Code = "en,gb" }, "", ""},
+		{"regex", func(f *form) { f.Code = "EN" }, "code", "has invalid format"},
+		{"slice entries", func(f *form) { f.Tags = []string{"new", "old"} }, "", ""},
+		{"slice max", func(f *form) { f.Tags = []string{"new", "old", "new"} }, "tags", "must be at most 2 entries"},
+		{"slice entry", func(f *form) { f.Tags = []string{"new", "used"} }, "tags", `entry "used" must be one of: new, old`},
+		{"query name", func(f *form) { f.Page = 0 }, "page", "must be at least 1"},
+		{"nested", func(f *form) { f.Address = &address{} }, "address.city", "can't be blank"},
+		{"nil nested", func(f *form) { f.Address = nil }, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := valid()
+			tt.change(&f)
+
+			err := validate.Struct(f)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+
+			fe := validate.GetFieldErrors(err)
+			if len(fe) != 1 {
+				t.Fatalf("expected single field error, got %v", err)
+			}
+			if got := fe[tt.field]; got != tt.msg {
+				t.Fatalf("expected %s %q, got %v", tt.field, tt.msg, fe)
+			}
+		})
+	}
+}
+
+func TestStructAllErrors(t *testing.T) {
+	err := validate.Struct(&form{Name: "A", Age: 1})
+
+	if !validate.IsFieldErrors(err) {
+		t.Fatalf("expected field errors, got %v", err)
+	}
+	want := "age must be at least 18, name must be at least 2 characters long, page must be at least 1"
+	if err.Error() != want {
+		t.Fatalf("expected %q, got %q", want, err.Error())
+	}
+}
+
+func TestStructNotStruct(t *testing.T) {
+	var f *form
+
+	for _, v := range []any{nil, f, "text", 1} {
+		if err := validate.Struct(v); err != nil {
+			t.Fatalf("expected %#v to be ignored, got %v", v, err)
+		}
+	}
+}
+
+// Types referring to each other must not make parsing of rules recurse forever.
+type author struct {
+	Name  string `json:"name" validate:"required"`
+	Books *shelf `json:"books"`
+}
+
+type shelf struct {
+	Author *author `json:"author"`
+	Label  string  `json:"label" validate:"max=3"`
+}
+
+type node struct {
+	Next *node `json:"next"`
+}
+
+func TestStructRecursive(t *testing.T) {
+	a := author{Name: "Ann", Books: &shelf{Label: "fiction", Author: &author{}}}
+
+	fe := validate.GetFieldErrors(validate.Struct(a))
+	want := validate.FieldErrors{
+		"books.label":       "must be at most 3 characters long",
+		"books.author.name": "can't be blank",
+	}
+	if len(fe) != len(want) {
+		t.Fatalf("expected %v, got %v", want, fe)
+	}
+	for field, msg := range want {
+		if fe[field] != msg {
+			t.Fatalf("expected %v, got %v", want, fe)
+		}
+	}
+
+	if err := validate.Struct(node{Next: &node{Next: &node{}}}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestStructMalformedTag(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{"unknown rule", struct {
+			F string `validate:"unknown"`
+		}{}},
+		{"min without number", struct {
+			F string `validate:"min=x"`
+		}{}},
+		{"invalid regex", struct {
+			F string `validate:"regex=("`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			_ = validate.Struct(tt.v)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaRange represents a single entry of the Accept header.
+type MediaRange struct {
+	Type    string
+	Subtype string
+	Q       float64
+}
+
+// ParseAccept parses the Accept header value into media ranges sorted by preference.
+// Malformed entries are skipped.
+func ParseAccept(header string) []MediaRange {
+	var ranges []MediaRange
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		typ, sub, ok := strings.Cut(mt, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, MediaRange{Type: typ, Subtype: sub, Q: q})
+	}
+
+	// More specific ranges take precedence over wildcards with the same quality.
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Q != ranges[j].Q {
+			return ranges[i].Q > ranges[j].Q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return ranges
+}
+
+// Match reports whether media type mt (e.g. application/json) is covered by the range.
+func (mr MediaRange) Match(mt string) bool {
+	typ, sub, ok := strings.Cut(mt, "/")
+	if !ok {
+		return false
+	}
+
+	if mr.Type != "*" && !strings.EqualFold(mr.Type, typ) {
+		return false
+	}
+	if mr.Subtype != "*" && !strings.EqualFold(mr.Subtype, sub) {
+		return false
+	}
+
+	return true
+}
+
+// Negotiate picks the best of the offered media types for the given Accept header.
+// The first offer wins when the header is empty. It returns an empty string when
+// none of the offers is acceptable.
+func Negotiate(header string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	if strings.TrimSpace(header) == "" {
+		return offers[0]
+	}
+
+	ranges := ParseAccept(header)
+
+	// Offers explicitly refused with q=0 can't be picked up by wildcards.
+	refused := func(offer string) bool {
+		for _, mr := range ranges {
+			if mr.Q == 0 && mr.specificity() == 2 && mr.Match(offer) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, mr := range ranges {
+		if mr.Q == 0 {
+			continue
+		}
+		for _, offer := range offers {
+			if mr.Match(offer) && !refused(offer) {
+				return offer
+			}
+		}
+	}
+
+	return ""
+}
+
+// private
+
+func (mr MediaRange) specificity() int {
+	switch {
+	case mr.Type == "*":
+		return 0
+	case mr.Subtype == "*":
+		return 1
+	}
+	return 2
+}
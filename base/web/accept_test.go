@@ -0,0 +1,76 @@
+package web_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/web"
+)
+
+func TestParseAccept(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []web.MediaRange
+	}{
+		{"", nil},
+		{"application/json", []web.MediaRange{{Type: "application", Subtype: "json", Q: 1}}},
+		{
+			"*/*;q=0.1, text/*;q=0.5, text/csv;q=0.5, application/xml",
+			[]web.MediaRange{
+				{Type: "application", Subtype: "xml", Q: 1},
+				{Type: "text", Subtype: "csv", Q: 0.5},
+				{Type: "text", Subtype: "*", Q: 0.5},
+				{Type: "*", Subtype: "*", Q: 0.1},
+			},
+		},
+		{
+			"text/csv;q=2, application/xml;q=x, garbage, application/json;q=0",
+			[]web.MediaRange{{Type: "application", Subtype: "json", Q: 0}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got := web.ParseAccept(tt.header)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "text/csv"}
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"no header", "", "application/json"},
+		{"exact", "text/csv", "text/csv"},
+		{"case insensitive", "Application/XML", "application/xml"},
+		{"any", "*/*", "application/json"},
+		{"type wildcard", "text/*", "text/csv"},
+		{"higher quality wins", "application/json;q=0.5, text/csv;q=0.9", "text/csv"},
+		{"order breaks ties", "application/xml, text/csv", "application/xml"},
+		{"specific beats wildcard", "*/*;q=0.8, text/csv;q=0.8", "text/csv"},
+		{"wildcard fallback", "image/png, */*;q=0.1", "application/json"},
+		{"refused offer", "application/json;q=0, */*", "application/xml"},
+		{"refused all", "*/*;q=0", ""},
+		{"none acceptable", "image/png", ""},
+		{"malformed only", "garbage", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := web.Negotiate(tt.header, offers); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := web.Negotiate("*/*", nil); got != "" {
+		t.Fatalf("expected no offer to be picked, got %q", got)
+	}
+}
@@ -2,15 +2,21 @@ package web
 
 import (
 	"context"
+	"expvar"
 	"net/http"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
+// unhandledErrors counts errors which made it past all the middleware.
+var unhandledErrors = expvar.NewInt("unhandled_errors")
+
 // Handler represents type responsible for handling http request.
 // It extends signature of http.HandlerFunc with support for context.Context.
 type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) error
@@ -18,26 +24,99 @@ type Handler func(ctx context.Context, w http.ResponseWriter, r *http.Request) e
 type App struct {
 	mux      *httptreemux.ContextMux
 	shutdown chan os.Signal
+	logger   *zap.SugaredLogger
 	mw       []Middleware
+	routes   []*Route
+	encoders []Encoder
+	// unmatched holds groups answering requests to their paths which match no route.
+	unmatched []*Group
 }
 
-func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
-	return &App{
+// NewApp constructs the App. Requests to unknown paths and methods go through the
+// application level middleware as well, so they are answered with RouteError
+// instead of the plain text defaults of the router. Responses are sent as JSON
+// unless other encoders are registered with RegisterEncoder.
+func NewApp(shutdown chan os.Signal, logger *zap.SugaredLogger, mw ...Middleware) *App {
+	a := App{
 		mux:      httptreemux.NewContextMux(),
 		shutdown: shutdown,
+		logger:   logger,
 		mw:       mw,
+		encoders: []Encoder{JSON{}},
+	}
+
+	a.mux.NotFoundHandler = func(w http.ResponseWriter, r *http.Request) {
+		a.httpHandler(a.unmatchedHandler(r.URL.Path, notFound))(w, r)
+	}
+	a.mux.MethodNotAllowedHandler = func(w http.ResponseWriter, r *http.Request, methods map[string]httptreemux.HandlerFunc) {
+		a.httpHandler(a.unmatchedHandler(r.URL.Path, methodNotAllowed(methods)))(w, r)
 	}
+
+	return &a
 }
 
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	a.mux.ServeHTTP(w, r)
 }
 
+// Shutdown asks the service to shut down gracefully. Requests made while
+// the shutdown is already pending don't block.
 func (a *App) Shutdown() {
-	a.shutdown <- syscall.SIGTERM
+	select {
+	case a.shutdown <- syscall.SIGTERM:
+	default:
+	}
+}
+
+// Handle registers the handler for the method and path, prefixed with the version if
+// it's not empty. Handler specific middleware run after the application level ones.
+// The registered route is returned, so it can be documented with Describe.
+func (a *App) Handle(method string, version string, path string, handler Handler, mw ...Middleware) *Route {
+	// Extend path with version if necessary.
+	if version != "" {
+		path = "/" + version + path
+	}
+
+	return a.handle(method, path, handler, mw)
+}
+
+// RegisterEncoder makes Response able to send bodies in the formats of the encoders,
+// when they are accepted by the client. An encoder replaces the one registered before
+// for the same media type. The first encoder, JSON unless replaced, is the default used
+// when the client accepts any format.
+func (a *App) RegisterEncoder(encoders ...Encoder) {
+	for _, enc := range encoders {
+		i := 0
+		for i < len(a.encoders) && a.encoders[i].MediaType() != enc.MediaType() {
+			i++
+		}
+		if i == len(a.encoders) {
+			a.encoders = append(a.encoders, enc)
+			continue
+		}
+		a.encoders[i] = enc
+	}
+}
+
+// Group returns the Group of routes sharing the path prefix and middleware.
+func (a *App) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{app: a, prefix: prefix, mw: mw}
+}
+
+// Routes lists all the registered routes in the order of registration.
+func (a *App) Routes() []Route {
+	routes := make([]Route, len(a.routes))
+	for i, rt := range a.routes {
+		routes[i] = *rt
+	}
+	return routes
 }
 
-func (a *App) Handle(method string, version string, path string, handler Handler, mw ...Middleware) {
+// private
+
+func (a *App) handle(method string, path string, handler Handler, mw []Middleware) *Route {
+	route := newRoute(method, path, handler, a.mw, mw)
+	a.routes = append(a.routes, route)
 
 	// Wrap handler specific middleware.
 	handler = wrapMiddleware(mw, handler)
@@ -45,9 +124,36 @@ func (a *App) Handle(method string, version string, path string, handler Handler
 	// Wrap handler with application level middleware.
 	handler = wrapMiddleware(a.mw, handler)
 
-	// Prepare the function to execute for each request.
-	// This anonymous func wraps Handler with proper error handling.
-	h := func(w http.ResponseWriter, r *http.Request) {
+	// Register handler func with the requested method and path.
+	a.mux.Handle(method, path, a.httpHandler(handler))
+
+	return route
+}
+
+// unmatchedHandler wraps the handler of requests matching no route with the middleware
+// of the group handling the path, the one with the longest prefix, if there is any.
+func (a *App) unmatchedHandler(path string, handler Handler) Handler {
+	var group *Group
+	for _, g := range a.unmatched {
+		if path != g.prefix && !strings.HasPrefix(path, g.prefix+"/") {
+			continue
+		}
+		if group == nil || len(g.prefix) > len(group.prefix) {
+			group = g
+		}
+	}
+
+	if group != nil {
+		handler = wrapMiddleware(group.mw, handler)
+	}
+
+	return wrapMiddleware(a.mw, handler)
+}
+
+// httpHandler prepares the function to execute for each request.
+// It wraps Handler with proper error handling.
+func (a *App) httpHandler(handler Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 
 		// Pull context out of the *http.Request and extend it with
 		// custom data expected by other middleware.
@@ -56,22 +162,76 @@ func (a *App) Handle(method string, version string, path string, handler Handler
 		// - start time of the request
 		// - response code
 		// - trace ID
+		// - client IP and user agent
+		// - accepted media types
 		ctx := r.Context()
 
 		ctx = context.WithValue(ctx, key, &CtxValues{
-			TraceID: uuid.Must(uuid.NewRandom()).String(),
-			Now:     time.Now().UTC(),
+			TraceID:   uuid.Must(uuid.NewRandom()).String(),
+			Now:       time.Now().UTC(),
+			ClientIP:  ClientIP(r),
+			UserAgent: r.UserAgent(),
+			Accept:    r.Header.Get("Accept"),
+			encoders:  a.encoders,
 		})
 
-		if err := handler(ctx, w, r); err != nil {
-			a.Shutdown()
-			return
+		// Helpers given only the request, like Decode, read the values from its context.
+		r = r.WithContext(ctx)
+
+		tw := &trackingWriter{ResponseWriter: w}
+
+		if err := handler(ctx, tw, r); err != nil {
+			a.handleError(ctx, tw, err)
 		}
 	}
-	// Extend path with version if necessary.
-	if version != "" {
-		path = "/" + version + path
+}
+
+// handleError applies the error policy to errors which made it past all the middleware.
+// Only errors created with NewShutdownError shut the service down, as they mean its
+// integrity is at risk. Every error is logged and counted, and the client gets 500
+// unless the response has already been started.
+func (a *App) handleError(ctx context.Context, w *trackingWriter, err error) {
+	unhandledErrors.Add(1)
+
+	shutdown := IsShutdownError(err)
+	a.logger.Errorw("unhandled error", "trace_id", GetTraceID(ctx), "shutdown", shutdown, "error", err)
+
+	if !w.wroteHeader {
+		_ = SetStatusCode(ctx, http.StatusInternalServerError)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
-	// Register handler func with the requested method and path.
-	a.mux.Handle(method, path, h)
+
+	if shutdown {
+		a.Shutdown()
+	}
+}
+
+// trackingWriter remembers whether the response has been started, so the error
+// policy knows if it's still possible to send the status code.
+type trackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (tw *trackingWriter) WriteHeader(statusCode int) {
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (tw *trackingWriter) Write(b []byte) (int, error) {
+	tw.wroteHeader = true
+	return tw.ResponseWriter.Write(b)
+}
+
+// Flush keeps streamed responses working through the wrapper.
+func (tw *trackingWriter) Flush() {
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok {
+		tw.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Unwrap gives http.ResponseController access to the original writer.
+func (tw *trackingWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
 }
@@ -15,6 +15,13 @@ type CtxValues struct {
 	TraceID    string
 	Now        time.Time
 	StatusCode int
+	ClientIP   string
+	UserAgent  string
+	BodyLimit  int64
+	Accept     string
+
+	// encoders are registered on the App, Response picks one of them for the Accept header.
+	encoders []Encoder
 }
 
 func GetCtxValues(ctx context.Context) (*CtxValues, error) {
@@ -33,6 +40,22 @@ func GetTraceID(ctx context.Context) string {
 	return v.TraceID
 }
 
+func GetClientIP(ctx context.Context) string {
+	v, ok := ctx.Value(key).(*CtxValues)
+	if !ok {
+		return ""
+	}
+	return v.ClientIP
+}
+
+func GetUserAgent(ctx context.Context) string {
+	v, ok := ctx.Value(key).(*CtxValues)
+	if !ok {
+		return ""
+	}
+	return v.UserAgent
+}
+
 func SetStatusCode(ctx context.Context, statusCode int) error {
 	v, ok := ctx.Value(key).(*CtxValues)
 	if !ok {
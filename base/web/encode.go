@@ -0,0 +1,262 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encoder writes response bodies in a single format. Encoders are registered on App
+// with RegisterEncoder and picked by Response according to the Accept header.
+type Encoder interface {
+	MediaType() string
+	Encode(w io.Writer, data any) error
+}
+
+// Lister is implemented by values wrapping the list they present, e.g. pages of listings.
+// Encoders which handle only lists, like CSV, encode the list alone.
+type Lister interface {
+	List() any
+}
+
+// ErrUnsupportedValue is returned by encoders which can't represent the value,
+// e.g. CSV given a single object. Response moves on to the next acceptable encoder then.
+var ErrUnsupportedValue = errors.New("value is not supported by the format")
+
+// NotAcceptableError reports responses which can't be sent in any of the formats
+// accepted by the client.
+type NotAcceptableError struct {
+	Offers []string
+}
+
+func (ne *NotAcceptableError) Error() string {
+	return fmt.Sprintf("none of the accepted media types can be produced, available: %s", strings.Join(ne.Offers, ", "))
+}
+
+func IsNotAcceptableError(err error) bool {
+	var ne *NotAcceptableError
+	return errors.As(err, &ne)
+}
+
+// GetNotAcceptableError returns the NotAcceptableError wrapped by err.
+func GetNotAcceptableError(err error) *NotAcceptableError {
+	var ne *NotAcceptableError
+	if !errors.As(err, &ne) {
+		return nil
+	}
+	return ne
+}
+
+// MediaTypes returns media types of the encoders available to the request, the default first.
+// Handlers producing their own formats offer them next to these.
+func MediaTypes(ctx context.Context) []string {
+	v, ok := ctx.Value(key).(*CtxValues)
+	if !ok {
+		return []string{JSON{}.MediaType()}
+	}
+
+	offers := make([]string, len(v.encoders))
+	for i, enc := range v.encoders {
+		offers[i] = enc.MediaType()
+	}
+	return offers
+}
+
+// JSON is the default encoder.
+type JSON struct{}
+
+func (JSON) MediaType() string { return "application/json" }
+
+func (JSON) Encode(w io.Writer, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// private
+
+// encode writes data with the best of the encoders for the Accept header. Encoders
+// rejecting the value with ErrUnsupportedValue are skipped. Error responses fall back
+// to the default encoder when none of the accepted ones fits, as the client should
+// learn what went wrong anyway.
+func encode(v *CtxValues, statusCode int, data any) (string, []byte, error) {
+	encoders := v.encoders
+	if len(encoders) == 0 {
+		encoders = []Encoder{JSON{}}
+	}
+
+	offers := make([]string, len(encoders))
+	for i, enc := range encoders {
+		offers[i] = enc.MediaType()
+	}
+
+	candidates := offers
+	for {
+		mt := Negotiate(v.Accept, candidates)
+		if mt == "" {
+			break
+		}
+
+		enc := encoders[indexOf(offers, mt)]
+
+		var buf bytes.Buffer
+		err := enc.Encode(&buf, data)
+		switch {
+		case err == nil:
+			return mt, buf.Bytes(), nil
+		case !errors.Is(err, ErrUnsupportedValue):
+			return "", nil, err
+		}
+
+		candidates = without(candidates, mt)
+	}
+
+	if statusCode >= 400 {
+		var buf bytes.Buffer
+		if err := encoders[0].Encode(&buf, data); err != nil {
+			return "", nil, err
+		}
+		return offers[0], buf.Bytes(), nil
+	}
+
+	return "", nil, &NotAcceptableError{Offers: offers}
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func without(list []string, s string) []string {
+	result := make([]string, 0, len(list))
+	for _, v := range list {
+		if v != s {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// node is the value decoded from JSON with the order of object members kept, so every
+// format presents data the same way JSON does: with the same names, omitted fields and
+// custom marshalers.
+type node struct {
+	kind    nodeKind
+	scalar  any // string, json.Number or bool
+	members []member
+	items   []node
+}
+
+type member struct {
+	name  string
+	value node
+}
+
+type nodeKind int
+
+const (
+	nullNode nodeKind = iota
+	scalarNode
+	objectNode
+	arrayNode
+)
+
+// toNode marshals data to JSON and decodes it back into node.
+func toNode(data any) (node, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return node{}, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	return decodeNode(dec)
+}
+
+func decodeNode(dec *json.Decoder) (node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return node{}, err
+	}
+
+	switch t := tok.(type) {
+	case nil:
+		return node{kind: nullNode}, nil
+
+	case json.Delim:
+		if t == '[' {
+			n := node{kind: arrayNode, items: []node{}}
+			for dec.More() {
+				item, err := decodeNode(dec)
+				if err != nil {
+					return node{}, err
+				}
+				n.items = append(n.items, item)
+			}
+			_, err = dec.Token()
+			return n, err
+		}
+
+		n := node{kind: objectNode, members: []member{}}
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return node{}, err
+			}
+			value, err := decodeNode(dec)
+			if err != nil {
+				return node{}, err
+			}
+			n.members = append(n.members, member{name: name.(string), value: value})
+		}
+		_, err = dec.Token()
+		return n, err
+	}
+
+	return node{kind: scalarNode, scalar: tok}, nil
+}
+
+// MarshalJSON writes the node back as JSON, keeping the order of object members.
+func (n node) MarshalJSON() ([]byte, error) {
+	switch n.kind {
+	case scalarNode:
+		return json.Marshal(n.scalar)
+	case arrayNode:
+		return json.Marshal(n.items)
+	case objectNode:
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for i, m := range n.members {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			name, err := json.Marshal(m.name)
+			if err != nil {
+				return nil, err
+			}
+			value, err := json.Marshal(m.value)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(name)
+			buf.WriteByte(':')
+			buf.Write(value)
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	}
+
+	return []byte("null"), nil
+}
@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CSV encodes lists, one record per entry. Lists of objects get the header made of
+// member names, in the order they first appear. Nested objects and arrays are written
+// as JSON within the cell. Values implementing Lister are encoded as their list, other
+// values than lists are rejected with ErrUnsupportedValue.
+type CSV struct{}
+
+func (CSV) MediaType() string { return "text/csv" }
+
+func (CSV) Encode(w io.Writer, data any) error {
+	if l, ok := data.(Lister); ok {
+		data = l.List()
+	}
+
+	n, err := toNode(data)
+	if err != nil {
+		return err
+	}
+	if n.kind != arrayNode {
+		return ErrUnsupportedValue
+	}
+
+	cw := csv.NewWriter(w)
+
+	var header []string
+	columns := make(map[string]int)
+	objects := false
+
+	for _, item := range n.items {
+		if item.kind != objectNode {
+			continue
+		}
+		objects = true
+		for _, m := range item.members {
+			if _, ok := columns[m.name]; !ok {
+				columns[m.name] = len(header)
+				header = append(header, m.name)
+			}
+		}
+	}
+
+	if objects {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range n.items {
+		var record []string
+
+		switch {
+		case objects && item.kind == objectNode:
+			record = make([]string, len(header))
+			for _, m := range item.members {
+				if record[columns[m.name]], err = csvCell(m.value); err != nil {
+					return err
+				}
+			}
+		case objects:
+			// Entries which aren't objects can't be spread over the columns.
+			return ErrUnsupportedValue
+		default:
+			cell, err := csvCell(item)
+			if err != nil {
+				return err
+			}
+			record = []string{cell}
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// private
+
+func csvCell(n node) (string, error) {
+	switch n.kind {
+	case nullNode:
+		return "", nil
+	case scalarNode:
+		return fmt.Sprint(n.scalar), nil
+	}
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// MessagePack encodes data in the MessagePack format, https://msgpack.org. Objects
+// become maps keyed by JSON names, integers use the smallest encoding able to hold
+// them and other numbers are sent as float 64.
+type MessagePack struct{}
+
+func (MessagePack) MediaType() string { return "application/msgpack" }
+
+func (MessagePack) Encode(w io.Writer, data any) error {
+	n, err := toNode(data)
+	if err != nil {
+		return err
+	}
+
+	b, err := appendMsgpack(nil, n)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// private
+
+func appendMsgpack(b []byte, n node) ([]byte, error) {
+	var err error
+
+	switch n.kind {
+	case nullNode:
+		return append(b, 0xc0), nil
+
+	case arrayNode:
+		b = appendMsgpackHeader(b, len(n.items), 0x90, 0xdc)
+		for _, item := range n.items {
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+
+	case objectNode:
+		b = appendMsgpackHeader(b, len(n.members), 0x80, 0xde)
+		for _, m := range n.members {
+			b = appendMsgpackString(b, m.name)
+			if b, err = appendMsgpack(b, m.value); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	switch v := n.scalar.(type) {
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+
+	case string:
+		return appendMsgpackString(b, v), nil
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return appendMsgpackInt(b, i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(f)), nil
+	}
+
+	return nil, fmt.Errorf("msgpack: unexpected value of type %T", n.scalar)
+}
+
+// appendMsgpackHeader writes the size of arrays and maps, fix is the prefix of
+// the fixarray or fixmap, wide the prefix of the 16 bit variant.
+func appendMsgpackHeader(b []byte, size int, fix byte, wide byte) []byte {
+	switch {
+	case size < 16:
+		return append(b, fix|byte(size))
+	case size <= math.MaxUint16:
+		b = append(b, wide)
+		return binary.BigEndian.AppendUint16(b, uint16(size))
+	}
+	b = append(b, wide+1)
+	return binary.BigEndian.AppendUint32(b, uint32(size))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8:
+		return append(b, byte(i))
+	case i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		b = append(b, 0xd1)
+		return binary.BigEndian.AppendUint16(b, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		b = append(b, 0xd2)
+		return binary.BigEndian.AppendUint32(b, uint32(i))
+	}
+	b = append(b, 0xd3)
+	return binary.BigEndian.AppendUint64(b, uint64(i))
+}
@@ -0,0 +1,200 @@
+package web_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	"go.uber.org/zap"
+)
+
+type item struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// page wraps the list the way paged listings do.
+type page struct {
+	Page  int    `json:"page"`
+	Items []item `json:"items"`
+}
+
+func (p page) List() any { return p.Items }
+
+func TestResponseNegotiation(t *testing.T) {
+	// capture keeps the error of the route, which is turned into the response by
+	// the errors middleware of the service otherwise.
+	var routeErr error
+	capture := func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			routeErr = handler(ctx, w, r)
+			return nil
+		}
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar(), capture)
+	app.RegisterEncoder(web.XML{}, web.CSV{}, web.MessagePack{})
+
+	list := []item{{ID: 1, Name: "a,b", Tags: []string{"x"}}, {ID: 2, Name: "c"}}
+	app.Handle(http.MethodGet, "", "/list", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, list)
+	})
+	app.Handle(http.MethodGet, "", "/page", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, page{Page: 1, Items: list})
+	})
+	app.Handle(http.MethodGet, "", "/one", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, item{ID: 1, Name: "<a>"})
+	})
+	app.Handle(http.MethodGet, "", "/fail", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusBadRequest, struct {
+			Error string `json:"error"`
+		}{"bad"})
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		accept      string
+		contentType string
+		body        string
+		notAccepted bool
+	}{
+		{
+			name:        "default",
+			path:        "/list",
+			contentType: "application/json",
+			body:        `[{"id":1,"name":"a,b","tags":["x"]},{"id":2,"name":"c"}]`,
+		},
+		{
+			name:        "csv",
+			path:        "/list",
+			accept:      "text/csv",
+			contentType: "text/csv",
+			body:        "id,name,tags\n1,\"a,b\",\"[\"\"x\"\"]\"\n2,c,\n",
+		},
+		{
+			name:        "page as json",
+			path:        "/page",
+			contentType: "application/json",
+			body:        `{"page":1,"items":[{"id":1,"name":"a,b","tags":["x"]},{"id":2,"name":"c"}]}`,
+		},
+		{
+			name:        "page as csv",
+			path:        "/page",
+			accept:      "text/csv",
+			contentType: "text/csv",
+			body:        "id,name,tags\n1,\"a,b\",\"[\"\"x\"\"]\"\n2,c,\n",
+		},
+		{
+			name:        "xml preferred by quality",
+			path:        "/one",
+			accept:      "application/json;q=0.5, application/xml",
+			contentType: "application/xml",
+			body:        "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><id>1</id><name>&lt;a&gt;</name></response>",
+		},
+		{
+			name:        "unsupported value falls back to next acceptable",
+			path:        "/one",
+			accept:      "text/csv, */*;q=0.1",
+			contentType: "application/json",
+			body:        `{"id":1,"name":"\u003ca\u003e"}`,
+		},
+		{
+			name:        "unsupported value without fallback",
+			path:        "/one",
+			accept:      "text/csv",
+			notAccepted: true,
+		},
+		{
+			name:        "not acceptable",
+			path:        "/list",
+			accept:      "image/png",
+			notAccepted: true,
+		},
+		{
+			name:        "errors fall back to default",
+			path:        "/fail",
+			accept:      "image/png",
+			contentType: "application/json",
+			body:        `{"error":"bad"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routeErr = nil
+
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if tt.notAccepted {
+				ne := web.GetNotAcceptableError(routeErr)
+				if ne == nil {
+					t.Fatalf("expected not acceptable error, got %v", routeErr)
+				}
+				want := []string{"application/json", "application/xml", "text/csv", "application/msgpack"}
+				if !reflect.DeepEqual(ne.Offers, want) {
+					t.Fatalf("expected offers %v, got %v", want, ne.Offers)
+				}
+				if w.Body.Len() != 0 {
+					t.Fatalf("expected nothing to be sent, got %q", w.Body)
+				}
+				return
+			}
+
+			if routeErr != nil {
+				t.Fatalf("expected no error, got %v", routeErr)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Fatalf("expected content type %q, got %q", tt.contentType, got)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Fatalf("expected Vary: Accept, got %q", got)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Fatalf("expected body %q, got %q", tt.body, got)
+			}
+		})
+	}
+}
+
+func TestResponseSingleEncoder(t *testing.T) {
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar())
+	app.Handle(http.MethodGet, "", "/one", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, item{ID: 1})
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/one", nil))
+
+	if got := w.Header().Get("Vary"); got != "" {
+		t.Fatalf("expected no Vary header with a single encoder, got %q", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Fatalf("expected application/json, got %q", got)
+	}
+}
+
+func TestMessagePack(t *testing.T) {
+	var buf bytes.Buffer
+	err := web.MessagePack{}.Encode(&buf, map[string]any{"a": 1, "b": []string{"x"}, "c": nil, "d": true})
+	if err != nil {
+		t.Fatalf("encoding: %v", err)
+	}
+
+	// fixmap of 4: "a" 1, "b" ["x"], "c" nil, "d" true.
+	want := []byte{0x84, 0xa1, 'a', 0x01, 0xa1, 'b', 0x91, 0xa1, 'x', 0xa1, 'c', 0xc0, 0xa1, 'd', 0xc3}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("expected % x, got % x", want, buf.Bytes())
+	}
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// XML encodes data as the document rooted at the response element. Object members
+// become elements named after their JSON names, entries of arrays become item elements.
+// Members with names which aren't valid XML names, e.g. keys of maps, become entry
+// elements with the name kept in the key attribute.
+type XML struct{}
+
+func (XML) MediaType() string { return "application/xml" }
+
+func (XML) Encode(w io.Writer, data any) error {
+	n, err := toNode(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	err = writeXML(enc, xml.StartElement{Name: xml.Name{Local: "response"}}, n)
+	if err != nil {
+		return err
+	}
+
+	return enc.Flush()
+}
+
+// private
+
+func writeXML(enc *xml.Encoder, start xml.StartElement, n node) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch n.kind {
+	case scalarNode:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(n.scalar))); err != nil {
+			return err
+		}
+
+	case arrayNode:
+		for _, item := range n.items {
+			if err := writeXML(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+
+	case objectNode:
+		for _, m := range n.members {
+			el := xml.StartElement{Name: xml.Name{Local: m.name}}
+			if !isXMLName(m.name) {
+				el = xml.StartElement{
+					Name: xml.Name{Local: "entry"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: m.name}},
+				}
+			}
+			if err := writeXML(enc, el, m.value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// isXMLName reports whether s can be used as the element name. It's stricter than
+// the XML specification, which is fine for names produced by encoding/json.
+func isXMLName(s string) bool {
+	if s == "" || len(s) >= 3 && (s[0]|0x20) == 'x' && (s[1]|0x20) == 'm' && (s[2]|0x20) == 'l' {
+		return false
+	}
+
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r == '-' || r == '.' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
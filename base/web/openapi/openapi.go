@@ -0,0 +1,250 @@
+// Package openapi builds the OpenAPI 3.1 description of the routes registered in web.App.
+// Schemas are generated by reflecting over the types given in web.Doc of every route.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/tchorzewski1991/bds/base/web"
+)
+
+// Version is the version of the OpenAPI specification the documents conform to.
+const Version = "3.1.0"
+
+// Config holds what the document needs beyond the routes.
+type Config struct {
+	Title       string
+	Version     string
+	Description string
+
+	// Prefix limits the document to routes under it, e.g. /v1.
+	Prefix string
+
+	// Error is an example of the body of error responses, described as the default
+	// response of every operation.
+	Error any
+
+	// SecuritySchemes are accepted alternatively by routes reported by Secured.
+	SecuritySchemes map[string]SecurityScheme
+	Secured         func(rt web.Route) bool
+}
+
+// Document is the root of the OpenAPI description.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds operations of the path keyed by the lowercase method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Description  string `json:"description,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+}
+
+// New builds the document describing routes under cfg.Prefix. Hidden routes and
+// routes without web.Doc are left out.
+func New(cfg Config, routes []web.Route) *Document {
+	doc := Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       cfg.Title,
+			Version:     cfg.Version,
+			Description: cfg.Description,
+		},
+		Paths: make(map[string]PathItem),
+		Components: Components{
+			SecuritySchemes: cfg.SecuritySchemes,
+		},
+	}
+
+	schemas := newRegistry()
+	ids := make(map[string]int)
+
+	for _, rt := range routes {
+		if rt.Doc == nil || rt.Doc.Hidden || !strings.HasPrefix(rt.Pattern, cfg.Prefix) {
+			continue
+		}
+
+		path, params := pathParams(rt.Pattern)
+
+		op := Operation{
+			OperationID: operationID(rt, ids),
+			Summary:     rt.Doc.Summary,
+			Description: rt.Doc.Description,
+			Tags:        rt.Doc.Tags,
+			Parameters:  params,
+			Responses:   make(map[string]Response),
+		}
+		if len(op.Tags) == 0 {
+			op.Tags = []string{defaultTag(strings.TrimPrefix(rt.Pattern, cfg.Prefix))}
+		}
+
+		if rt.Doc.Query != nil {
+			op.Parameters = append(op.Parameters, schemas.queryParams(rt.Doc.Query)...)
+		}
+
+		if rt.Doc.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  schemas.content(rt.Doc.Request),
+			}
+		}
+
+		for status, body := range rt.Doc.Responses {
+			resp := Response{Description: http.StatusText(status)}
+			if body != nil {
+				resp.Content = schemas.content(body)
+			}
+			op.Responses[fmt.Sprint(status)] = resp
+		}
+
+		if cfg.Error != nil {
+			op.Responses["default"] = Response{
+				Description: "Error",
+				Content:     schemas.content(cfg.Error),
+			}
+		}
+
+		if cfg.Secured != nil && cfg.Secured(rt) {
+			names := make([]string, 0, len(cfg.SecuritySchemes))
+			for name := range cfg.SecuritySchemes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				op.Security = append(op.Security, map[string][]string{name: {}})
+			}
+		}
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.Method)] = &op
+	}
+
+	doc.Components.Schemas = schemas.components
+
+	return &doc
+}
+
+// private
+
+// pathParams converts the router pattern, e.g. /books/:id, into the OpenAPI path
+// template, e.g. /books/{id}, together with its parameters.
+func pathParams(pattern string) (string, []Parameter) {
+	var params []Parameter
+
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if seg == "" || (seg[0] != ':' && seg[0] != '*') {
+			continue
+		}
+
+		// Segments can have static suffix, like :id.json, which is kept outside of the template.
+		name, suffix := seg[1:], ""
+		if j := strings.IndexAny(name, "."); j >= 0 {
+			name, suffix = name[:j], name[j:]
+		}
+
+		segments[i] = "{" + name + "}" + suffix
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	return strings.Join(segments, "/"), params
+}
+
+// operationID names the operation after the handler, e.g. bookHandler.Query becomes
+// bookQuery. Names are made unique with a number suffix.
+func operationID(rt web.Route, ids map[string]int) string {
+	name := rt.Handler
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+
+	receiver, method, ok := strings.Cut(name, ".")
+	if ok {
+		name = strings.TrimSuffix(receiver, "Handler") + method
+	}
+
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	name = string(runes)
+
+	ids[name]++
+	if n := ids[name]; n > 1 {
+		name = fmt.Sprintf("%s%d", name, n)
+	}
+
+	return name
+}
+
+// defaultTag groups operations by the first segment of the path.
+func defaultTag(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if seg == "" {
+		return "default"
+	}
+	return seg
+}
@@ -0,0 +1,327 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/tchorzewski1991/bds/base/web"
+)
+
+// Schema is the subset of JSON Schema used to describe the types of the API.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *int               `json:"minimum,omitempty"`
+	Maximum              *int               `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+}
+
+// registry turns named struct types into components, so every type is described once.
+type registry struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newRegistry() *registry {
+	return &registry{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(web.Raw(""))
+)
+
+// content describes the body given as an example value, or as web.Raw media type.
+func (r *registry) content(body any) map[string]MediaType {
+	if raw, ok := body.(web.Raw); ok {
+		return map[string]MediaType{
+			string(raw): {Schema: &Schema{Type: "string", ContentEncoding: "binary"}},
+		}
+	}
+
+	return map[string]MediaType{
+		"application/json": {Schema: r.schema(reflect.TypeOf(body))},
+	}
+}
+
+// queryParams describes the fields of the struct bound to query params by web.DecodeQuery.
+func (r *registry) queryParams(query any) []Parameter {
+	t := reflect.TypeOf(query)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var params []Parameter
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name := f.Tag.Get("query")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		required, schema := r.field(f)
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: required,
+			Schema:   schema,
+		})
+	}
+
+	return params
+}
+
+func (r *registry) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawType:
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := r.schema(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+		}
+		if typ, ok := s.Type.(string); ok {
+			s.Type = []string{typ, "null"}
+		}
+		return s
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json sends bytes as base64 string.
+			return &Schema{Type: "string", ContentEncoding: "base64"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		return r.structSchema(t)
+	}
+
+	// Interfaces can hold anything.
+	return &Schema{}
+}
+
+// structSchema describes named structs as components referenced by name, anonymous
+// structs are described inline.
+func (r *registry) structSchema(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return r.object(t)
+	}
+
+	name, ok := r.names[t]
+	if !ok {
+		name = r.componentName(t)
+		r.names[t] = name
+
+		// The placeholder lets recursive types refer to themselves.
+		r.components[name] = &Schema{}
+		*r.components[name] = *r.object(t)
+	}
+
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object describes properties of the struct the way encoding/json marshals them.
+// Fields of types declaring validation rules are required when tagged as required,
+// as they are sent by clients. Otherwise all fields but the ones with omitempty
+// are required, as they are always sent by the API.
+func (r *registry) object(t reflect.Type) *Schema {
+	s := Schema{Type: "object", Properties: make(map[string]*Schema)}
+	input := hasRules(t)
+
+	r.properties(t, &s, input)
+
+	return &s
+}
+
+func (r *registry) properties(t reflect.Type, s *Schema, input bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		// Fields of embedded structs are promoted, unless the embedded struct is named in JSON.
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				r.properties(ft, s, input)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		required, schema := r.field(f)
+		s.Properties[name] = schema
+
+		omitempty := strings.Contains(","+opts+",", ",omitempty,")
+		if (input && required) || (!input && !omitempty) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// field describes the field together with the constraints of its validate tag.
+func (r *registry) field(f reflect.StructField) (bool, *Schema) {
+	schema := r.schema(f.Type)
+
+	tag := f.Tag.Get("validate")
+	if tag == "" {
+		return false, schema
+	}
+
+	target := schema
+	switch {
+	case schema.Ref != "" || schema.AnyOf != nil:
+		// Constraints of references would change the shared component.
+		target = nil
+	case schema.Items != nil && schema.Items.Ref == "":
+		// Rules other than min and max apply to every entry, see validate.Struct.
+		target = schema.Items
+	}
+
+	var required bool
+
+	for tag != "" {
+		var token string
+		if strings.HasPrefix(tag, "regex=") {
+			token, tag = tag, ""
+		} else {
+			token, tag, _ = strings.Cut(tag, ",")
+		}
+
+		rule, arg, _ := strings.Cut(token, "=")
+		if rule == "required" {
+			required = true
+			continue
+		}
+		if target == nil {
+			continue
+		}
+
+		switch rule {
+		case "min", "max":
+			limit(schema, rule, arg)
+		case "enum":
+			target.Enum = strings.Fields(arg)
+		case "email":
+			target.Format = "email"
+		case "regex":
+			target.Pattern = arg
+		}
+	}
+
+	return required, schema
+}
+
+// limit translates min and max rules into the keyword matching the type.
+func limit(s *Schema, rule string, arg string) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return
+	}
+
+	typ, _ := s.Type.(string)
+	if types, ok := s.Type.([]string); ok {
+		typ = types[0]
+	}
+
+	switch {
+	case typ == "string" && rule == "min":
+		s.MinLength = &n
+	case typ == "string":
+		s.MaxLength = &n
+	case typ == "array" && rule == "min":
+		s.MinItems = &n
+	case typ == "array":
+		s.MaxItems = &n
+	case rule == "min":
+		s.Minimum = &n
+	default:
+		s.Maximum = &n
+	}
+}
+
+// componentName names the component after the package and the type, e.g. book.NewBook.
+// Unexported types are capitalized, as they are still public in the API.
+func (r *registry) componentName(t reflect.Type) string {
+	runes := []rune(t.Name())
+	runes[0] = unicode.ToUpper(runes[0])
+
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+
+	name := pkg + "." + string(runes)
+	base := name
+	for i := 2; ; i++ {
+		if _, taken := r.components[name]; !taken {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+}
+
+// hasRules reports whether any field of the struct declares validation rules.
+func hasRules(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("validate") != "" {
+			return true
+		}
+	}
+	return false
+}
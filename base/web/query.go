@@ -0,0 +1,134 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DecodeQuery reads the query string of the request into dest, which must be a pointer
+// to struct, and checks it with Validate. Fields are bound to params by the query tag,
+// e.g. `query:"page"`, fields without the tag are left untouched. Strings, booleans,
+// numbers, time.Time given in RFC 3339 and pointers to them are supported, slices of
+// them take every occurrence of the param. Params which can't be converted are reported
+// as FieldErrors together with validation failures of other fields.
+func DecodeQuery(r *http.Request, dest any) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("web: DecodeQuery needs a pointer to struct, got %T", dest))
+	}
+	rv = rv.Elem()
+
+	query := r.URL.Query()
+	fe := make(FieldErrors)
+
+	for i := 0; i < rv.NumField(); i++ {
+		f := rv.Type().Field(i)
+
+		name := f.Tag.Get("query")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		values, ok := query[name]
+		if !ok {
+			continue
+		}
+
+		if msg, ok := setQueryField(rv.Field(i), values); !ok {
+			fe[name] = msg
+		}
+	}
+
+	err := Validate(dest)
+	if err != nil {
+		// Conversion failures take precedence, as the field holds no value anyway.
+		for field, msg := range GetFieldErrors(err) {
+			if _, ok := fe[field]; !ok {
+				fe[field] = msg
+			}
+		}
+	}
+
+	if len(fe) == 0 {
+		return nil
+	}
+
+	return fe
+}
+
+// private
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func setQueryField(fv reflect.Value, values []string) (string, bool) {
+	if fv.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, v := range values {
+			if msg, ok := setQueryValue(slice.Index(i), v); !ok {
+				return fmt.Sprintf("entry %q %s", v, msg), false
+			}
+		}
+		fv.Set(slice)
+		return "", true
+	}
+
+	// The last occurrence wins for single values.
+	return setQueryValue(fv, values[len(values)-1])
+}
+
+func setQueryValue(v reflect.Value, s string) (string, bool) {
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if msg, ok := setQueryValue(ptr.Elem(), s); !ok {
+			return msg, false
+		}
+		v.Set(ptr)
+		return "", true
+	}
+
+	if v.Type() == timeType {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "must be a time in RFC 3339 format", false
+		}
+		v.Set(reflect.ValueOf(t))
+		return "", true
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return "must be true or false", false
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return "must be an integer", false
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return "must be a non-negative integer", false
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return "must be a number", false
+		}
+		v.SetFloat(n)
+	default:
+		// Unsupported fields are programming errors, like malformed validate tags.
+		panic(fmt.Sprintf("web: query param of type %s is not supported", v.Type()))
+	}
+
+	return "", true
+}
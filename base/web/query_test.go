@@ -0,0 +1,127 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tchorzewski1991/bds/base/web"
+)
+
+type bookQuery struct {
+	Page   int       `query:"page" validate:"max=100"`
+	Since  time.Time `query:"since"`
+	Tags   []string  `query:"tag" validate:"max=2"`
+	Kind   string    `query:"kind" validate:"enum=paper ebook"`
+	Limit  *uint     `query:"limit"`
+	Exact  bool      `query:"exact"`
+	Score  float64   `query:"score"`
+	Hidden string
+}
+
+func TestDecodeQuery(t *testing.T) {
+	limit := uint(20)
+
+	tests := []struct {
+		name  string
+		query string
+		want  bookQuery
+		errs  web.FieldErrors
+	}{
+		{
+			name:  "empty",
+			query: "",
+			want:  bookQuery{},
+			errs:  nil,
+		},
+		{
+			name:  "all params",
+			query: "page=2&since=2024-01-02T03:04:05Z&tag=new&tag=old&kind=ebook&limit=20&exact=true&score=0.5&Hidden=x",
+			want: bookQuery{
+				Page:  2,
+				Since: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+				Tags:  []string{"new", "old"},
+				Kind:  "ebook",
+				Limit: &limit,
+				Exact: true,
+				Score: 0.5,
+			},
+		},
+		{
+			name:  "last single value wins",
+			query: "page=1&page=3",
+			want:  bookQuery{Page: 3},
+		},
+		{
+			name:  "conversion failures",
+			query: "page=x&since=yesterday&limit=-1&exact=maybe&score=high&tag=a&tag=b&tag=c",
+			errs: web.FieldErrors{
+				"page":  "must be an integer",
+				"since": "must be a time in RFC 3339 format",
+				"limit": "must be a non-negative integer",
+				"exact": "must be true or false",
+				"score": "must be a number",
+				"tag":   "must be at most 2 entries",
+			},
+		},
+		{
+			name:  "validation failures",
+			query: "page=101&kind=audio",
+			errs: web.FieldErrors{
+				"page": "must be at most 100",
+				"kind": "must be one of: paper, ebook",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/books?"+tt.query, nil)
+
+			var q bookQuery
+			err := web.DecodeQuery(r, &q)
+
+			if tt.errs != nil {
+				fe := web.GetFieldErrors(err)
+				if !reflect.DeepEqual(fe, tt.errs) {
+					t.Fatalf("expected %v, got %v", tt.errs, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(q, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, q)
+			}
+		})
+	}
+}
+
+func TestDecodeQueryPanics(t *testing.T) {
+	tests := []struct {
+		name string
+		dest any
+	}{
+		{"not a pointer", bookQuery{}},
+		{"pointer to non-struct", new(string)},
+		{"unsupported field", &struct {
+			M map[string]string `query:"m"`
+		}{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic")
+				}
+			}()
+			r := httptest.NewRequest(http.MethodGet, "/books?m=x", nil)
+			_ = web.DecodeQuery(r, tt.dest)
+		})
+	}
+}
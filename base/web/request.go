@@ -1,15 +1,175 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"net"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
+// DefaultBodyLimit is the largest body, in bytes, Decode reads on routes without BodyLimit.
+const DefaultBodyLimit int64 = 1 << 20
+
+// Decode reads the JSON body of the request into dest and checks it with Validate.
+// The body must be sent as application/json and hold exactly one JSON value without
+// fields unknown to dest. It can't be larger than DefaultBodyLimit, unless the route
+// sets its own limit with BodyLimit. Bodies which can't be decoded are returned as
+// DecodeError, validation failures as FieldErrors.
 func Decode(r *http.Request, dest any) error {
-	err := json.NewDecoder(r.Body).Decode(dest)
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mt != "application/json" && !strings.HasSuffix(mt, "+json")) {
+		return &DecodeError{
+			Err:    errors.New("content type must be application/json"),
+			Status: http.StatusUnsupportedMediaType,
+		}
+	}
+
+	if getBodyLimit(r) == 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, DefaultBodyLimit)
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dest)
+	if err != nil {
+		return decodeError(err)
+	}
+
+	// Anything but white space after the value is rejected, including another value.
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return decodeError(err)
+		}
+		return &DecodeError{
+			Err:    errors.New("body must contain a single JSON value"),
+			Status: http.StatusBadRequest,
+		}
+	}
+
+	return Validate(dest)
+}
+
+// BodyLimit limits the body of requests to the route to n bytes. Requests declaring
+// larger content are rejected up front, others fail once they read past the limit.
+// It replaces DefaultBodyLimit used by Decode.
+func BodyLimit(n int64) Middleware {
+
+	m := func(handler Handler) Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if r.ContentLength > n {
+				return &DecodeError{
+					Err:    fmt.Errorf("body must not be larger than %d bytes", n),
+					Status: http.StatusRequestEntityTooLarge,
+				}
+			}
+
+			v, err := GetCtxValues(ctx)
+			if err != nil {
+				return NewShutdownError("cannot fetch values out of context")
+			}
+			v.BodyLimit = n
+
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+
+			return handler(ctx, w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
+
+// DecodeError reports a request which can't be decoded because of its content type,
+// size or malformed content. Status holds the matching HTTP status: 415, 413 or 400.
+type DecodeError struct {
+	Err    error
+	Status int
+}
+
+func (de *DecodeError) Error() string {
+	return de.Err.Error()
+}
+
+func (de *DecodeError) Unwrap() error {
+	return de.Err
+}
+
+func IsDecodeError(err error) bool {
+	var de *DecodeError
+	return errors.As(err, &de)
+}
+
+// GetDecodeError returns the DecodeError wrapped by err.
+func GetDecodeError(err error) *DecodeError {
+	var de *DecodeError
+	if !errors.As(err, &de) {
+		return nil
+	}
+	return de
+}
+
+// ClientIP returns the IP address of the client connected to the service.
+// Headers like X-Forwarded-For are not trusted, as any client is able to set them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// private
+
+// decodeError translates errors of the JSON decoder into messages meaningful to clients.
+func decodeError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxErr *http.MaxBytesError
+
+	status := http.StatusBadRequest
+
+	switch {
+	case errors.As(err, &maxErr):
+		status = http.StatusRequestEntityTooLarge
+		err = fmt.Errorf("body must not be larger than %d bytes", maxErr.Limit)
+	case errors.Is(err, io.EOF):
+		err = errors.New("body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		err = errors.New("body contains malformed JSON")
+	case errors.As(err, &syntaxErr):
+		err = fmt.Errorf("body contains malformed JSON at position %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		err = fmt.Errorf("body field %s must be %s", typeErr.Field, typeErr.Type)
+	case errors.As(err, &typeErr) && (typeErr.Type.Kind() == reflect.Struct || typeErr.Type.Kind() == reflect.Map):
+		err = errors.New("body must be a JSON object")
+	case errors.As(err, &typeErr):
+		err = fmt.Errorf("body must be %s", typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// The decoder doesn't export a type for unknown fields.
+		err = fmt.Errorf("body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		err = fmt.Errorf("payload not valid: %w", err)
+	}
+
+	return &DecodeError{Err: err, Status: status}
+}
+
+// getBodyLimit returns the limit set by BodyLimit for the route, or zero.
+func getBodyLimit(r *http.Request) int64 {
+	v, err := GetCtxValues(r.Context())
 	if err != nil {
-		return fmt.Errorf("payload not valid: %w", err)
+		return 0
 	}
-	return nil
+	return v.BodyLimit
 }
@@ -0,0 +1,152 @@
+package web_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	"go.uber.org/zap"
+)
+
+type newBook struct {
+	Title string   `json:"title" validate:"required"`
+	Year  int      `json:"year"`
+	Tags  []string `json:"tags"`
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		msg         string
+	}{
+		{"valid", "application/json", `{"title":"Dune","year":1965}`, 0, ""},
+		{"json suffix", "application/merge-patch+json", `{"title":"Dune"}`, 0, ""},
+		{"charset", "application/json; charset=utf-8", `{"title":"Dune"}`, 0, ""},
+		{"trailing white space", "application/json", "{\"title\":\"Dune\"}\n\t ", 0, ""},
+		{"no content type", "", `{"title":"Dune"}`, http.StatusUnsupportedMediaType, "content type must be application/json"},
+		{"wrong content type", "text/plain", `{"title":"Dune"}`, http.StatusUnsupportedMediaType, "content type must be application/json"},
+		{"empty", "application/json", ``, http.StatusBadRequest, "body must not be empty"},
+		{"truncated", "application/json", `{"title":`, http.StatusBadRequest, "body contains malformed JSON"},
+		{"malformed", "application/json", `{"title" "Dune"}`, http.StatusBadRequest, "body contains malformed JSON at position 10"},
+		{"unknown field", "application/json", `{"title":"Dune","isbn":"x"}`, http.StatusBadRequest, `body contains unknown field "isbn"`},
+		{"wrong field type", "application/json", `{"title":"Dune","year":"1965"}`, http.StatusBadRequest, "body field year must be int"},
+		{"not an object", "application/json", `["Dune"]`, http.StatusBadRequest, "body must be a JSON object"},
+		{"trailing value", "application/json", `{"title":"Dune"}{"title":"Emma"}`, http.StatusBadRequest, "body must contain a single JSON value"},
+		{"trailing garbage", "application/json", `{"title":"Dune"} x`, http.StatusBadRequest, "body must contain a single JSON value"},
+		{"too large", "application/json", `{"title":"` + strings.Repeat("a", int(web.DefaultBodyLimit)) + `"}`, http.StatusRequestEntityTooLarge, "body must not be larger than 1048576 bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			var nb newBook
+			err := web.Decode(r, &nb)
+
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if nb.Title == "" {
+					t.Fatal("expected body to be decoded")
+				}
+				return
+			}
+
+			de := web.GetDecodeError(err)
+			if de == nil {
+				t.Fatalf("expected decode error, got %v", err)
+			}
+			if de.Status != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, de.Status)
+			}
+			if de.Error() != tt.msg {
+				t.Fatalf("expected %q, got %q", tt.msg, de.Error())
+			}
+		})
+	}
+}
+
+func TestDecodeValidates(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(`{"year":1965}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	var nb newBook
+	err := web.Decode(r, &nb)
+
+	fe := web.GetFieldErrors(err)
+	if fe["title"] != "can't be blank" {
+		t.Fatalf("expected title to be reported, got %v", err)
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	const limit = 32
+
+	// capture keeps the error of the route, which is turned into the response by
+	// the errors middleware of the service otherwise.
+	var routeErr error
+	capture := func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			routeErr = handler(ctx, w, r)
+			return nil
+		}
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), zap.NewNop().Sugar(), capture)
+	app.Handle(http.MethodPost, "", "/books", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		var nb newBook
+		return web.Decode(r, &nb)
+	}, web.BodyLimit(limit))
+
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		status  int
+	}{
+		{"within limit", `{"title":"Dune"}`, false, 0},
+		{"declared over limit", `{"title":"` + strings.Repeat("a", limit) + `"}`, false, http.StatusRequestEntityTooLarge},
+		{"read over limit", `{"title":"` + strings.Repeat("a", limit) + `"}`, true, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routeErr = nil
+
+			r := httptest.NewRequest(http.MethodPost, "/books", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			if tt.chunked {
+				// Without the declared length the limit applies while reading.
+				r.ContentLength = -1
+			}
+
+			app.ServeHTTP(httptest.NewRecorder(), r)
+
+			if tt.status == 0 {
+				if routeErr != nil {
+					t.Fatalf("expected no error, got %v", routeErr)
+				}
+				return
+			}
+
+			de := web.GetDecodeError(routeErr)
+			if de == nil || de.Status != tt.status {
+				t.Fatalf("expected decode error with status %d, got %v", tt.status, routeErr)
+			}
+			if want := "body must not be larger than 32 bytes"; de.Error() != want {
+				t.Fatalf("expected %q, got %q", want, de.Error())
+			}
+		})
+	}
+}
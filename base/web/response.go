@@ -2,41 +2,99 @@ package web
 
 import (
 	"context"
-	"encoding/json"
+	"io"
 	"net/http"
 )
 
+// Response sends data back to the client in the format negotiated on the Accept header
+// among the encoders registered on the App. NotAcceptableError is returned when none of
+// them is accepted, unless the response reports an error, see encode.
 func Response(ctx context.Context, w http.ResponseWriter, statusCode int, data any) error {
 
-	// Set status code in the context.
-	err := SetStatusCode(ctx, statusCode)
+	v, err := GetCtxValues(ctx)
 	if err != nil {
 		return err
 	}
 
 	// If there is nothing to marshal then set status code and return.
 	if statusCode == http.StatusNoContent {
+		v.StatusCode = statusCode
 		w.WriteHeader(statusCode)
 		return nil
 	}
 
-	// Marshal data to JSON.
-	jsonData, err := json.Marshal(data)
+	// Encode data before anything is sent, so the client can still get the error.
+	contentType, body, err := encode(v, statusCode, data)
 	if err != nil {
 		return err
 	}
 
-	// Ensure content type has been set properly while we know marshaling has succeeded.
-	w.Header().Set("Content-Type", "application/json")
+	// Set status code in the context.
+	v.StatusCode = statusCode
+
+	// Ensure content type has been set properly while we know encoding has succeeded.
+	w.Header().Set("Content-Type", contentType)
+	if len(v.encoders) > 1 {
+		w.Header().Add("Vary", "Accept")
+	}
 
 	// Write the status code to the response.
 	w.WriteHeader(statusCode)
 
 	// Send result back to the client.
-	_, err = w.Write(jsonData)
+	_, err = w.Write(body)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RawResponse sends data of the given content type back to the client as is.
+func RawResponse(ctx context.Context, w http.ResponseWriter, statusCode int, contentType string, data []byte) error {
+
+	// Set status code in the context.
+	err := SetStatusCode(ctx, statusCode)
 	if err != nil {
 		return err
 	}
 
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+
+	_, err = w.Write(data)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// StreamResponse sends the status code and content type, then lets fn write the body directly to the client.
+func StreamResponse(ctx context.Context, w http.ResponseWriter, statusCode int, contentType string, fn func(w io.Writer) error) error {
+
+	// Set status code in the context.
+	err := SetStatusCode(ctx, statusCode)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+
+	return fn(w)
+}
+
+// Redirect redirects the client to the url with the given status code.
+func Redirect(ctx context.Context, w http.ResponseWriter, r *http.Request, url string, statusCode int) error {
+
+	// Set status code in the context.
+	err := SetStatusCode(ctx, statusCode)
+	if err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, url, statusCode)
+
 	return nil
 }
@@ -0,0 +1,186 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+// Group registers routes sharing the path prefix and middleware. Middleware of the
+// group run before the ones given to particular routes.
+type Group struct {
+	app    *App
+	prefix string
+	mw     []Middleware
+}
+
+// Handle registers the handler for the method and path, prefixed with the prefix of the group.
+// The registered route is returned, so it can be documented with Describe.
+func (g *Group) Handle(method string, path string, handler Handler, mw ...Middleware) *Route {
+	return g.app.handle(method, g.prefix+path, handler, g.chain(mw))
+}
+
+// Group returns the nested Group extending the prefix and middleware of g.
+func (g *Group) Group(prefix string, mw ...Middleware) *Group {
+	return &Group{app: g.app, prefix: g.prefix + prefix, mw: g.chain(mw)}
+}
+
+// HandleUnmatched makes requests to paths under the prefix of the group which match
+// no route, or none of its methods, go through the middleware of the group as well,
+// so they are answered the way the routes of the group are, e.g. in its error format.
+// The group with the longest prefix handles the path, when there are more of them.
+func (g *Group) HandleUnmatched() {
+	g.app.unmatched = append(g.app.unmatched, g)
+}
+
+// Route describes the registered route. Handler and middleware are named after
+// their functions, middleware after the functions which created them, e.g.
+// mid.Authenticate. Middleware are listed in the order they run, the application
+// level ones first.
+type Route struct {
+	Method     string   `json:"method"`
+	Pattern    string   `json:"pattern"`
+	Handler    string   `json:"handler"`
+	Middleware []string `json:"middleware"`
+	Doc        *Doc     `json:"-"`
+}
+
+// Describe attaches the documentation to the route.
+func (rt *Route) Describe(doc Doc) {
+	rt.Doc = &doc
+}
+
+// Doc documents the route for the API description. Query, Request and values of
+// Responses are examples of the types involved, e.g. book.NewBook{}. Query must be
+// a struct decoded with DecodeQuery. Responses are keyed by the status code, nil
+// stands for the response without a body. Bodies which are not JSON are described
+// with Raw.
+type Doc struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Query       any
+	Request     any
+	Responses   map[int]any
+	// Hidden routes are left out of the description, e.g. the description itself.
+	Hidden bool
+}
+
+// Raw describes the body which is not JSON by its media type, e.g. Raw("image/png").
+type Raw string
+
+// RouteError reports requests to paths, or methods of paths, which are not served.
+// Status holds the matching HTTP status: 404 or 405.
+type RouteError struct {
+	Err    error
+	Status int
+}
+
+func (re *RouteError) Error() string {
+	return re.Err.Error()
+}
+
+func IsRouteError(err error) bool {
+	var re *RouteError
+	return errors.As(err, &re)
+}
+
+// GetRouteError returns the RouteError wrapped by err.
+func GetRouteError(err error) *RouteError {
+	var re *RouteError
+	if !errors.As(err, &re) {
+		return nil
+	}
+	return re
+}
+
+// private
+
+// chain returns middleware of the group followed by mw, without sharing the backing array.
+func (g *Group) chain(mw []Middleware) []Middleware {
+	result := make([]Middleware, 0, len(g.mw)+len(mw))
+	result = append(result, g.mw...)
+	return append(result, mw...)
+}
+
+func newRoute(method string, pattern string, handler Handler, mw ...[]Middleware) *Route {
+	route := Route{
+		Method:     method,
+		Pattern:    pattern,
+		Handler:    funcName(handler),
+		Middleware: []string{},
+	}
+	for _, list := range mw {
+		for _, m := range list {
+			if m != nil {
+				route.Middleware = append(route.Middleware, funcName(m))
+			}
+		}
+	}
+	return &route
+}
+
+// closureSuffix matches the part of the function name added by the compiler to closures
+// and method values.
+var closureSuffix = regexp.MustCompile(`((\.func\d+)+(\.\d+)*|-fm)$`)
+
+// funcName returns the name of the function, or the function which created the closure,
+// qualified with the package name only, e.g. mid.Authenticate.
+func funcName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+
+	return closureSuffix.ReplaceAllString(name, "")
+}
+
+func notFound(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+	return &RouteError{
+		Err:    fmt.Errorf("path %s not found", r.URL.Path),
+		Status: http.StatusNotFound,
+	}
+}
+
+// methodNotAllowed answers OPTIONS requests with the list of allowed methods,
+// requests with other methods are rejected with RouteError.
+func methodNotAllowed(methods map[string]httptreemux.HandlerFunc) Handler {
+	allowed := []string{http.MethodOptions}
+	for m := range methods {
+		allowed = append(allowed, m)
+	}
+	if _, ok := methods[http.MethodGet]; ok {
+		if _, ok := methods[http.MethodHead]; !ok {
+			// The router serves HEAD with GET handlers.
+			allowed = append(allowed, http.MethodHead)
+		}
+	}
+	sort.Strings(allowed)
+	allow := strings.Join(allowed, ", ")
+
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		w.Header().Set("Allow", allow)
+
+		if r.Method == http.MethodOptions {
+			return Response(ctx, w, http.StatusNoContent, nil)
+		}
+
+		return &RouteError{
+			Err:    fmt.Errorf("method %s not allowed", r.Method),
+			Status: http.StatusMethodNotAllowed,
+		}
+	}
+}
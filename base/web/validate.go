@@ -0,0 +1,22 @@
+package web
+
+import "github.com/tchorzewski1991/bds/base/validate"
+
+// FieldErrors holds all the validation failures of the value, keyed by the JSON name
+// of the field, or the name of the query param for values decoded by DecodeQuery.
+type FieldErrors = validate.FieldErrors
+
+// Validate checks v against the rules declared in the validate tags of its fields,
+// see validate.Struct. Decode and DecodeQuery call it for every decoded value.
+func Validate(v any) error {
+	return validate.Struct(v)
+}
+
+func IsFieldErrors(err error) bool {
+	return validate.IsFieldErrors(err)
+}
+
+// GetFieldErrors returns the FieldErrors wrapped by err.
+func GetFieldErrors(err error) FieldErrors {
+	return validate.GetFieldErrors(err)
+}
@@ -0,0 +1,312 @@
+// Package apikey manages API keys used by services and batch jobs instead of user credentials.
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	uid "github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/core/apikey/db"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotFound = errors.New("api key not found")
+)
+
+// Keys look like bds_1a2b3c4d_<secret>. The part up to the second underscore is
+// the prefix stored in plain text, so keys can be found and recognised.
+const (
+	keyPrefix    = "bds_"
+	prefixLength = 8
+)
+
+// Core manages the set of APIs for API key access. It implements auth.KeyAuthenticator.
+type Core struct {
+	store  db.Store
+	logger *zap.SugaredLogger
+}
+
+// NewCore constructs a Core for API key access.
+func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger) Core {
+	return Core{store: db.NewStore(sqlDB, logger), logger: logger}
+}
+
+// Create stores the new API key and returns it together with the key itself.
+// The key can't be retrieved again later.
+func (c Core) Create(ctx context.Context, nk NewKey, createdBy string) (Key, string, error) {
+	err := check(&nk)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	prefix, secret, err := generate()
+	if err != nil {
+		return Key{}, "", err
+	}
+	raw := prefix + "_" + secret
+
+	key := db.Key{
+		ID:          uid.NewString(),
+		Name:        nk.Name,
+		Prefix:      prefix,
+		KeyHash:     hash(raw),
+		Permissions: nk.Permissions,
+		IPAllowlist: nk.IPAllowlist,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if nk.ExpiresAt != nil {
+		key.ExpiresAt = sql.NullTime{Time: nk.ExpiresAt.UTC(), Valid: true}
+	}
+	if createdBy != "" {
+		key.CreatedBy = sql.NullString{String: createdBy, Valid: true}
+	}
+
+	err = c.store.Create(ctx, key)
+	if err != nil {
+		return Key{}, "", fmt.Errorf("create failed: %w", err)
+	}
+
+	return convertToKey(key), raw, nil
+}
+
+func (c Core) Query(ctx context.Context) ([]Key, error) {
+	keys, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make([]Key, len(keys))
+	for i, k := range keys {
+		result[i] = convertToKey(k)
+	}
+
+	return result, nil
+}
+
+func (c Core) QueryByID(ctx context.Context, id string) (Key, error) {
+	if _, err := uid.Parse(id); err != nil {
+		return Key{}, ErrNotFound
+	}
+
+	key, err := c.store.QueryByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Key{}, ErrNotFound
+		}
+		return Key{}, fmt.Errorf("query failed: %w", err)
+	}
+
+	return convertToKey(key), nil
+}
+
+// Revoke makes the key unusable. Revoked keys are kept for the record.
+func (c Core) Revoke(ctx context.Context, id string) (Key, error) {
+	_, err := c.QueryByID(ctx, id)
+	if err != nil {
+		return Key{}, err
+	}
+
+	err = c.store.Revoke(ctx, id)
+	if err != nil {
+		return Key{}, fmt.Errorf("revoke failed: %w", err)
+	}
+
+	return c.QueryByID(ctx, id)
+}
+
+// AuthenticateKey returns claims granting the permissions of the key. Every successful
+// use is recorded.
+func (c Core) AuthenticateKey(ctx context.Context, raw string, ip string) (auth.Claims, error) {
+	prefix, ok := parse(raw)
+	if !ok {
+		return auth.Claims{}, fmt.Errorf("malformed key: %w", auth.ErrKeyNotValid)
+	}
+
+	key, err := c.store.QueryByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return auth.Claims{}, auth.ErrKeyNotValid
+		}
+		return auth.Claims{}, fmt.Errorf("authenticate failed: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hash(raw)), []byte(key.KeyHash)) != 1 {
+		return auth.Claims{}, auth.ErrKeyNotValid
+	}
+
+	now := time.Now().UTC()
+
+	switch {
+	case key.RevokedAt.Valid:
+		return auth.Claims{}, fmt.Errorf("key revoked: %w", auth.ErrKeyNotValid)
+	case key.ExpiresAt.Valid && !now.Before(key.ExpiresAt.Time):
+		return auth.Claims{}, fmt.Errorf("key expired: %w", auth.ErrKeyNotValid)
+	case !allowed(key.IPAllowlist, ip):
+		return auth.Claims{}, fmt.Errorf("ip %s not allowed: %w", ip, auth.ErrKeyNotValid)
+	}
+
+	err = c.store.RecordUse(ctx, key.ID)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("recording key use: %w", err)
+	}
+
+	return auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:  "bds-api",
+			Subject: key.ID,
+		},
+		Permissions: key.Permissions,
+		Kind:        auth.KindAPIKey,
+	}, nil
+}
+
+// private
+
+// check validates and normalizes the new key.
+func check(nk *NewKey) error {
+	nk.Name = strings.TrimSpace(nk.Name)
+	if nk.Name == "" {
+		return FieldError{field: "name", err: "can't be blank"}
+	}
+
+	if len(nk.Permissions) == 0 {
+		return FieldError{field: "permissions", err: "can't be empty"}
+	}
+	for _, p := range nk.Permissions {
+		if !auth.ValidPermission(p) {
+			return FieldError{field: "permissions", err: fmt.Sprintf("%q is not a valid permission", p)}
+		}
+	}
+
+	allowlist := make([]string, len(nk.IPAllowlist))
+	for i, entry := range nk.IPAllowlist {
+		network, err := parseNetwork(entry)
+		if err != nil {
+			return FieldError{field: "ip_allowlist", err: fmt.Sprintf("%q is neither IP nor CIDR", entry)}
+		}
+		allowlist[i] = network.String()
+	}
+	nk.IPAllowlist = allowlist
+
+	if nk.ExpiresAt != nil && !nk.ExpiresAt.After(time.Now()) {
+		return FieldError{field: "expires_at", err: "must be in the future"}
+	}
+
+	return nil
+}
+
+// parseNetwork accepts CIDR or a single IP, which is turned into the network of one address.
+func parseNetwork(entry string) (*net.IPNet, error) {
+	if !strings.Contains(entry, "/") {
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, errors.New("invalid ip")
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(entry)
+	return network, err
+}
+
+func allowed(allowlist []string, ip string) bool {
+	if len(allowlist) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, entry := range allowlist {
+		network, err := parseNetwork(entry)
+		if err == nil && network.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func generate() (string, string, error) {
+	b := make([]byte, prefixLength/2+32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", fmt.Errorf("generating key: %w", err)
+	}
+
+	prefix := keyPrefix + hex.EncodeToString(b[:prefixLength/2])
+	secret := base64.RawURLEncoding.EncodeToString(b[prefixLength/2:])
+
+	return prefix, secret, nil
+}
+
+// parse returns the prefix of the raw key.
+func parse(raw string) (string, bool) {
+	if !strings.HasPrefix(raw, keyPrefix) {
+		return "", false
+	}
+
+	i := len(keyPrefix) + prefixLength
+	if len(raw) <= i+1 || raw[i] != '_' {
+		return "", false
+	}
+
+	return raw[:i], true
+}
+
+func hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func convertToKey(key db.Key) Key {
+	k := Key{
+		ID:           key.ID,
+		Name:         key.Name,
+		Prefix:       key.Prefix,
+		Permissions:  key.Permissions,
+		IPAllowlist:  key.IPAllowlist,
+		CreatedAt:    key.CreatedAt,
+		RequestCount: key.RequestCount,
+	}
+	if k.Permissions == nil {
+		k.Permissions = []string{}
+	}
+	if k.IPAllowlist == nil {
+		k.IPAllowlist = []string{}
+	}
+	if key.ExpiresAt.Valid {
+		k.ExpiresAt = &key.ExpiresAt.Time
+	}
+	if key.CreatedBy.Valid {
+		k.CreatedBy = &key.CreatedBy.String
+	}
+	if key.RevokedAt.Valid {
+		k.RevokedAt = &key.RevokedAt.Time
+	}
+	if key.LastUsedAt.Valid {
+		k.LastUsedAt = &key.LastUsedAt.Time
+	}
+	return k
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *database.ExtContext
+}
+
+func NewStore(db *sqlx.DB, logger *zap.SugaredLogger) Store {
+	return Store{db: database.NewExtContext(db).WithLogger(logger)}
+}
+
+func (s Store) Create(ctx context.Context, key Key) error {
+	const q = `
+		insert into api_keys
+			(id, name, prefix, key_hash, permissions, ip_allowlist, expires_at, created_by, created_at)
+		values
+			(:id, :name, :prefix, :key_hash, :permissions, :ip_allowlist, :expires_at, :created_by, :created_at)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("api_keys", "Create"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, key)
+	return err
+}
+
+func (s Store) Query(ctx context.Context) ([]Key, error) {
+	const q = `select * from api_keys order by created_at desc`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("api_keys", "Query"))
+
+	return s.query(ctx, ext, q, map[string]any{})
+}
+
+func (s Store) QueryByID(ctx context.Context, id string) (Key, error) {
+	const q = `select * from api_keys where id = :id`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("api_keys", "QueryByID"))
+
+	return s.queryOne(ctx, ext, q, map[string]any{"id": id})
+}
+
+func (s Store) QueryByPrefix(ctx context.Context, prefix string) (Key, error) {
+	const q = `select * from api_keys where prefix = :prefix`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("api_keys", "QueryByPrefix"))
+
+	return s.queryOne(ctx, ext, q, map[string]any{"prefix": prefix})
+}
+
+func (s Store) Revoke(ctx context.Context, id string) error {
+	const q = `update api_keys set revoked_at = now() where id = :id and revoked_at is null`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("api_keys", "Revoke"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{"id": id})
+	return err
+}
+
+// RecordUse bumps the request counter and the last used time of the key.
+func (s Store) RecordUse(ctx context.Context, id string) error {
+	const q = `update api_keys set last_used_at = now(), request_count = request_count + 1 where id = :id`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("api_keys", "RecordUse"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{"id": id})
+	return err
+}
+
+// private
+
+func (s Store) queryOne(ctx context.Context, ext *database.ExtContext, q string, data map[string]any) (Key, error) {
+	keys, err := s.query(ctx, ext, q, data)
+	if err != nil {
+		return Key{}, err
+	}
+	if len(keys) == 0 {
+		return Key{}, database.ErrNotFound
+	}
+	return keys[0], nil
+}
+
+func (s Store) query(ctx context.Context, ext *database.ExtContext, q string, data map[string]any) ([]Key, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []Key
+
+	for rows.Next() {
+		var key Key
+		err = rows.StructScan(&key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,23 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Key struct {
+	ID           string         `db:"id"`
+	Name         string         `db:"name"`
+	Prefix       string         `db:"prefix"`
+	KeyHash      string         `db:"key_hash"`
+	Permissions  pq.StringArray `db:"permissions"`
+	IPAllowlist  pq.StringArray `db:"ip_allowlist"`
+	ExpiresAt    sql.NullTime   `db:"expires_at"`
+	CreatedBy    sql.NullString `db:"created_by"`
+	CreatedAt    time.Time      `db:"created_at"`
+	RevokedAt    sql.NullTime   `db:"revoked_at"`
+	LastUsedAt   sql.NullTime   `db:"last_used_at"`
+	RequestCount int64          `db:"request_count"`
+}
@@ -0,0 +1,45 @@
+package apikey
+
+import (
+	"fmt"
+	"time"
+)
+
+// Key is a business representation of the API key. The key itself is never stored,
+// only its prefix which helps to recognise it.
+type Key struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Permissions  []string   `json:"permissions"`
+	IPAllowlist  []string   `json:"ip_allowlist"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedBy    *string    `json:"created_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RequestCount int64      `json:"request_count"`
+}
+
+// NewKey contains information needed to create an API key. Empty IPAllowlist
+// allows every IP, nil ExpiresAt creates a key which never expires.
+type NewKey struct {
+	Name        string     `json:"name" validate:"required,max=100"`
+	Permissions []string   `json:"permissions" validate:"required"`
+	IPAllowlist []string   `json:"ip_allowlist"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type FieldError struct {
+	field string
+	err   string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s %s", fe.field, fe.err)
+}
+
+// Details returns the problem keyed by the JSON name of the field.
+func (fe FieldError) Details() map[string]string {
+	return map[string]string{fe.field: fe.err}
+}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
+	"github.com/tchorzewski1991/bds/base/isbn"
+	"github.com/tchorzewski1991/bds/base/validate"
 	"github.com/tchorzewski1991/bds/business/core/book/db"
 	"github.com/tchorzewski1991/bds/business/sys/database"
 	"go.uber.org/zap"
@@ -42,25 +44,97 @@ func (c Core) QueryByID(ctx context.Context, ID int) (Book, error) {
 	return convertToBook(book), nil
 }
 
-func (c Core) Query(ctx context.Context, page int, rowsPerPage int) ([]Book, error) {
-	books, err := c.store.Query(ctx, page, rowsPerPage)
+func (c Core) Query(ctx context.Context, filter QueryFilter, page int, rowsPerPage int) ([]Book, error) {
+	books, err := c.store.Query(ctx, db.Filter(filter), page, rowsPerPage)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 	return convertToBooks(books), nil
 }
 
+// QueryRecent returns the most recently added books first.
+func (c Core) QueryRecent(ctx context.Context, page int, rowsPerPage int) ([]Book, error) {
+	books, err := c.store.QueryRecent(ctx, page, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	return convertToBooks(books), nil
+}
+
+// QueryAuthors returns distinct authors in alphabetical order.
+func (c Core) QueryAuthors(ctx context.Context, page int, rowsPerPage int) ([]Facet, error) {
+	return c.queryFacets(ctx, "author", page, rowsPerPage)
+}
+
+// QueryPublishers returns distinct publishers in alphabetical order.
+func (c Core) QueryPublishers(ctx context.Context, page int, rowsPerPage int) ([]Facet, error) {
+	return c.queryFacets(ctx, "publisher", page, rowsPerPage)
+}
+
+// Each calls fn for every book matching the filter, in ID order.
+// Books are fetched in batches, so it is suitable for exporting the whole catalogue.
+func (c Core) Each(ctx context.Context, filter QueryFilter, fn func(Book) error) error {
+	const batchSize = 500
+
+	var afterID int
+	for {
+		books, err := c.store.QueryAfter(ctx, db.Filter(filter), afterID, batchSize)
+		if err != nil {
+			return fmt.Errorf("query failed: %w", err)
+		}
+
+		for _, b := range books {
+			err = fn(convertToBook(b))
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(books) < batchSize {
+			return nil
+		}
+		afterID = books[len(books)-1].ID
+	}
+}
+
+// QueryByIDs returns books matching the given IDs. It fails with ErrNotFound when any of them is missing.
+func (c Core) QueryByIDs(ctx context.Context, IDs []int) ([]Book, error) {
+	books, err := c.store.QueryByIDs(ctx, IDs)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	found := make(map[int]db.Book, len(books))
+	for _, b := range books {
+		found[b.ID] = b
+	}
+
+	// Keep the order requested by the caller.
+	result := make([]Book, 0, len(IDs))
+	for _, id := range IDs {
+		b, ok := found[id]
+		if !ok {
+			return nil, fmt.Errorf("book %d: %w", id, ErrNotFound)
+		}
+		result = append(result, convertToBook(b))
+	}
+
+	return result, nil
+}
+
 func (c Core) Create(ctx context.Context, nb NewBook) (Book, error) {
+	err := Validate(nb)
+	if err != nil {
+		return Book{}, fmt.Errorf("create failed: %w", err)
+	}
+
 	book := db.Book{
 		Isbn:            nb.Isbn,
 		Title:           nb.Title,
 		Author:          database.Str(nb.Author),
 		PublicationYear: database.Str(nb.PublicationYear),
 		Publisher:       database.Str(nb.Publisher),
-	}
-	err := sanityCheck(book)
-	if err != nil {
-		return Book{}, fmt.Errorf("create failed: %w", err)
+		CoverURL:        database.Str(nb.CoverURL),
 	}
 
 	id, err := c.store.Create(ctx, book)
@@ -76,8 +150,75 @@ func (c Core) Create(ctx context.Context, nb NewBook) (Book, error) {
 	return convertToBook(book), nil
 }
 
+// Delete removes the book. Metadata of its files goes away with it, their content
+// has to be removed from the blob store beforehand.
+func (c Core) Delete(ctx context.Context, ID int) error {
+	err := c.store.Delete(ctx, ID)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("delete failed: %w", err)
+	}
+
+	return nil
+}
+
+// QueryConflicts returns existing books sharing the ISBN (in any form) or the title with the new book.
+func (c Core) QueryConflicts(ctx context.Context, nb NewBook) ([]Conflict, error) {
+	var isbns []string
+	if nb.Isbn != "" {
+		isbns = append(isbns, nb.Isbn)
+		if v, err := isbn.To10(nb.Isbn); err == nil {
+			isbns = append(isbns, v)
+		}
+		if v, err := isbn.To13(nb.Isbn); err == nil {
+			isbns = append(isbns, v)
+		}
+	}
+
+	books, err := c.store.QueryConflicts(ctx, isbns, nb.Title)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	conflicts := make([]Conflict, len(books))
+	for i, b := range books {
+		reason := ConflictTitle
+		for _, v := range isbns {
+			if b.Isbn == v {
+				reason = ConflictIsbn
+			}
+		}
+		conflicts[i] = Conflict{Book: convertToBook(b), Reason: reason}
+	}
+
+	return conflicts, nil
+}
+
+// Validate checks whether the new book can be persisted. The same rules apply
+// to books created through the API and to books imported with the tooling.
+// Violations are returned as validate.FieldErrors.
+func Validate(nb NewBook) error {
+	return validate.Struct(nb)
+}
+
 // private
 
+func (c Core) queryFacets(ctx context.Context, column string, page int, rowsPerPage int) ([]Facet, error) {
+	facets, err := c.store.QueryFacets(ctx, column, page, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make([]Facet, len(facets))
+	for i, f := range facets {
+		result[i] = Facet(f)
+	}
+
+	return result, nil
+}
+
 func convertToBooks(books []db.Book) []Book {
 	result := make([]Book, len(books))
 
@@ -104,6 +245,11 @@ func convertToBook(book db.Book) Book {
 		publisher = &book.Publisher.String
 	}
 
+	var coverURL *string
+	if book.CoverURL.Valid {
+		coverURL = &book.CoverURL.String
+	}
+
 	return Book{
 		ID:              book.ID,
 		Isbn:            book.Isbn,
@@ -111,15 +257,6 @@ func convertToBook(book db.Book) Book {
 		Author:          author,
 		PublicationYear: publicationYear,
 		Publisher:       publisher,
+		CoverURL:        coverURL,
 	}
 }
-
-func sanityCheck(book db.Book) error {
-	if book.Title == "" {
-		return FieldError{field: "title", err: "can't be blank"}
-	}
-	if book.Isbn == "" {
-		return FieldError{field: "isbn", err: "can't be blank"}
-	}
-	return nil
-}
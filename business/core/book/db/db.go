@@ -3,6 +3,8 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
@@ -46,23 +48,55 @@ func (s Store) QueryByID(ctx context.Context, id int) (Book, error) {
 	return book, nil
 }
 
-func (s Store) Query(ctx context.Context, page int, rowsPerPage int) ([]Book, error) {
-	const q = `select * from books order by id offset :offset rows fetch next :rows_per_page rows only`
+func (s Store) Query(ctx context.Context, filter Filter, page int, rowsPerPage int) ([]Book, error) {
+	page, rowsPerPage = paging(page, rowsPerPage)
 
-	// Ensure page is set correctly
-	if page < 1 {
-		page = 1
+	data := map[string]any{
+		"offset":        (page - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
 	}
 
-	// Ensure rowsPerPage is set correctly
-	if rowsPerPage < 1 || rowsPerPage > 20 {
-		rowsPerPage = 20
-	}
+	q := `select * from books` + where(filter, data) + ` order by id offset :offset rows fetch next :rows_per_page rows only`
 
 	ext := s.db.
 		WithErrorMapper(database.NewErrorMapper()).
 		WithMetric(database.NewMetric("books", "Query"))
 
+	return s.query(ctx, ext, q, data)
+}
+
+// QueryRecent returns the most recently added books first.
+func (s Store) QueryRecent(ctx context.Context, page int, rowsPerPage int) ([]Book, error) {
+	const q = `select * from books order by created_at desc, id desc offset :offset rows fetch next :rows_per_page rows only`
+
+	page, rowsPerPage = paging(page, rowsPerPage)
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("books", "QueryRecent"))
+
+	return s.query(ctx, ext, q, map[string]any{
+		"offset":        (page - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	})
+}
+
+// QueryFacets returns distinct values of the column together with the number of books.
+// Column must be one of: author, publisher.
+func (s Store) QueryFacets(ctx context.Context, column string, page int, rowsPerPage int) ([]Facet, error) {
+	if column != "author" && column != "publisher" {
+		return nil, fmt.Errorf("facet column %q is not supported", column)
+	}
+
+	q := `select ` + column + ` as name, count(*) as count from books where ` + column + ` is not null and ` + column + ` <> ''
+		group by ` + column + ` order by ` + column + ` offset :offset rows fetch next :rows_per_page rows only`
+
+	page, rowsPerPage = paging(page, rowsPerPage)
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("books", "QueryFacets"))
+
 	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
 		"offset":        (page - 1) * rowsPerPage,
 		"rows_per_page": rowsPerPage,
@@ -72,26 +106,69 @@ func (s Store) Query(ctx context.Context, page int, rowsPerPage int) ([]Book, er
 	}
 	defer rows.Close()
 
-	var books []Book
+	var facets []Facet
 
 	for rows.Next() {
-		var book Book
-		err = rows.StructScan(&book)
+		var f Facet
+		err = rows.StructScan(&f)
 		if err != nil {
 			return nil, err
 		}
-		books = append(books, book)
+		facets = append(facets, f)
 	}
 
-	return books, nil
+	return facets, nil
+}
+
+// QueryAfter returns up to limit books with ID greater than afterID.
+// It allows iterating over the whole table without offset penalty.
+func (s Store) QueryAfter(ctx context.Context, filter Filter, afterID int, limit int) ([]Book, error) {
+	data := map[string]any{
+		"after_id": afterID,
+		"limit":    limit,
+	}
+
+	q := `select * from books` + where(filter, data, "id > :after_id") + ` order by id limit :limit`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("books", "QueryAfter"))
+
+	return s.query(ctx, ext, q, data)
+}
+
+func (s Store) QueryByIDs(ctx context.Context, ids []int) ([]Book, error) {
+	const q = `select * from books where id = any(:ids) order by id`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("books", "QueryByIDs"))
+
+	return s.query(ctx, ext, q, map[string]any{
+		"ids": pq.Array(ids),
+	})
+}
+
+// QueryConflicts returns books sharing any of the ISBNs or the title (case-insensitively).
+func (s Store) QueryConflicts(ctx context.Context, isbns []string, title string) ([]Book, error) {
+	const q = `select * from books where isbn = any(:isbns) or lower(title) = lower(:title) order by id limit 20`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("books", "QueryConflicts"))
+
+	return s.query(ctx, ext, q, map[string]any{
+		"isbns": pq.Array(isbns),
+		"title": title,
+	})
 }
 
 func (s Store) Create(ctx context.Context, book Book) (id int, err error) {
 	const q = `
 		insert into books 
-			(isbn, title, author, publication_year, publisher, created_at)
+			(isbn, title, author, publication_year, publisher, cover_url, created_at)
 		values
-			(:isbn, :title, :author, :publication_year, :publisher, now())
+			(:isbn, :title, :author, :publication_year, :publisher, :cover_url, now())
 		returning id;
 	`
 
@@ -116,3 +193,104 @@ func (s Store) Create(ctx context.Context, book Book) (id int, err error) {
 
 	return id, nil
 }
+
+func (s Store) Delete(ctx context.Context, id int) error {
+	const q = `delete from books where id = :id`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("books", "Delete"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"id": id,
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
+
+// private
+
+func paging(page int, rowsPerPage int) (int, int) {
+	// Ensure page is set correctly
+	if page < 1 {
+		page = 1
+	}
+
+	// Ensure rowsPerPage is set correctly
+	if rowsPerPage < 1 || rowsPerPage > 20 {
+		rowsPerPage = 20
+	}
+
+	return page, rowsPerPage
+}
+
+func (s Store) query(ctx context.Context, ext *database.ExtContext, q string, data map[string]any) ([]Book, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []Book
+
+	for rows.Next() {
+		var book Book
+		err = rows.StructScan(&book)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// where builds the where clause out of the filter and extra conditions.
+// Filter values are added to the data used for binding named parameters.
+func where(filter Filter, data map[string]any, conditions ...string) string {
+	if filter.Isbn != "" {
+		data["isbn"] = filter.Isbn
+		conditions = append(conditions, "isbn = :isbn")
+	}
+	if filter.Title != "" {
+		data["title"] = "%" + filter.Title + "%"
+		conditions = append(conditions, "title ilike :title")
+	}
+	if filter.Author != "" {
+		data["author"] = "%" + filter.Author + "%"
+		conditions = append(conditions, "author ilike :author")
+	}
+	if filter.Publisher != "" {
+		data["publisher"] = "%" + filter.Publisher + "%"
+		conditions = append(conditions, "publisher ilike :publisher")
+	}
+	if filter.Search != "" {
+		data["search"] = "%" + filter.Search + "%"
+		conditions = append(conditions, "(title ilike :search or author ilike :search or isbn ilike :search)")
+	}
+	if filter.ExactAuthor != "" {
+		data["exact_author"] = filter.ExactAuthor
+		conditions = append(conditions, "author = :exact_author")
+	}
+	if filter.ExactPublisher != "" {
+		data["exact_publisher"] = filter.ExactPublisher
+		conditions = append(conditions, "publisher = :exact_publisher")
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " where " + strings.Join(conditions, " and ")
+}
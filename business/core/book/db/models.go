@@ -12,6 +12,24 @@ type Book struct {
 	Author          sql.NullString `db:"author"`
 	PublicationYear sql.NullString `db:"publication_year"`
 	Publisher       sql.NullString `db:"publisher"`
+	CoverURL        sql.NullString `db:"cover_url"`
 	CreatedAt       time.Time      `db:"created_at"`
 	UpdatedAt       sql.NullTime   `db:"updated_at"`
 }
+
+// Filter holds optional criteria used while querying books.
+// Empty fields are not taken into account.
+type Filter struct {
+	Isbn           string
+	Title          string
+	Author         string
+	Publisher      string
+	Search         string
+	ExactAuthor    string
+	ExactPublisher string
+}
+
+type Facet struct {
+	Name  string `db:"name"`
+	Count int    `db:"count"`
+}
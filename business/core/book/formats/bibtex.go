@@ -0,0 +1,65 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// BibTeX encodes books as @book entries.
+type BibTeX struct{}
+
+func (BibTeX) Name() string      { return "bibtex" }
+func (BibTeX) MediaType() string { return "application/x-bibtex" }
+func (BibTeX) Extension() string { return "bib" }
+
+func (BibTeX) NewWriter(w io.Writer) Writer {
+	return &bibtexWriter{w: bufio.NewWriter(w)}
+}
+
+type bibtexWriter struct {
+	w *bufio.Writer
+}
+
+func (bw *bibtexWriter) Write(b book.Book) error {
+	fields := [][2]string{
+		{"title", b.Title},
+		{"author", str(b.Author)},
+		{"publisher", str(b.Publisher)},
+		{"year", str(b.PublicationYear)},
+		{"isbn", b.Isbn},
+	}
+
+	fmt.Fprintf(bw.w, "@book{bds%d", b.ID)
+	for _, f := range fields {
+		if f[1] == "" {
+			continue
+		}
+		fmt.Fprintf(bw.w, ",\n  %s = {%s}", f[0], bibtexEscape(f[1]))
+	}
+	_, err := bw.w.WriteString("\n}\n\n")
+
+	return err
+}
+
+func (bw *bibtexWriter) Close() error {
+	return bw.w.Flush()
+}
+
+var bibtexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+)
+
+func bibtexEscape(s string) string {
+	return bibtexReplacer.Replace(s)
+}
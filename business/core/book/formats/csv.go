@@ -0,0 +1,169 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// Fields of the new book which can be mapped onto CSV columns.
+const (
+	FieldIsbn            = "isbn"
+	FieldTitle           = "title"
+	FieldAuthor          = "author"
+	FieldPublicationYear = "publication_year"
+	FieldPublisher       = "publisher"
+	FieldCoverURL        = "cover_url"
+)
+
+// ColumnMapping maps book fields onto CSV columns. A column is referenced either
+// by its zero based index or by its name from the header row.
+type ColumnMapping map[string]string
+
+// ParseMapping parses mapping in the form of "isbn=0,title=Book-Title,...".
+func ParseMapping(s string) (ColumnMapping, error) {
+	m := make(ColumnMapping)
+
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		field, column, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("mapping entry %q is not valid: expected field=column", pair)
+		}
+
+		field = strings.TrimSpace(field)
+		switch field {
+		case FieldIsbn, FieldTitle, FieldAuthor, FieldPublicationYear, FieldPublisher, FieldCoverURL:
+		default:
+			return nil, fmt.Errorf("mapping entry %q is not valid: unknown field %s", pair, field)
+		}
+
+		m[field] = strings.TrimSpace(column)
+	}
+
+	return m, nil
+}
+
+// CSV decodes books out of comma separated values.
+type CSV struct {
+	// Mapping selects columns holding book fields.
+	Mapping ColumnMapping
+
+	// Header tells whether the first row contains column names.
+	Header bool
+
+	// Comma is the field delimiter. Comma (',') is used when not set.
+	Comma rune
+}
+
+// DefaultCSV understands the Book-Crossing dataset layout.
+var DefaultCSV = CSV{
+	Mapping: ColumnMapping{
+		FieldIsbn:            "0",
+		FieldTitle:           "1",
+		FieldAuthor:          "2",
+		FieldPublicationYear: "3",
+		FieldPublisher:       "4",
+		FieldCoverURL:        "7",
+	},
+	Header: true,
+}
+
+func (CSV) Name() string { return "csv" }
+
+// Detect accepts any textual input as CSV doesn't have any signature.
+func (CSV) Detect(head []byte) bool {
+	return len(bytes.TrimSpace(head)) > 0 && !bytes.ContainsRune(head, 0)
+}
+
+func (c CSV) NewReader(r io.Reader) Reader {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	if c.Comma != 0 {
+		cr.Comma = c.Comma
+	}
+	return &csvReader{r: cr, cfg: c}
+}
+
+type csvReader struct {
+	r       *csv.Reader
+	cfg     CSV
+	columns map[string]int
+	count   int
+}
+
+func (cr *csvReader) Read() (book.NewBook, error) {
+	if cr.columns == nil {
+		if err := cr.resolve(); err != nil {
+			return book.NewBook{}, err
+		}
+	}
+
+	row, err := cr.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			cr.count++
+			return book.NewBook{}, &RecordError{Record: cr.count, Err: err}
+		}
+		return book.NewBook{}, err
+	}
+	cr.count++
+
+	get := func(field string) string {
+		idx, ok := cr.columns[field]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	return book.NewBook{
+		Isbn:            get(FieldIsbn),
+		Title:           get(FieldTitle),
+		Author:          get(FieldAuthor),
+		PublicationYear: get(FieldPublicationYear),
+		Publisher:       get(FieldPublisher),
+		CoverURL:        get(FieldCoverURL),
+	}, nil
+}
+
+// resolve turns the configured mapping into column indexes, consuming the header row if present.
+func (cr *csvReader) resolve() error {
+	names := make(map[string]int)
+
+	if cr.cfg.Header {
+		header, err := cr.r.Read()
+		if err != nil {
+			return err
+		}
+		for i, name := range header {
+			names[strings.TrimSpace(name)] = i
+		}
+	}
+
+	cr.columns = make(map[string]int, len(cr.cfg.Mapping))
+	for field, column := range cr.cfg.Mapping {
+		if idx, err := strconv.Atoi(column); err == nil {
+			cr.columns[field] = idx
+			continue
+		}
+		idx, ok := names[column]
+		if !ok {
+			return fmt.Errorf("column %q mapped to %s not found in header", column, field)
+		}
+		cr.columns[field] = idx
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package formats
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// DublinCore encodes books as simple Dublin Core records (OAI-PMH oai_dc schema).
+type DublinCore struct{}
+
+func (DublinCore) Name() string      { return "dc" }
+func (DublinCore) MediaType() string { return "application/dc+xml" }
+func (DublinCore) Extension() string { return "xml" }
+
+func (DublinCore) NewWriter(w io.Writer) Writer {
+	return newXMLWriter(w, xml.StartElement{
+		Name: xml.Name{Local: "records"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "xmlns:oai_dc"}, Value: "http://www.openarchives.org/OAI/2.0/oai_dc/"},
+			{Name: xml.Name{Local: "xmlns:dc"}, Value: "http://purl.org/dc/elements/1.1/"},
+		},
+	}, func(b book.Book) (any, error) {
+		return newDCRecord(b), nil
+	})
+}
+
+// encoding/xml doesn't support namespace prefixes, so they are kept in local names.
+type dcRecord struct {
+	XMLName    xml.Name `xml:"oai_dc:dc"`
+	Title      string   `xml:"dc:title"`
+	Creator    string   `xml:"dc:creator,omitempty"`
+	Publisher  string   `xml:"dc:publisher,omitempty"`
+	Date       string   `xml:"dc:date,omitempty"`
+	Type       string   `xml:"dc:type"`
+	Identifier []string `xml:"dc:identifier"`
+}
+
+func newDCRecord(b book.Book) dcRecord {
+	return dcRecord{
+		Title:     b.Title,
+		Creator:   str(b.Author),
+		Publisher: str(b.Publisher),
+		Date:      str(b.PublicationYear),
+		Type:      "Text",
+		Identifier: []string{
+			"urn:isbn:" + strings.TrimSpace(b.Isbn),
+			"bds:book:" + itoa(b.ID),
+		},
+	}
+}
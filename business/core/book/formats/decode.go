@@ -0,0 +1,158 @@
+package formats
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// Decoder describes a single bibliographic input format.
+type Decoder interface {
+	// Name is a short identifier of the format, e.g. marcxml.
+	Name() string
+
+	// Detect reports whether the beginning of the input looks like the format.
+	Detect(head []byte) bool
+
+	// NewReader returns a Reader decoding books out of r.
+	NewReader(r io.Reader) Reader
+}
+
+// Reader decodes a stream of books. Read returns io.EOF once the input is exhausted.
+// Errors related to a single malformed record are reported as RecordError, so
+// the caller can skip the record and keep reading.
+type Reader interface {
+	Read() (book.NewBook, error)
+}
+
+// RecordError reports a record which can't be decoded.
+type RecordError struct {
+	Record int
+	Err    error
+}
+
+func (re *RecordError) Error() string {
+	return "record " + itoa(re.Record) + ": " + re.Err.Error()
+}
+
+func (re *RecordError) Unwrap() error {
+	return re.Err
+}
+
+var decoders = make(map[string]Decoder)
+
+// RegisterDecoder makes the decoder available under its name.
+func RegisterDecoder(dec Decoder) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	decoders[dec.Name()] = dec
+}
+
+// LookupDecoder returns the decoder registered under the given name.
+func LookupDecoder(name string) (Decoder, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	dec, ok := decoders[name]
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+	return dec, nil
+}
+
+// DecoderNames returns names of all the registered decoders in stable order.
+func DecoderNames() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(decoders))
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Detect returns the decoder recognizing the beginning of the input.
+// CSV doesn't have any reliable signature, so it is used as the last resort.
+func Detect(head []byte) (Decoder, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, name := range []string{"marc", "marcxml", "onix"} {
+		if dec, ok := decoders[name]; ok && dec.Detect(head) {
+			return dec, nil
+		}
+	}
+
+	if dec, ok := decoders["csv"]; ok && dec.Detect(head) {
+		return dec, nil
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+func init() {
+	RegisterDecoder(MARC{})
+	RegisterDecoder(MARCXML{})
+	RegisterDecoder(ONIX{})
+	RegisterDecoder(DefaultCSV)
+}
+
+// private
+
+// rootElement returns the local name of the first element found in XML input.
+func rootElement(head []byte) string {
+	for {
+		i := bytes.IndexByte(head, '<')
+		if i < 0 || i+1 >= len(head) {
+			return ""
+		}
+		head = head[i+1:]
+
+		// Skip declarations, processing instructions and comments.
+		if head[0] == '?' || head[0] == '!' {
+			continue
+		}
+
+		end := bytes.IndexAny(head, " \t\r\n/>")
+		if end < 0 {
+			return ""
+		}
+		name := string(head[:end])
+		if _, local, ok := strings.Cut(name, ":"); ok {
+			name = local
+		}
+		return name
+	}
+}
+
+// firstToken trims qualifiers often recorded next to identifiers, e.g. "0195153448 (pbk.)".
+func firstToken(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, " \t("); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// year extracts the first four digits number out of free text dates, e.g. "c2002." or "20020115".
+func year(s string) string {
+	run := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			run++
+			if run == 4 {
+				return s[i-3 : i+1]
+			}
+			continue
+		}
+		run = 0
+	}
+	return ""
+}
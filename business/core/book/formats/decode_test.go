@@ -0,0 +1,365 @@
+package formats_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/book/formats"
+)
+
+func TestDecoders(t *testing.T) {
+	dune := iso2709(
+		[2]string{"001", "1"},
+		[2]string{"020", "  \x1fa0441013597 (pbk.)"},
+		[2]string{"100", "1 \x1faHerbert, Frank,"},
+		[2]string{"245", "10\x1faDune :\x1fbthe novel /"},
+		[2]string{"264", " 1\x1fbAce Books,\x1fcc2005."},
+	)
+	solaris := iso2709(
+		[2]string{"001", "2"},
+		[2]string{"008", "850101s1961    pl"},
+		[2]string{"020", "  \x1fa9788308049218"},
+		[2]string{"110", "2 \x1faWydawnictwo MON."},
+		[2]string{"245", "00\x1faSolaris."},
+	)
+	shortField := iso2709(
+		[2]string{"001", "3"},
+		[2]string{"245", "1"},
+	)
+
+	// The base address of the data can't be read, the record length still can.
+	badBase := []byte(dune)
+	copy(badBase[12:17], "xxxxx")
+
+	duneBook := book.NewBook{Isbn: "0441013597", Title: "Dune: the novel", Author: "Herbert, Frank", PublicationYear: "2005", Publisher: "Ace Books"}
+	solarisBook := book.NewBook{Isbn: "9788308049218", Title: "Solaris", Author: "Wydawnictwo MON", PublicationYear: "1961"}
+
+	const marcxml = `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000nam a2200000   4500</leader>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780441013593</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Herbert, Frank.</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Dune /</subfield></datafield>
+    <datafield tag="260" ind1=" " ind2=" "><subfield code="b">Chilton,</subfield><subfield code="c">1965.</subfield></datafield>
+  </record>
+  <record>
+    <controlfield tag="008">850101s1972    ru</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780575094185</subfield></datafield>
+    <datafield tag="700" ind1="1" ind2=" "><subfield code="a">Strugatsky, Arkady,</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Roadside picnic</subfield></datafield>
+  </record>
+</collection>`
+
+	const onix = `<?xml version="1.0" encoding="UTF-8"?>
+<ONIXMessage xmlns="http://ns.editeur.org/onix/3.0/reference" release="3.0">
+  <Header><Sender><SenderName>Ace</SenderName></Sender></Header>
+  <Product>
+    <ProductIdentifier><ProductIDType>02</ProductIDType><IDValue>0441478123</IDValue></ProductIdentifier>
+    <ProductIdentifier><ProductIDType>15</ProductIDType><IDValue>9780441478125</IDValue></ProductIdentifier>
+    <DescriptiveDetail>
+      <TitleDetail>
+        <TitleType>01</TitleType>
+        <TitleElement><TitleElementLevel>02</TitleElementLevel><TitleText>Hainish Cycle</TitleText></TitleElement>
+        <TitleElement><TitleElementLevel>01</TitleElementLevel><TitlePrefix>The</TitlePrefix><TitleWithoutPrefix>Left Hand of Darkness</TitleWithoutPrefix></TitleElement>
+      </TitleDetail>
+      <Contributor><ContributorRole>B01</ContributorRole><PersonName>Harold Bloom</PersonName></Contributor>
+      <Contributor><ContributorRole>A01</ContributorRole><NamesBeforeKey>Ursula K.</NamesBeforeKey><KeyNames>Le Guin</KeyNames></Contributor>
+    </DescriptiveDetail>
+    <CollateralDetail>
+      <SupportingResource>
+        <ResourceContentType>01</ResourceContentType>
+        <ResourceVersion><ResourceLink>https://example.com/covers/9780441478125.jpg</ResourceLink></ResourceVersion>
+      </SupportingResource>
+    </CollateralDetail>
+    <PublishingDetail>
+      <Publisher><PublishingRole>01</PublishingRole><PublisherName>Ace</PublisherName></Publisher>
+      <PublishingDate><PublishingDateRole>01</PublishingDateRole><Date>19690301</Date></PublishingDate>
+    </PublishingDetail>
+  </Product>
+  <Product>
+    <ProductIdentifier><ProductIDType>03</ProductIDType><IDValue>9780575094185</IDValue></ProductIdentifier>
+    <DescriptiveDetail>
+      <TitleDetail>
+        <TitleType>01</TitleType>
+        <TitleElement><TitleElementLevel>01</TitleElementLevel><TitleText>Roadside Picnic</TitleText><Subtitle>A Novel</Subtitle></TitleElement>
+      </TitleDetail>
+      <Contributor><ContributorRole>A01</ContributorRole><PersonName>Arkady Strugatsky</PersonName></Contributor>
+    </DescriptiveDetail>
+  </Product>
+</ONIXMessage>`
+
+	leGuin := book.NewBook{Isbn: "9780441478125", Title: "The Left Hand of Darkness", Author: "Ursula K. Le Guin", PublicationYear: "1969", Publisher: "Ace", CoverURL: "https://example.com/covers/9780441478125.jpg"}
+	picnic := book.NewBook{Isbn: "9780575094185", Title: "Roadside Picnic: A Novel", Author: "Arkady Strugatsky"}
+
+	const bookCrossing = `"ISBN","Book-Title","Book-Author","Year-Of-Publication","Publisher","Image-URL-S","Image-URL-M","Image-URL-L"
+0195153448,Classical Mythology,Mark P. O. Morford,2002,Oxford University Press,s.jpg,m.jpg,l.jpg
+0002005018,Clara Callan
+0060973129,The "Decision" in Normandy, Carlo D'Este ,1991,HarperPerennial,,,
+`
+
+	tests := []struct {
+		name    string
+		dec     formats.Decoder
+		input   string
+		want    []book.NewBook
+		skipped []int
+		fails   bool
+	}{
+		{
+			name:  "marc",
+			dec:   formats.MARC{},
+			input: dune + "\r\n" + solaris,
+			want:  []book.NewBook{duneBook, solarisBook},
+		},
+		{
+			name:    "marc skips malformed records",
+			dec:     formats.MARC{},
+			input:   dune + string(badBase) + shortField + solaris,
+			want:    []book.NewBook{duneBook, solarisBook},
+			skipped: []int{2, 3},
+		},
+		{
+			name:  "marc with invalid record length",
+			dec:   formats.MARC{},
+			input: dune + "x" + solaris[1:],
+			want:  []book.NewBook{duneBook},
+			fails: true,
+		},
+		{
+			name:  "marc truncated",
+			dec:   formats.MARC{},
+			input: dune + solaris[:40],
+			want:  []book.NewBook{duneBook},
+			fails: true,
+		},
+		{
+			name:  "marcxml",
+			dec:   formats.MARCXML{},
+			input: marcxml,
+			want: []book.NewBook{
+				{Isbn: "9780441013593", Title: "Dune", Author: "Herbert, Frank", PublicationYear: "1965", Publisher: "Chilton"},
+				{Isbn: "9780575094185", Title: "Roadside picnic", Author: "Strugatsky, Arkady", PublicationYear: "1972"},
+			},
+		},
+		{
+			name:  "marcxml single record",
+			dec:   formats.MARCXML{},
+			input: `<record xmlns="http://www.loc.gov/MARC21/slim"><datafield tag="245" ind1="0" ind2="0"><subfield code="a">Solaris</subfield></datafield></record>`,
+			want:  []book.NewBook{{Title: "Solaris"}},
+		},
+		{
+			name:  "marcxml malformed",
+			dec:   formats.MARCXML{},
+			input: strings.Replace(marcxml, "</record>\n</collection>", "</collection>", 1),
+			want: []book.NewBook{
+				{Isbn: "9780441013593", Title: "Dune", Author: "Herbert, Frank", PublicationYear: "1965", Publisher: "Chilton"},
+			},
+			fails: true,
+		},
+		{
+			name:  "onix",
+			dec:   formats.ONIX{},
+			input: onix,
+			want:  []book.NewBook{leGuin, picnic},
+		},
+		{
+			name:  "onix truncated",
+			dec:   formats.ONIX{},
+			input: onix[:strings.LastIndex(onix, "<Product>")+len("<Product><ProductIdentifier>")],
+			want:  []book.NewBook{leGuin},
+			fails: true,
+		},
+		{
+			name:  "csv book-crossing",
+			dec:   formats.DefaultCSV,
+			input: bookCrossing,
+			want: []book.NewBook{
+				{Isbn: "0195153448", Title: "Classical Mythology", Author: "Mark P. O. Morford", PublicationYear: "2002", Publisher: "Oxford University Press", CoverURL: "l.jpg"},
+				{Isbn: "0002005018", Title: "Clara Callan"},
+				{Isbn: "0060973129", Title: `The "Decision" in Normandy`, Author: "Carlo D'Este", PublicationYear: "1991", Publisher: "HarperPerennial"},
+			},
+		},
+		{
+			name: "csv mapped by name",
+			dec: formats.CSV{
+				Mapping: formats.ColumnMapping{formats.FieldIsbn: "isbn", formats.FieldTitle: "name", formats.FieldPublicationYear: "2"},
+				Header:  true,
+				Comma:   ';',
+			},
+			input: "name;isbn;year\nDune;9780441013593;1965\n",
+			want:  []book.NewBook{{Isbn: "9780441013593", Title: "Dune", PublicationYear: "1965"}},
+		},
+		{
+			name:  "csv without header",
+			dec:   formats.CSV{Mapping: formats.ColumnMapping{formats.FieldIsbn: "1", formats.FieldTitle: "0"}},
+			input: "Dune,9780441013593\nSolaris,9788308049218\n",
+			want:  []book.NewBook{{Isbn: "9780441013593", Title: "Dune"}, {Isbn: "9788308049218", Title: "Solaris"}},
+		},
+		{
+			name:  "csv column missing in header",
+			dec:   formats.CSV{Mapping: formats.ColumnMapping{formats.FieldIsbn: "isbn"}, Header: true},
+			input: "title\nDune\n",
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, skipped, err := decodeAll(tt.dec.NewReader(strings.NewReader(tt.input)))
+
+			if tt.fails && err == nil {
+				t.Errorf("expected decoding to fail")
+			}
+			if !tt.fails && err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected\n%+v\ngot\n%+v", tt.want, got)
+			}
+			if !reflect.DeepEqual(skipped, tt.skipped) {
+				t.Errorf("expected records %v to be skipped, got %v", tt.skipped, skipped)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	marc := iso2709([2]string{"245", "00\x1faDune"})
+
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"marc", marc, "marc"},
+		{"marcxml collection", `<?xml version="1.0"?><collection xmlns="http://www.loc.gov/MARC21/slim">`, "marcxml"},
+		{"marcxml record after comment", `<!-- export --><marc:record xmlns:marc="http://www.loc.gov/MARC21/slim">`, "marcxml"},
+		{"onix", `<?xml version="1.0"?>` + "\n" + `<ONIXMessage release="3.0">`, "onix"},
+		{"onix with prefix", `<onix:ONIXMessage xmlns:onix="http://ns.editeur.org/onix/3.0/reference">`, "onix"},
+		{"xml of other namespace falls back to csv", `<collection xmlns="urn:example">`, "csv"},
+		{"csv", "isbn,title\n9780441013593,Dune\n", "csv"},
+		{"empty", " \n", ""},
+		{"binary", "\x89PNG\r\n\x1a\n\x00\x00", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := formats.Detect([]byte(tt.head))
+			if tt.want == "" {
+				if !errors.Is(err, formats.ErrUnknownFormat) {
+					t.Errorf("expected %v, got %v", formats.ErrUnknownFormat, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected %s, got %v", tt.want, err)
+			}
+			if dec.Name() != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, dec.Name())
+			}
+		})
+	}
+}
+
+func TestDecoderNames(t *testing.T) {
+	want := []string{"csv", "marc", "marcxml", "onix"}
+	if got := formats.DecoderNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		want  formats.ColumnMapping
+		fails bool
+	}{
+		{
+			name: "indexes and names",
+			s:    "isbn=0,title=Book-Title,cover_url=7",
+			want: formats.ColumnMapping{"isbn": "0", "title": "Book-Title", "cover_url": "7"},
+		},
+		{
+			name: "spaces and empty entries",
+			s:    " isbn = 0 ,, author=Book Author ,",
+			want: formats.ColumnMapping{"isbn": "0", "author": "Book Author"},
+		},
+		{
+			name: "empty",
+			s:    "",
+			want: formats.ColumnMapping{},
+		},
+		{
+			name:  "missing column",
+			s:     "isbn=0,title",
+			fails: true,
+		},
+		{
+			name:  "unknown field",
+			s:     "isbn=0,price=3",
+			fails: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formats.ParseMapping(tt.s)
+			if tt.fails {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// decodeAll reads books the way importers do: records failing with RecordError are
+// skipped, any other error stops reading.
+func decodeAll(r formats.Reader) (books []book.NewBook, skipped []int, err error) {
+	for {
+		b, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return books, skipped, nil
+		}
+		var re *formats.RecordError
+		if errors.As(err, &re) {
+			skipped = append(skipped, re.Record)
+			continue
+		}
+		if err != nil {
+			return books, skipped, err
+		}
+		books = append(books, b)
+	}
+}
+
+// iso2709 assembles a binary MARC record out of tags and field values without terminators.
+func iso2709(fields ...[2]string) string {
+	var dir, data bytes.Buffer
+	for _, f := range fields {
+		value := f[1] + "\x1e"
+		fmt.Fprintf(&dir, "%s%04d%05d", f[0], len(value), data.Len())
+		data.WriteString(value)
+	}
+	dir.WriteByte(0x1e)
+
+	base := 24 + dir.Len()
+	length := base + data.Len() + 1
+
+	return fmt.Sprintf("%05dnam a22%05d   4500", length, base) + dir.String() + data.String() + "\x1d"
+}
@@ -0,0 +1,123 @@
+// Package formats provides bibliographic encoders for books.
+// New formats register themselves with Register, so handlers don't need
+// to know about any concrete encoding.
+package formats
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+var ErrUnknownFormat = errors.New("format is not known")
+
+// Encoder describes a single bibliographic format.
+type Encoder interface {
+	// Name is a short identifier of the format, e.g. bibtex.
+	Name() string
+
+	// MediaType is the content type produced by the encoder.
+	MediaType() string
+
+	// Extension is the file extension used while exporting to file.
+	Extension() string
+
+	// NewWriter returns a Writer encoding books into w.
+	NewWriter(w io.Writer) Writer
+}
+
+// Writer encodes a stream of books. Close must be called once all books
+// have been written, so the format can be properly terminated.
+type Writer interface {
+	Write(b book.Book) error
+	Close() error
+}
+
+var (
+	mu       sync.RWMutex
+	encoders = make(map[string]Encoder)
+)
+
+// Register makes the encoder available under its name and media type.
+// Registering the same name twice replaces previous encoder.
+func Register(enc Encoder) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	encoders[enc.Name()] = enc
+}
+
+// Lookup returns the encoder registered under the given name.
+func Lookup(name string) (Encoder, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	enc, ok := encoders[name]
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+	return enc, nil
+}
+
+// ByMediaType returns the encoder producing the given media type.
+func ByMediaType(mediaType string) (Encoder, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, enc := range encoders {
+		if enc.MediaType() == mediaType {
+			return enc, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// MediaTypes returns media types of all the registered encoders in stable order.
+func MediaTypes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	mts := make([]string, 0, len(encoders))
+	for _, enc := range encoders {
+		mts = append(mts, enc.MediaType())
+	}
+	sort.Strings(mts)
+
+	return mts
+}
+
+// Encode writes all the books into w using the given encoder.
+func Encode(w io.Writer, enc Encoder, books ...book.Book) error {
+	bw := enc.NewWriter(w)
+	for _, b := range books {
+		if err := bw.Write(b); err != nil {
+			return err
+		}
+	}
+	return bw.Close()
+}
+
+func init() {
+	Register(MARC{})
+	Register(MARCXML{})
+	Register(DublinCore{})
+	Register(BibTeX{})
+	Register(RIS{})
+}
+
+// private
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func itoa(i int) string {
+	return strconv.Itoa(i)
+}
@@ -0,0 +1,184 @@
+package formats_test
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/book/formats"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// books cover optional fields left empty and values which have to be escaped.
+var books = []book.Book{
+	{
+		ID:              1,
+		Isbn:            "9780441013593",
+		Title:           "Dune",
+		Author:          ptr("Frank Herbert"),
+		PublicationYear: ptr("1965"),
+		Publisher:       ptr("Chilton Books"),
+	},
+	{
+		ID:        2,
+		Isbn:      "9788308049218",
+		Title:     `Solaris & <Eden> {50% off} #1_\`,
+		Publisher: ptr("Wydawnictwo Literackie"),
+	},
+	{
+		ID:              3,
+		Isbn:            "9780575094185",
+		Title:           "Roadside Picnic",
+		Author:          ptr("Arkady Strugatsky"),
+		PublicationYear: ptr("c1972"),
+	},
+}
+
+// TestEncoders fails when the output of any encoder drifts from the committed one.
+// Run go test -update after changing a format, then review the diff.
+func TestEncoders(t *testing.T) {
+	tests := []struct {
+		enc       formats.Encoder
+		mediaType string
+	}{
+		{formats.MARC{}, "application/marc"},
+		{formats.MARCXML{}, "application/marcxml+xml"},
+		{formats.DublinCore{}, "application/dc+xml"},
+		{formats.BibTeX{}, "application/x-bibtex"},
+		{formats.RIS{}, "application/x-research-info-systems"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.enc.Name(), func(t *testing.T) {
+			var got bytes.Buffer
+			if err := formats.Encode(&got, tt.enc, books...); err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+
+			golden := filepath.Join("testdata", tt.enc.Name()+".golden")
+
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0o644); err != nil {
+					t.Fatalf("updating golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file: %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("output differs from %s, run go test -update and review the diff:\n%s", golden, got.String())
+			}
+
+			enc, err := formats.ByMediaType(tt.mediaType)
+			if err != nil || enc.Name() != tt.enc.Name() {
+				t.Errorf("expected %s registered for %s, got %v, %v", tt.enc.Name(), tt.mediaType, enc, err)
+			}
+		})
+	}
+}
+
+// TestEncodeEmpty checks that formats with the root element are terminated without books too.
+func TestEncodeEmpty(t *testing.T) {
+	tests := []struct {
+		enc  formats.Encoder
+		want string
+	}{
+		{formats.MARC{}, ""},
+		{formats.MARCXML{}, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<collection xmlns="http://www.loc.gov/MARC21/slim"></collection>`},
+		{formats.BibTeX{}, ""},
+		{formats.RIS{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.enc.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := formats.Encode(&buf, tt.enc); err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestMARCRoundTrip reads the encoded records back with the decoders of the same format.
+func TestMARCRoundTrip(t *testing.T) {
+	want := []book.NewBook{
+		{Isbn: "9780441013593", Title: "Dune", Author: "Frank Herbert", PublicationYear: "1965", Publisher: "Chilton Books"},
+		{Isbn: "9788308049218", Title: `Solaris & <Eden> {50% off} #1_\`, Publisher: "Wydawnictwo Literackie"},
+		{Isbn: "9780575094185", Title: "Roadside Picnic", Author: "Arkady Strugatsky", PublicationYear: "1972"},
+	}
+
+	for _, enc := range []formats.Encoder{formats.MARC{}, formats.MARCXML{}} {
+		t.Run(enc.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := formats.Encode(&buf, enc, books...); err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+
+			dec, err := formats.Detect(buf.Bytes())
+			if err != nil {
+				t.Fatalf("detecting format: %v", err)
+			}
+			if dec.Name() != enc.Name() {
+				t.Fatalf("expected %s to be detected, got %s", enc.Name(), dec.Name())
+			}
+
+			got := readAll(t, dec.NewReader(&buf))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected\n%+v\ngot\n%+v", want, got)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"marc", "marcxml", "dc", "bibtex", "ris"} {
+		enc, err := formats.Lookup(name)
+		if err != nil {
+			t.Errorf("%s: expected encoder, got %v", name, err)
+			continue
+		}
+		if enc.Name() != name {
+			t.Errorf("expected encoder %s, got %s", name, enc.Name())
+		}
+	}
+
+	if _, err := formats.Lookup("pdf"); !errors.Is(err, formats.ErrUnknownFormat) {
+		t.Errorf("expected %v, got %v", formats.ErrUnknownFormat, err)
+	}
+	if _, err := formats.ByMediaType("application/pdf"); !errors.Is(err, formats.ErrUnknownFormat) {
+		t.Errorf("expected %v, got %v", formats.ErrUnknownFormat, err)
+	}
+}
+
+// readAll reads every book, failing the test on any error.
+func readAll(t *testing.T, r formats.Reader) []book.NewBook {
+	t.Helper()
+
+	var result []book.NewBook
+	for {
+		b, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return result
+		}
+		if err != nil {
+			t.Fatalf("reading: %v", err)
+		}
+		result = append(result, b)
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
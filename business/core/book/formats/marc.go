@@ -0,0 +1,253 @@
+package formats
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// MARC encodes books as MARC21 records in ISO 2709 transmission format.
+type MARC struct{}
+
+func (MARC) Name() string      { return "marc" }
+func (MARC) MediaType() string { return "application/marc" }
+func (MARC) Extension() string { return "mrc" }
+
+func (MARC) NewWriter(w io.Writer) Writer {
+	return &marcWriter{w: bufio.NewWriter(w)}
+}
+
+type marcWriter struct {
+	w *bufio.Writer
+}
+
+func (mw *marcWriter) Write(b book.Book) error {
+	data, err := newRecord(b).binary()
+	if err != nil {
+		return fmt.Errorf("book %d: %w", b.ID, err)
+	}
+	_, err = mw.w.Write(data)
+	return err
+}
+
+func (mw *marcWriter) Close() error {
+	return mw.w.Flush()
+}
+
+// MARCXML encodes books as MARC21 records in the MARCXML slim schema.
+type MARCXML struct{}
+
+func (MARCXML) Name() string      { return "marcxml" }
+func (MARCXML) MediaType() string { return "application/marcxml+xml" }
+func (MARCXML) Extension() string { return "xml" }
+
+func (MARCXML) NewWriter(w io.Writer) Writer {
+	return newXMLWriter(w, xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: MARCXMLNamespace}},
+	}, func(b book.Book) (any, error) {
+		rec, err := newRecord(b).xml()
+		if err != nil {
+			return nil, fmt.Errorf("book %d: %w", b.ID, err)
+		}
+		return rec, nil
+	})
+}
+
+// MARCXMLNamespace is the namespace of the MARCXML slim schema.
+const MARCXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+// =============================================================================
+// MARC record
+
+// ISO 2709 delimiters.
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+// ISO 2709 limits, set by the length slots of the leader and the directory.
+const (
+	maxFieldLength  = 9999
+	maxRecordLength = 99999
+)
+
+// ErrRecordTooLong is returned for books which don't fit into ISO 2709 record,
+// or any of its fields.
+var ErrRecordTooLong = errors.New("record is too long for ISO 2709")
+
+type controlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type subfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+type dataField struct {
+	Tag       string     `xml:"tag,attr"`
+	Ind1      string     `xml:"ind1,attr"`
+	Ind2      string     `xml:"ind2,attr"`
+	Subfields []subfield `xml:"subfield"`
+}
+
+type record struct {
+	controlFields []controlField
+	dataFields    []dataField
+}
+
+type xmlRecord struct {
+	XMLName       xml.Name       `xml:"record"`
+	Leader        string         `xml:"leader"`
+	ControlFields []controlField `xml:"controlfield"`
+	DataFields    []dataField    `xml:"datafield"`
+}
+
+// newRecord maps a book onto the minimal MARC21 bibliographic record.
+func newRecord(b book.Book) record {
+	var r record
+
+	year := clean(str(b.PublicationYear))
+
+	r.controlFields = []controlField{
+		{Tag: "001", Value: strconv.Itoa(b.ID)},
+		{Tag: "003", Value: "bds"},
+		{Tag: "008", Value: fixedData(year)},
+	}
+
+	r.dataFields = append(r.dataFields, dataField{
+		Tag: "020", Ind1: " ", Ind2: " ",
+		Subfields: []subfield{{Code: "a", Value: clean(b.Isbn)}},
+	})
+
+	// The first indicator of the title field tells whether the main entry (author) exists.
+	titleInd := "0"
+	if author := clean(str(b.Author)); author != "" {
+		titleInd = "1"
+		r.dataFields = append(r.dataFields, dataField{
+			Tag: "100", Ind1: "1", Ind2: " ",
+			Subfields: []subfield{{Code: "a", Value: author}},
+		})
+	}
+
+	r.dataFields = append(r.dataFields, dataField{
+		Tag: "245", Ind1: titleInd, Ind2: "0",
+		Subfields: []subfield{{Code: "a", Value: clean(b.Title)}},
+	})
+
+	var imprint []subfield
+	if publisher := clean(str(b.Publisher)); publisher != "" {
+		imprint = append(imprint, subfield{Code: "b", Value: publisher})
+	}
+	if year != "" {
+		imprint = append(imprint, subfield{Code: "c", Value: year})
+	}
+	if len(imprint) > 0 {
+		r.dataFields = append(r.dataFields, dataField{
+			Tag: "260", Ind1: " ", Ind2: " ",
+			Subfields: imprint,
+		})
+	}
+
+	return r
+}
+
+// clean replaces control characters, ISO 2709 delimiters among them, with spaces,
+// so values can't break the structure of the record.
+func clean(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7F {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// fixedData builds the 40 characters long 008 field for books.
+func fixedData(year string) string {
+	dateType, date1 := "n", "uuuu"
+	if _, err := strconv.Atoi(year); err == nil && len(year) == 4 {
+		dateType, date1 = "s", year
+	}
+
+	// 00-05 date entered, 06 type of date, 07-10 date 1, 11-14 date 2,
+	// 15-17 place, 18-34 book specific, 35-37 language, 38 modified, 39 source.
+	return "||||||" + dateType + date1 + "    " + "xx " + "                 " + "und" + " " + "d"
+}
+
+// leader builds the record leader for the given record length and base address of data.
+func leader(length, base int) string {
+	return fmt.Sprintf("%05dnam a22%05d   4500", length, base)
+}
+
+// binary encodes the record in ISO 2709 format. Lengths and offsets have fixed size
+// slots, so fields and records exceeding them are reported with ErrRecordTooLong.
+func (r record) binary() ([]byte, error) {
+	var directory, data []byte
+
+	add := func(tag string, field []byte) error {
+		field = append(field, fieldTerminator)
+		if len(field) > maxFieldLength {
+			return fmt.Errorf("field %s has %d bytes: %w", tag, len(field), ErrRecordTooLong)
+		}
+		directory = append(directory, fmt.Sprintf("%s%04d%05d", tag, len(field), len(data))...)
+		data = append(data, field...)
+		return nil
+	}
+
+	for _, cf := range r.controlFields {
+		if err := add(cf.Tag, []byte(cf.Value)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, df := range r.dataFields {
+		field := []byte(df.Ind1 + df.Ind2)
+		for _, sf := range df.Subfields {
+			field = append(field, subfieldDelimiter)
+			field = append(field, sf.Code...)
+			field = append(field, sf.Value...)
+		}
+		if err := add(df.Tag, field); err != nil {
+			return nil, err
+		}
+	}
+
+	directory = append(directory, fieldTerminator)
+
+	base := 24 + len(directory)
+	length := base + len(data) + 1
+	if length > maxRecordLength {
+		return nil, fmt.Errorf("record has %d bytes: %w", length, ErrRecordTooLong)
+	}
+
+	out := make([]byte, 0, length)
+	out = append(out, leader(length, base)...)
+	out = append(out, directory...)
+	out = append(out, data...)
+	out = append(out, recordTerminator)
+
+	return out, nil
+}
+
+func (r record) xml() (xmlRecord, error) {
+	// Leader of MARCXML record describes its binary counterpart.
+	bin, err := r.binary()
+	if err != nil {
+		return xmlRecord{}, err
+	}
+	return xmlRecord{
+		Leader:        string(bin[:24]),
+		ControlFields: r.controlFields,
+		DataFields:    r.dataFields,
+	}, nil
+}
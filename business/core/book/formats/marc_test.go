@@ -0,0 +1,70 @@
+package formats_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/book/formats"
+)
+
+func TestMARCDelimitersInValues(t *testing.T) {
+	author := "Frank\x1fHerbert\x1e"
+	b := book.Book{ID: 7, Isbn: "9780441013593", Title: "Dune\x1d Messiah", Author: &author}
+
+	for _, enc := range []formats.Encoder{formats.MARC{}, formats.MARCXML{}} {
+		t.Run(enc.Name(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := formats.Encode(&buf, enc, b); err != nil {
+				t.Fatalf("encoding: %v", err)
+			}
+
+			dec, err := formats.LookupDecoder(enc.Name())
+			if err != nil {
+				t.Fatalf("looking up decoder: %v", err)
+			}
+			got, err := dec.NewReader(&buf).Read()
+			if err != nil {
+				t.Fatalf("decoding: %v", err)
+			}
+
+			if got.Title != "Dune  Messiah" {
+				t.Errorf("expected title %q, got %q", "Dune  Messiah", got.Title)
+			}
+			if got.Author != "Frank Herbert" {
+				t.Errorf("expected author %q, got %q", "Frank Herbert", got.Author)
+			}
+		})
+	}
+}
+
+func TestMARCTooLong(t *testing.T) {
+	// The title field holds both indicators, the subfield delimiter and code, and the
+	// field terminator on top of the title, 5 bytes in total.
+	tests := []struct {
+		name    string
+		title   int
+		tooLong bool
+	}{
+		{"longest field", 9999 - 5, false},
+		{"field over limit", 9999 - 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := book.Book{ID: 1, Isbn: "9780441013593", Title: strings.Repeat("a", tt.title)}
+
+			for _, enc := range []formats.Encoder{formats.MARC{}, formats.MARCXML{}} {
+				err := formats.Encode(&bytes.Buffer{}, enc, b)
+				switch {
+				case tt.tooLong && !errors.Is(err, formats.ErrRecordTooLong):
+					t.Errorf("%s: expected %v, got %v", enc.Name(), formats.ErrRecordTooLong, err)
+				case !tt.tooLong && err != nil:
+					t.Errorf("%s: expected no error, got %v", enc.Name(), err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+var errMalformedRecord = errors.New("malformed MARC record")
+
+// Detect recognizes ISO 2709 leader: five digits of record length followed by
+// the status, type and the indicator and subfield counts set to 2.
+func (MARC) Detect(head []byte) bool {
+	if len(head) < 24 {
+		return false
+	}
+	for _, c := range head[:5] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return head[10] == '2' && head[11] == '2'
+}
+
+func (MARC) NewReader(r io.Reader) Reader {
+	return &marcReader{r: bufio.NewReader(r)}
+}
+
+type marcReader struct {
+	r     *bufio.Reader
+	count int
+}
+
+func (mr *marcReader) Read() (book.NewBook, error) {
+	// Skip whitespace some tools put in between records.
+	for {
+		c, err := mr.r.ReadByte()
+		if err != nil {
+			return book.NewBook{}, err
+		}
+		if c != '\n' && c != '\r' && c != ' ' {
+			_ = mr.r.UnreadByte()
+			break
+		}
+	}
+
+	mr.count++
+
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(mr.r, head); err != nil {
+		return book.NewBook{}, unexpectedEOF(err)
+	}
+
+	length, err := strconv.Atoi(string(head))
+	if err != nil || length < 25 {
+		return book.NewBook{}, fmt.Errorf("record %d: %w: invalid length", mr.count, errMalformedRecord)
+	}
+
+	data := make([]byte, length)
+	copy(data, head)
+	if _, err = io.ReadFull(mr.r, data[5:]); err != nil {
+		return book.NewBook{}, unexpectedEOF(err)
+	}
+
+	rec, err := parseBinary(data)
+	if err != nil {
+		return book.NewBook{}, &RecordError{Record: mr.count, Err: err}
+	}
+
+	return rec.newBook(), nil
+}
+
+// Detect recognizes MARCXML collection or a single record.
+func (MARCXML) Detect(head []byte) bool {
+	root := rootElement(head)
+	return (root == "collection" || root == "record") && bytes.Contains(head, []byte(MARCXMLNamespace))
+}
+
+func (MARCXML) NewReader(r io.Reader) Reader {
+	return &xmlReader{dec: xml.NewDecoder(r), element: "record", convert: func(d *xml.Decoder, se xml.StartElement) (book.NewBook, error) {
+		var xr xmlRecord
+		if err := d.DecodeElement(&xr, &se); err != nil {
+			return book.NewBook{}, err
+		}
+		return record{controlFields: xr.ControlFields, dataFields: xr.DataFields}.newBook(), nil
+	}}
+}
+
+// private
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func parseBinary(data []byte) (record, error) {
+	base, err := strconv.Atoi(string(data[12:17]))
+	if err != nil || base > len(data) || base < 25 {
+		return record{}, errMalformedRecord
+	}
+
+	var r record
+
+	directory := data[24 : base-1]
+	for i := 0; i+12 <= len(directory); i += 12 {
+		entry := directory[i : i+12]
+		tag := string(entry[:3])
+
+		length, err := strconv.Atoi(string(entry[3:7]))
+		if err != nil {
+			return record{}, errMalformedRecord
+		}
+		start, err := strconv.Atoi(string(entry[7:12]))
+		if err != nil {
+			return record{}, errMalformedRecord
+		}
+
+		from, to := base+start, base+start+length
+		if to > len(data) || length < 1 {
+			return record{}, errMalformedRecord
+		}
+
+		// Drop the field terminator.
+		field := data[from : to-1]
+
+		if strings.HasPrefix(tag, "00") {
+			r.controlFields = append(r.controlFields, controlField{Tag: tag, Value: string(field)})
+			continue
+		}
+
+		if len(field) < 2 {
+			return record{}, errMalformedRecord
+		}
+
+		df := dataField{Tag: tag, Ind1: string(field[0]), Ind2: string(field[1])}
+		for _, sf := range bytes.Split(field[2:], []byte{subfieldDelimiter}) {
+			if len(sf) == 0 {
+				continue
+			}
+			df.Subfields = append(df.Subfields, subfield{Code: string(sf[0]), Value: string(sf[1:])})
+		}
+		r.dataFields = append(r.dataFields, df)
+	}
+
+	return r, nil
+}
+
+// subfield returns the first value of the subfield within the first matching field.
+func (r record) subfield(code string, tags ...string) string {
+	for _, tag := range tags {
+		for _, df := range r.dataFields {
+			if df.Tag != tag {
+				continue
+			}
+			for _, sf := range df.Subfields {
+				if sf.Code == code && sf.Value != "" {
+					return sf.Value
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// newBook maps MARC21 bibliographic record onto the new book.
+func (r record) newBook() book.NewBook {
+	title := trimPunctuation(r.subfield("a", "245"))
+	if sub := trimPunctuation(r.subfield("b", "245")); sub != "" {
+		title += ": " + sub
+	}
+
+	published := year(r.subfield("c", "264", "260"))
+	if published == "" {
+		for _, cf := range r.controlFields {
+			if cf.Tag == "008" && len(cf.Value) >= 11 {
+				published = year(cf.Value[7:11])
+			}
+		}
+	}
+
+	return book.NewBook{
+		Isbn:            firstToken(r.subfield("a", "020")),
+		Title:           title,
+		Author:          trimPunctuation(r.subfield("a", "100", "110", "700")),
+		PublicationYear: published,
+		Publisher:       trimPunctuation(r.subfield("b", "264", "260")),
+	}
+}
+
+// trimPunctuation removes ISBD punctuation cataloguers put at the end of subfields.
+func trimPunctuation(s string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s), " /:;,=."))
+}
@@ -0,0 +1,163 @@
+package formats
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// ONIX decodes products out of ONIX for Books 3.0 messages using reference tag names.
+type ONIX struct{}
+
+func (ONIX) Name() string { return "onix" }
+
+func (ONIX) Detect(head []byte) bool {
+	return rootElement(head) == "ONIXMessage"
+}
+
+func (ONIX) NewReader(r io.Reader) Reader {
+	return &xmlReader{dec: xml.NewDecoder(r), element: "Product", convert: func(d *xml.Decoder, se xml.StartElement) (book.NewBook, error) {
+		var p onixProduct
+		if err := d.DecodeElement(&p, &se); err != nil {
+			return book.NewBook{}, err
+		}
+		return p.newBook(), nil
+	}}
+}
+
+// ONIX code lists used while mapping products.
+const (
+	onixISBN13       = "15"
+	onixISBN10       = "02"
+	onixGTIN13       = "03"
+	onixDistinctive  = "01"
+	onixAuthor       = "A01"
+	onixPublisher    = "01"
+	onixPublishDate  = "01"
+	onixProductLevel = "01"
+	onixFrontCover   = "01"
+)
+
+type onixProduct struct {
+	Identifiers []struct {
+		Type  string `xml:"ProductIDType"`
+		Value string `xml:"IDValue"`
+	} `xml:"ProductIdentifier"`
+	Descriptive struct {
+		Titles []struct {
+			Type     string `xml:"TitleType"`
+			Elements []struct {
+				Level         string `xml:"TitleElementLevel"`
+				Text          string `xml:"TitleText"`
+				Prefix        string `xml:"TitlePrefix"`
+				WithoutPrefix string `xml:"TitleWithoutPrefix"`
+				Subtitle      string `xml:"Subtitle"`
+			} `xml:"TitleElement"`
+		} `xml:"TitleDetail"`
+		Contributors []struct {
+			Roles          []string `xml:"ContributorRole"`
+			PersonName     string   `xml:"PersonName"`
+			NamesBeforeKey string   `xml:"NamesBeforeKey"`
+			KeyNames       string   `xml:"KeyNames"`
+			CorporateName  string   `xml:"CorporateName"`
+		} `xml:"Contributor"`
+	} `xml:"DescriptiveDetail"`
+	Collateral struct {
+		Resources []struct {
+			ContentType string   `xml:"ResourceContentType"`
+			Links       []string `xml:"ResourceVersion>ResourceLink"`
+		} `xml:"SupportingResource"`
+	} `xml:"CollateralDetail"`
+	Publishing struct {
+		Publishers []struct {
+			Role string `xml:"PublishingRole"`
+			Name string `xml:"PublisherName"`
+		} `xml:"Publisher"`
+		Dates []struct {
+			Role string `xml:"PublishingDateRole"`
+			Date string `xml:"Date"`
+		} `xml:"PublishingDate"`
+	} `xml:"PublishingDetail"`
+}
+
+func (p onixProduct) newBook() book.NewBook {
+	var nb book.NewBook
+
+	// ISBN-13 is preferred over ISBN-10 and GTIN-13.
+	for _, typ := range []string{onixISBN13, onixISBN10, onixGTIN13} {
+		for _, id := range p.Identifiers {
+			if id.Type == typ && nb.Isbn == "" {
+				nb.Isbn = strings.TrimSpace(id.Value)
+			}
+		}
+	}
+
+	for _, t := range p.Descriptive.Titles {
+		if t.Type != onixDistinctive {
+			continue
+		}
+		for _, e := range t.Elements {
+			if e.Level != onixProductLevel && e.Level != "" {
+				continue
+			}
+			title := e.Text
+			if title == "" {
+				title = strings.TrimSpace(e.Prefix + " " + e.WithoutPrefix)
+			}
+			if e.Subtitle != "" {
+				title += ": " + e.Subtitle
+			}
+			nb.Title = strings.TrimSpace(title)
+			break
+		}
+	}
+
+	for _, c := range p.Descriptive.Contributors {
+		if !contains(c.Roles, onixAuthor) {
+			continue
+		}
+		name := c.PersonName
+		if name == "" {
+			name = strings.TrimSpace(c.NamesBeforeKey + " " + c.KeyNames)
+		}
+		if name == "" {
+			name = c.CorporateName
+		}
+		nb.Author = strings.TrimSpace(name)
+		break
+	}
+
+	for _, pub := range p.Publishing.Publishers {
+		if pub.Role == onixPublisher || pub.Role == "" {
+			nb.Publisher = strings.TrimSpace(pub.Name)
+			break
+		}
+	}
+
+	for _, d := range p.Publishing.Dates {
+		if d.Role == onixPublishDate {
+			nb.PublicationYear = year(d.Date)
+			break
+		}
+	}
+
+	for _, r := range p.Collateral.Resources {
+		if r.ContentType == onixFrontCover && len(r.Links) > 0 {
+			nb.CoverURL = strings.TrimSpace(r.Links[0])
+			break
+		}
+	}
+
+	return nb
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// RIS encodes books in the Research Information Systems tagged format.
+type RIS struct{}
+
+func (RIS) Name() string      { return "ris" }
+func (RIS) MediaType() string { return "application/x-research-info-systems" }
+func (RIS) Extension() string { return "ris" }
+
+func (RIS) NewWriter(w io.Writer) Writer {
+	return &risWriter{w: bufio.NewWriter(w)}
+}
+
+type risWriter struct {
+	w *bufio.Writer
+}
+
+func (rw *risWriter) Write(b book.Book) error {
+	tags := [][2]string{
+		{"TY", "BOOK"},
+		{"ID", itoa(b.ID)},
+		{"TI", b.Title},
+		{"AU", str(b.Author)},
+		{"PB", str(b.Publisher)},
+		{"PY", str(b.PublicationYear)},
+		{"SN", b.Isbn},
+	}
+
+	for _, t := range tags {
+		if t[1] == "" {
+			continue
+		}
+		fmt.Fprintf(rw.w, "%s  - %s\r\n", t[0], t[1])
+	}
+	_, err := rw.w.WriteString("ER  - \r\n\r\n")
+
+	return err
+}
+
+func (rw *risWriter) Close() error {
+	return rw.w.Flush()
+}
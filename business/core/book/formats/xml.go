@@ -0,0 +1,59 @@
+package formats
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// xmlWriter streams books as XML elements wrapped with the root element.
+type xmlWriter struct {
+	w       io.Writer
+	enc     *xml.Encoder
+	root    xml.StartElement
+	convert func(book.Book) (any, error)
+	started bool
+}
+
+func newXMLWriter(w io.Writer, root xml.StartElement, convert func(book.Book) (any, error)) *xmlWriter {
+	return &xmlWriter{
+		w:       w,
+		enc:     xml.NewEncoder(w),
+		root:    root,
+		convert: convert,
+	}
+}
+
+func (xw *xmlWriter) Write(b book.Book) error {
+	v, err := xw.convert(b)
+	if err != nil {
+		return err
+	}
+	if err := xw.start(); err != nil {
+		return err
+	}
+	return xw.enc.Encode(v)
+}
+
+func (xw *xmlWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if err := xw.enc.EncodeToken(xw.root.End()); err != nil {
+		return err
+	}
+	return xw.enc.Flush()
+}
+
+func (xw *xmlWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+
+	if _, err := io.WriteString(xw.w, xml.Header); err != nil {
+		return err
+	}
+	return xw.enc.EncodeToken(xw.root)
+}
@@ -0,0 +1,44 @@
+package formats
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+
+	"github.com/tchorzewski1991/bds/business/core/book"
+)
+
+// xmlReader streams books out of XML input, converting every element with the given local name.
+type xmlReader struct {
+	dec     *xml.Decoder
+	element string
+	convert func(d *xml.Decoder, se xml.StartElement) (book.NewBook, error)
+	count   int
+}
+
+func (xr *xmlReader) Read() (book.NewBook, error) {
+	for {
+		tkn, err := xr.dec.Token()
+		if err != nil {
+			return book.NewBook{}, err
+		}
+
+		se, ok := tkn.(xml.StartElement)
+		if !ok || se.Name.Local != xr.element {
+			continue
+		}
+
+		xr.count++
+
+		nb, err := xr.convert(xr.dec, se)
+		if err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) || errors.Is(err, io.EOF) {
+				return book.NewBook{}, err
+			}
+			return book.NewBook{}, &RecordError{Record: xr.count, Err: err}
+		}
+
+		return nb, nil
+	}
+}
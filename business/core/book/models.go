@@ -1,7 +1,5 @@
 package book
 
-import "fmt"
-
 type Book struct {
 	ID              int     `json:"id"`
 	Isbn            string  `json:"isbn"`
@@ -9,21 +7,46 @@ type Book struct {
 	Author          *string `json:"author"`
 	PublicationYear *string `json:"publication_year"`
 	Publisher       *string `json:"publisher"`
+	CoverURL        *string `json:"cover_url"`
 }
 
 type NewBook struct {
-	Isbn            string `json:"isbn"`
-	Title           string `json:"title"`
-	Author          string `json:"author"`
-	PublicationYear string `json:"publication_year"`
-	Publisher       string `json:"publisher"`
+	Isbn            string `json:"isbn" validate:"required,isbn"`
+	Title           string `json:"title" validate:"required,max=500"`
+	Author          string `json:"author" validate:"max=300"`
+	PublicationYear string `json:"publication_year" validate:"regex=^[0-9]{1,4}$"`
+	Publisher       string `json:"publisher" validate:"max=300"`
+	CoverURL        string `json:"cover_url" validate:"max=2048,regex=^https?://"`
+}
+
+// QueryFilter holds optional criteria used while querying books.
+// Title, Author and Publisher are matched case-insensitively by substring.
+// Search matches any of the title, author or isbn. ExactAuthor and ExactPublisher
+// match the whole value, the way facets group books.
+type QueryFilter struct {
+	Isbn           string
+	Title          string
+	Author         string
+	Publisher      string
+	Search         string
+	ExactAuthor    string
+	ExactPublisher string
 }
 
-type FieldError struct {
-	field string
-	err   string
+// Facet represents a distinct value of the book attribute, e.g. author, used while browsing.
+type Facet struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
 }
 
-func (fe FieldError) Error() string {
-	return fmt.Sprintf("%s %s", fe.field, fe.err)
+// Conflict describes an existing book which is likely the same as the new one.
+type Conflict struct {
+	Book   Book   `json:"book"`
+	Reason string `json:"reason"`
 }
+
+// Reasons of conflicts between books.
+const (
+	ConflictIsbn  = "isbn"
+	ConflictTitle = "title"
+)
@@ -0,0 +1,297 @@
+// Package bookfile provides the business API for digital editions attached to books.
+package bookfile
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	uid "github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/base/epub"
+	"github.com/tchorzewski1991/bds/base/isbn"
+	"github.com/tchorzewski1991/bds/business/core/book"
+	"github.com/tchorzewski1991/bds/business/core/bookfile/db"
+	"github.com/tchorzewski1991/bds/business/sys/blob"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotFound    = errors.New("file not found")
+	ErrInvalidEPUB = errors.New("file is not a valid epub")
+)
+
+// Core manages the set of APIs for book files access.
+// Notes:
+// Metadata of files is kept in the database, content goes to the blob store.
+type Core struct {
+	store db.Store
+	blobs blob.Store
+}
+
+// NewCore constructs a Core for book files api access.
+func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger, blobs blob.Store) Core {
+	return Core{store: db.NewStore(sqlDB, logger), blobs: blobs}
+}
+
+// Inspect parses the EPUB and returns its package metadata.
+func Inspect(data []byte) (epub.Metadata, error) {
+	pub, err := epub.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return epub.Metadata{}, fmt.Errorf("%w: %v", ErrInvalidEPUB, err)
+	}
+	return pub.Metadata, nil
+}
+
+// NewBook pre-fills the new book out of the EPUB metadata.
+func NewBook(md epub.Metadata) book.NewBook {
+	nb := book.NewBook{
+		Isbn:            ISBN(md),
+		Title:           md.Title,
+		Author:          strings.Join(md.Creators, ", "),
+		PublicationYear: year(md.Date),
+		Publisher:       md.Publisher,
+	}
+	return nb
+}
+
+// ISBN returns the first valid ISBN found among the identifiers, in ISBN-13 form.
+func ISBN(md epub.Metadata) string {
+	for _, id := range md.Identifiers {
+		value := id.Value
+		if len(value) > 9 && strings.EqualFold(value[:9], "urn:isbn:") {
+			value = value[9:]
+		}
+
+		// Identifiers of other schemes, e.g. urn:uuid, may contain enough digits to look like ISBN.
+		if strings.Trim(value, "0123456789-xX ") != "" {
+			continue
+		}
+
+		if v, err := isbn.To13(value); err == nil {
+			return v
+		}
+	}
+	return ""
+}
+
+// AttachEPUB stores the EPUB file of the book together with its cover image, if present.
+// Either both files are stored or none of them: the cover is read first and the EPUB
+// is removed again when storing the cover fails.
+func (c Core) AttachEPUB(ctx context.Context, bookID int, data []byte) ([]File, error) {
+	pub, err := epub.Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEPUB, err)
+	}
+
+	img, err := readCover(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.save(ctx, bookID, KindEPUB, epub.MediaType, ".epub", data)
+	if err != nil {
+		return nil, err
+	}
+	files := []File{f}
+
+	if img == nil {
+		return files, nil
+	}
+
+	ext := path.Ext(pub.Metadata.Cover.Path)
+	cf, err := c.save(ctx, bookID, KindCover, pub.Metadata.Cover.MediaType, ext, img)
+	if err != nil {
+		if rErr := c.Remove(ctx, files...); rErr != nil {
+			return nil, fmt.Errorf("%w, removing epub failed: %v", err, rErr)
+		}
+		return nil, err
+	}
+
+	return append(files, cf), nil
+}
+
+// Remove deletes both metadata and content of the files. Files already gone are skipped.
+func (c Core) Remove(ctx context.Context, files ...File) error {
+	for _, f := range files {
+		df, err := c.store.QueryByID(ctx, f.BookID, f.ID)
+		if err != nil {
+			if errors.Is(err, database.ErrNotFound) {
+				continue
+			}
+			return fmt.Errorf("query failed: %w", err)
+		}
+
+		err = c.store.Delete(ctx, df.ID)
+		if err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+
+		err = c.blobs.Delete(ctx, df.BlobKey)
+		if err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return fmt.Errorf("deleting blob failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c Core) QueryByBookID(ctx context.Context, bookID int) ([]File, error) {
+	files, err := c.store.QueryByBookID(ctx, bookID)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make([]File, len(files))
+	for i, f := range files {
+		result[i] = convertToFile(f)
+	}
+
+	return result, nil
+}
+
+// QueryByBookIDs returns files of the books keyed by the book ID. Books without files
+// are left out.
+func (c Core) QueryByBookIDs(ctx context.Context, bookIDs []int) (map[int][]File, error) {
+	files, err := c.store.QueryByBookIDs(ctx, bookIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make(map[int][]File)
+	for _, f := range files {
+		result[f.BookID] = append(result[f.BookID], convertToFile(f))
+	}
+
+	return result, nil
+}
+
+func (c Core) QueryByID(ctx context.Context, bookID int, id string) (File, error) {
+	if _, err := uid.Parse(id); err != nil {
+		return File{}, ErrNotFound
+	}
+
+	f, err := c.store.QueryByID(ctx, bookID, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return File{}, ErrNotFound
+		}
+		return File{}, fmt.Errorf("query failed: %w", err)
+	}
+
+	return convertToFile(f), nil
+}
+
+// Open returns the content of the file. The caller is responsible for closing it.
+func (c Core) Open(ctx context.Context, bookID int, id string) (File, io.ReadCloser, error) {
+	if _, err := uid.Parse(id); err != nil {
+		return File{}, nil, ErrNotFound
+	}
+
+	f, err := c.store.QueryByID(ctx, bookID, id)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return File{}, nil, ErrNotFound
+		}
+		return File{}, nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	rc, err := c.blobs.Get(ctx, f.BlobKey)
+	if err != nil {
+		if errors.Is(err, blob.ErrNotFound) {
+			return File{}, nil, ErrNotFound
+		}
+		return File{}, nil, fmt.Errorf("opening blob failed: %w", err)
+	}
+
+	return convertToFile(f), rc, nil
+}
+
+// private
+
+func (c Core) save(ctx context.Context, bookID int, kind, mediaType, ext string, data []byte) (File, error) {
+	sum := sha256.Sum256(data)
+
+	f := db.File{
+		ID:        uid.NewString(),
+		BookID:    bookID,
+		Kind:      kind,
+		MediaType: mediaType,
+		Size:      int64(len(data)),
+		SHA256:    hex.EncodeToString(sum[:]),
+		CreatedAt: time.Now().UTC(),
+	}
+	f.BlobKey = fmt.Sprintf("books/%d/%s%s", bookID, f.ID, ext)
+
+	err := c.blobs.Put(ctx, f.BlobKey, bytes.NewReader(data))
+	if err != nil {
+		return File{}, fmt.Errorf("storing blob failed: %w", err)
+	}
+
+	err = c.store.Create(ctx, f)
+	if err != nil {
+		_ = c.blobs.Delete(ctx, f.BlobKey)
+		return File{}, fmt.Errorf("create failed: %w", err)
+	}
+
+	return convertToFile(f), nil
+}
+
+func convertToFile(f db.File) File {
+	return File{
+		ID:        f.ID,
+		BookID:    f.BookID,
+		Kind:      f.Kind,
+		MediaType: f.MediaType,
+		Size:      f.Size,
+		SHA256:    f.SHA256,
+		CreatedAt: f.CreatedAt,
+	}
+}
+
+// readCover returns the cover image, or nil when the publication has none.
+func readCover(pub *epub.Book) ([]byte, error) {
+	cover, err := pub.OpenCover()
+	if err != nil {
+		switch {
+		case errors.Is(err, epub.ErrNoCover):
+			return nil, nil
+		case errors.Is(err, epub.ErrEntryTooLarge):
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEPUB, err)
+		}
+		return nil, fmt.Errorf("reading cover failed: %w", err)
+	}
+	defer cover.Close()
+
+	img, err := io.ReadAll(cover)
+	if err != nil {
+		if errors.Is(err, epub.ErrEntryTooLarge) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidEPUB, err)
+		}
+		return nil, fmt.Errorf("reading cover failed: %w", err)
+	}
+
+	return img, nil
+}
+
+// year extracts the year out of W3CDTF dates used by EPUB, e.g. 2002-01-15.
+func year(date string) string {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return ""
+	}
+	for _, c := range date[:4] {
+		if c < '0' || c > '9' {
+			return ""
+		}
+	}
+	return date[:4]
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *database.ExtContext
+}
+
+func NewStore(db *sqlx.DB, logger *zap.SugaredLogger) Store {
+	return Store{db: database.NewExtContext(db).WithLogger(logger)}
+}
+
+func (s Store) Create(ctx context.Context, file File) error {
+	const q = `
+		insert into book_files
+			(id, book_id, kind, media_type, size, sha256, blob_key, created_at)
+		values
+			(:id, :book_id, :kind, :media_type, :size, :sha256, :blob_key, :created_at)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("book_files", "Create"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, file)
+	return err
+}
+
+func (s Store) Delete(ctx context.Context, id string) error {
+	const q = `delete from book_files where id = :id`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("book_files", "Delete"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"id": id,
+	})
+	return err
+}
+
+func (s Store) QueryByID(ctx context.Context, bookID int, id string) (File, error) {
+	const q = `select * from book_files where id = :id and book_id = :book_id`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("book_files", "QueryByID"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"id":      id,
+		"book_id": bookID,
+	})
+	if err != nil {
+		return File{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return File{}, database.ErrNotFound
+	}
+
+	var file File
+	err = rows.StructScan(&file)
+	if err != nil {
+		return File{}, err
+	}
+
+	return file, nil
+}
+
+// QueryByBookIDs returns files of all the books at once, ordered like QueryByBookID.
+func (s Store) QueryByBookIDs(ctx context.Context, bookIDs []int) ([]File, error) {
+	const q = `select * from book_files where book_id = any(:book_ids) order by book_id, created_at, kind`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("book_files", "QueryByBookIDs"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"book_ids": pq.Array(bookIDs),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+
+	for rows.Next() {
+		var file File
+		err = rows.StructScan(&file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func (s Store) QueryByBookID(ctx context.Context, bookID int) ([]File, error) {
+	const q = `select * from book_files where book_id = :book_id order by created_at, kind`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("book_files", "QueryByBookID"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"book_id": bookID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+
+	for rows.Next() {
+		var file File
+		err = rows.StructScan(&file)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
@@ -0,0 +1,14 @@
+package db
+
+import "time"
+
+type File struct {
+	ID        string    `db:"id"`
+	BookID    int       `db:"book_id"`
+	Kind      string    `db:"kind"`
+	MediaType string    `db:"media_type"`
+	Size      int64     `db:"size"`
+	SHA256    string    `db:"sha256"`
+	BlobKey   string    `db:"blob_key"`
+	CreatedAt time.Time `db:"created_at"`
+}
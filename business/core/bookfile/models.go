@@ -0,0 +1,20 @@
+package bookfile
+
+import "time"
+
+// Kinds of files attached to books.
+const (
+	KindEPUB  = "epub"
+	KindCover = "cover"
+)
+
+// File is a business representation of the file attached to a book.
+type File struct {
+	ID        string    `json:"id"`
+	BookID    int       `json:"book_id"`
+	Kind      string    `json:"kind"`
+	MediaType string    `json:"media_type"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
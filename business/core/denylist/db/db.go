@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *database.ExtContext
+}
+
+func NewStore(db *sqlx.DB, logger *zap.SugaredLogger) Store {
+	return Store{db: database.NewExtContext(db).WithLogger(logger)}
+}
+
+func (s Store) Create(ctx context.Context, token RevokedToken) error {
+	const q = `
+		insert into revoked_tokens
+			(jti, expires_at, created_at)
+		values
+			(:jti, :expires_at, :created_at)
+		on conflict do nothing
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("revoked_tokens", "Create"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, token)
+	return err
+}
+
+// QueryActive returns revoked tokens which haven't expired yet.
+// Expired tokens are refused anyway, so there is no need to remember them.
+func (s Store) QueryActive(ctx context.Context) ([]RevokedToken, error) {
+	const q = `select * from revoked_tokens where expires_at > now()`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("revoked_tokens", "QueryActive"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []RevokedToken
+
+	for rows.Next() {
+		var token RevokedToken
+		err = rows.StructScan(&token)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// DeleteExpired removes tokens which have already expired.
+func (s Store) DeleteExpired(ctx context.Context) error {
+	const q = `delete from revoked_tokens where expires_at <= now()`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("revoked_tokens", "DeleteExpired"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{})
+	return err
+}
@@ -0,0 +1,9 @@
+package db
+
+import "time"
+
+type RevokedToken struct {
+	JTI       string    `db:"jti"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
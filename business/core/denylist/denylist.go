@@ -0,0 +1,131 @@
+// Package denylist keeps IDs of access tokens revoked before they expired.
+package denylist
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/core/denylist/db"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"go.uber.org/zap"
+)
+
+// DefaultSyncInterval is how often the cache is reloaded from the DB. Tokens revoked
+// by other instances of the service are refused after at most this long.
+const DefaultSyncInterval = 10 * time.Second
+
+// Core manages the set of APIs for revoking tokens. It implements auth.Verifier.
+// Notes:
+// Revoked tokens are cached in-process and the cache is shared between copies of Core,
+// so checking the token doesn't hit the DB on every request.
+type Core struct {
+	store db.Store
+	cache *cache
+}
+
+// NewCore constructs a Core for revoking tokens.
+func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger, syncInterval time.Duration) Core {
+	return Core{
+		store: db.NewStore(sqlDB, logger),
+		cache: &cache{
+			interval: syncInterval,
+			entries:  make(map[string]time.Time),
+		},
+	}
+}
+
+// Revoke denies the token until it expires. Tokens which already expired are
+// removed from the denylist at the same time.
+func (c Core) Revoke(ctx context.Context, claims auth.Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return fmt.Errorf("token can't be revoked without jti and exp claims")
+	}
+
+	err := c.store.DeleteExpired(ctx)
+	if err != nil {
+		return fmt.Errorf("purging expired tokens: %w", err)
+	}
+
+	err = c.store.Create(ctx, db.RevokedToken{
+		JTI:       claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("revoke failed: %w", err)
+	}
+
+	c.cache.add(claims.ID, claims.ExpiresAt.Time)
+
+	return nil
+}
+
+// VerifyClaims refuses tokens which have been revoked.
+func (c Core) VerifyClaims(ctx context.Context, claims auth.Claims) (auth.Claims, error) {
+	err := c.sync(ctx)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	if c.cache.contains(claims.ID) {
+		return auth.Claims{}, auth.ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// private
+
+// sync reloads the cache when it's older than the sync interval.
+func (c Core) sync(ctx context.Context) error {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.cache.synced) < c.cache.interval {
+		return nil
+	}
+
+	tokens, err := c.store.QueryActive(ctx)
+	if err != nil {
+		return fmt.Errorf("loading revoked tokens: %w", err)
+	}
+
+	entries := make(map[string]time.Time, len(tokens))
+	for _, t := range tokens {
+		entries[t.JTI] = t.ExpiresAt
+	}
+
+	c.cache.entries = entries
+	c.cache.synced = now
+
+	return nil
+}
+
+type cache struct {
+	mu       sync.Mutex
+	interval time.Duration
+	synced   time.Time
+	entries  map[string]time.Time
+}
+
+func (c *cache) add(jti string, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[jti] = expiresAt
+}
+
+func (c *cache) contains(jti string) bool {
+	if jti == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt, ok := c.entries[jti]
+	return ok && time.Now().Before(expiresAt)
+}
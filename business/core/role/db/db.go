@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *database.ExtContext
+}
+
+func NewStore(db *sqlx.DB, logger *zap.SugaredLogger) Store {
+	return Store{db: database.NewExtContext(db).WithLogger(logger)}
+}
+
+func (s Store) Query(ctx context.Context) ([]Role, error) {
+	const q = `select * from roles order by name`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("roles", "Query"))
+
+	return s.query(ctx, ext, q, map[string]any{})
+}
+
+func (s Store) QueryByName(ctx context.Context, name string) (Role, error) {
+	const q = `select * from roles where name = :name`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("roles", "QueryByName"))
+
+	roles, err := s.query(ctx, ext, q, map[string]any{
+		"name": name,
+	})
+	if err != nil {
+		return Role{}, err
+	}
+	if len(roles) == 0 {
+		return Role{}, database.ErrNotFound
+	}
+
+	return roles[0], nil
+}
+
+// Save creates the role or replaces description and permissions of the existing one.
+func (s Store) Save(ctx context.Context, role Role) (Role, error) {
+	const q = `
+		insert into roles
+			(name, description, permissions, date_created, date_updated)
+		values
+			(:name, :description, :permissions, :date_created, :date_updated)
+		on conflict (name) do update set
+			description = excluded.description,
+			permissions = excluded.permissions,
+			date_updated = excluded.date_updated
+		returning *
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("roles", "Save"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, role)
+	if err != nil {
+		return Role{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Role{}, database.ErrNotFound
+	}
+
+	var saved Role
+	err = rows.StructScan(&saved)
+	if err != nil {
+		return Role{}, err
+	}
+
+	return saved, nil
+}
+
+func (s Store) Delete(ctx context.Context, name string) error {
+	const q = `delete from roles where name = :name`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("roles", "Delete"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"name": name,
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+
+	return nil
+}
+
+// private
+
+func (s Store) query(ctx context.Context, ext *database.ExtContext, q string, data map[string]any) ([]Role, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+
+	for rows.Next() {
+		var role Role
+		err = rows.StructScan(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
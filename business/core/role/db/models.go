@@ -0,0 +1,15 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type Role struct {
+	Name        string         `db:"name"`
+	Description string         `db:"description"`
+	Permissions pq.StringArray `db:"permissions"`
+	CreatedAt   time.Time      `db:"date_created"`
+	UpdatedAt   time.Time      `db:"date_updated"`
+}
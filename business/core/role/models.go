@@ -0,0 +1,35 @@
+package role
+
+import (
+	"fmt"
+	"time"
+)
+
+// Role bundles permissions under a name, e.g. librarian, which can be assigned to users.
+type Role struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewRole contains information needed to create or replace a role.
+type NewRole struct {
+	Description string   `json:"description" validate:"max=500"`
+	Permissions []string `json:"permissions"`
+}
+
+type FieldError struct {
+	field string
+	err   string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s %s", fe.field, fe.err)
+}
+
+// Details returns the problem keyed by the JSON name of the field.
+func (fe FieldError) Details() map[string]string {
+	return map[string]string{fe.field: fe.err}
+}
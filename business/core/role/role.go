@@ -0,0 +1,121 @@
+// Package role manages named sets of permissions assigned to users.
+package role
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/core/role/db"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotFound = errors.New("role not found")
+)
+
+var nameRe = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// Core manages the set of APIs for role access.
+type Core struct {
+	store db.Store
+}
+
+// NewCore constructs a Core for role api access.
+func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger) Core {
+	return Core{store: db.NewStore(sqlDB, logger)}
+}
+
+func (c Core) Query(ctx context.Context) ([]Role, error) {
+	roles, err := c.store.Query(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make([]Role, len(roles))
+	for i, r := range roles {
+		result[i] = convertToRole(r)
+	}
+
+	return result, nil
+}
+
+func (c Core) QueryByName(ctx context.Context, name string) (Role, error) {
+	role, err := c.store.QueryByName(ctx, name)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return Role{}, ErrNotFound
+		}
+		return Role{}, fmt.Errorf("query failed: %w", err)
+	}
+
+	return convertToRole(role), nil
+}
+
+// Save creates the role or replaces the existing one with the same name.
+func (c Core) Save(ctx context.Context, name string, nr NewRole) (Role, error) {
+	if !nameRe.MatchString(name) {
+		return Role{}, FieldError{field: "name", err: "must be lowercase alphanumeric up to 64 characters"}
+	}
+
+	permissions := nr.Permissions
+	if permissions == nil {
+		permissions = []string{}
+	}
+	for _, p := range permissions {
+		if !auth.ValidPermission(p) {
+			return Role{}, FieldError{field: "permissions", err: fmt.Sprintf("%q is not a valid permission", p)}
+		}
+	}
+
+	now := time.Now().UTC()
+
+	role, err := c.store.Save(ctx, db.Role{
+		Name:        name,
+		Description: nr.Description,
+		Permissions: permissions,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	if err != nil {
+		return Role{}, fmt.Errorf("save failed: %w", err)
+	}
+
+	return convertToRole(role), nil
+}
+
+// Delete removes the role. Users holding it lose its permissions once their cached
+// state is reloaded, see user.NewCore.
+func (c Core) Delete(ctx context.Context, name string) error {
+	err := c.store.Delete(ctx, name)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("delete failed: %w", err)
+	}
+
+	return nil
+}
+
+// private
+
+func convertToRole(role db.Role) Role {
+	permissions := []string(role.Permissions)
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	return Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: permissions,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+type Store struct {
+	db *database.ExtContext
+}
+
+func NewStore(db *sqlx.DB, logger *zap.SugaredLogger) Store {
+	return Store{db: database.NewExtContext(db).WithLogger(logger)}
+}
+
+func (s Store) Create(ctx context.Context, event Event) error {
+	const q = `
+		insert into security_events
+			(id, type, outcome, user_uuid, actor, ip, user_agent, trace_id, details, created_at)
+		values
+			(:id, :type, :outcome, :user_uuid, :actor, :ip, :user_agent, :trace_id, :details, :created_at)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("security_events", "Create"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, event)
+	return err
+}
+
+// Query returns the most recent events first.
+func (s Store) Query(ctx context.Context, filter Filter, page int, rowsPerPage int) ([]Event, error) {
+	page, rowsPerPage = paging(page, rowsPerPage)
+
+	data := map[string]any{
+		"offset":        (page - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	q := `select * from security_events` + where(filter, data) +
+		` order by created_at desc, id offset :offset rows fetch next :rows_per_page rows only`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("security_events", "Query"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []Event
+
+	for rows.Next() {
+		var event Event
+		err = rows.StructScan(&event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// DeleteBefore removes events recorded before the given time and returns their number.
+func (s Store) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	const q = `delete from security_events where created_at < :before`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("security_events", "DeleteBefore"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{"before": before})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// private
+
+func where(filter Filter, data map[string]any) string {
+	var conditions []string
+
+	if filter.Type != "" {
+		data["type"] = filter.Type
+		conditions = append(conditions, "type = :type")
+	}
+	if filter.Outcome != "" {
+		data["outcome"] = filter.Outcome
+		conditions = append(conditions, "outcome = :outcome")
+	}
+	if filter.UserUUID != "" {
+		data["user_uuid"] = filter.UserUUID
+		conditions = append(conditions, "user_uuid = cast(:user_uuid as uuid)")
+	}
+	if filter.IP != "" {
+		data["ip"] = filter.IP
+		conditions = append(conditions, "ip = :ip")
+	}
+	if !filter.From.IsZero() {
+		data["from"] = filter.From
+		conditions = append(conditions, "created_at >= :from")
+	}
+	if !filter.To.IsZero() {
+		data["to"] = filter.To
+		conditions = append(conditions, "created_at < :to")
+	}
+
+	if len(conditions) == 0 {
+		return ""
+	}
+
+	return " where " + strings.Join(conditions, " and ")
+}
+
+func paging(page int, rowsPerPage int) (int, int) {
+	// Ensure page is set correctly
+	if page < 1 {
+		page = 1
+	}
+
+	// Ensure rowsPerPage is set correctly
+	if rowsPerPage < 1 || rowsPerPage > 20 {
+		rowsPerPage = 20
+	}
+
+	return page, rowsPerPage
+}
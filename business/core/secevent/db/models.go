@@ -0,0 +1,30 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+type Event struct {
+	ID        string         `db:"id"`
+	Type      string         `db:"type"`
+	Outcome   string         `db:"outcome"`
+	UserUUID  sql.NullString `db:"user_uuid"`
+	Actor     sql.NullString `db:"actor"`
+	IP        string         `db:"ip"`
+	UserAgent string         `db:"user_agent"`
+	TraceID   string         `db:"trace_id"`
+	Details   string         `db:"details"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// Filter holds optional criteria used while querying events.
+// Empty fields are not taken into account.
+type Filter struct {
+	Type     string
+	Outcome  string
+	UserUUID string
+	IP       string
+	From     time.Time
+	To       time.Time
+}
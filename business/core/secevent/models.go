@@ -0,0 +1,47 @@
+package secevent
+
+import (
+	"fmt"
+	"time"
+)
+
+// Event is a business representation of the recorded security event.
+// Actor is the subject of the credentials used for the action, e.g. the admin who
+// granted the permission. It's nil when the action was taken without credentials.
+type Event struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Outcome   string    `json:"outcome"`
+	UserUUID  *string   `json:"user_uuid"`
+	Actor     *string   `json:"actor"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	TraceID   string    `json:"trace_id"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QueryFilter holds optional criteria used while querying events.
+// From is inclusive, To is exclusive.
+type QueryFilter struct {
+	Type     string
+	Outcome  string
+	UserUUID string
+	IP       string
+	From     time.Time
+	To       time.Time
+}
+
+type FieldError struct {
+	field string
+	err   string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s %s", fe.field, fe.err)
+}
+
+// Details returns the problem keyed by the JSON name of the field.
+func (fe FieldError) Details() map[string]string {
+	return map[string]string{fe.field: fe.err}
+}
@@ -0,0 +1,151 @@
+// Package secevent keeps the append-only record of security events, like logins,
+// token issues and permission changes.
+package secevent
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	uid "github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/secevent/db"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"go.uber.org/zap"
+)
+
+// recordTimeout bounds recording of a single event.
+const recordTimeout = 5 * time.Second
+
+// Core manages the set of APIs for security events. It implements auth.EventRecorder.
+// Notes:
+// Events are never changed once recorded, they are only purged when they fall out
+// of the retention period.
+type Core struct {
+	store  db.Store
+	logger *zap.SugaredLogger
+}
+
+// NewCore constructs a Core for security events.
+func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger) Core {
+	return Core{store: db.NewStore(sqlDB, logger), logger: logger}
+}
+
+// RecordEvent stores the event together with the client IP, the user agent and
+// the trace ID of the request. The subject of claims stored in the context, if any,
+// is recorded as the actor. Anonymous events keep the trace ID only.
+// Failures are only logged, so they don't affect the action.
+func (c Core) RecordEvent(ctx context.Context, e auth.Event) {
+	event := db.Event{
+		ID:        uid.NewString(),
+		Type:      e.Type,
+		Outcome:   e.Outcome,
+		TraceID:   web.GetTraceID(ctx),
+		Details:   e.Details,
+		CreatedAt: time.Now().UTC(),
+	}
+	if _, err := uid.Parse(e.UserUUID); err == nil {
+		event.UserUUID = sql.NullString{String: e.UserUUID, Valid: true}
+	}
+	if !e.Anonymous {
+		event.IP = web.GetClientIP(ctx)
+		event.UserAgent = web.GetUserAgent(ctx)
+		if claims, err := auth.GetClaims(ctx); err == nil && claims.Subject != "" {
+			event.Actor = sql.NullString{String: claims.Subject, Valid: true}
+		}
+	}
+
+	// The event is recorded even when the client has gone away in the meantime.
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout) // nolint:contextcheck
+	defer cancel()
+
+	err := c.store.Create(ctx, event)
+	if err != nil {
+		c.logger.Errorw("recording security event", "trace_id", event.TraceID, "type", event.Type, "error", err)
+	}
+}
+
+// Query returns the most recent events matching the filter first.
+func (c Core) Query(ctx context.Context, filter QueryFilter, page int, rowsPerPage int) ([]Event, error) {
+	if filter.UserUUID != "" {
+		if _, err := uid.Parse(filter.UserUUID); err != nil {
+			return nil, FieldError{field: "user_uuid", err: "is not a valid UUID"}
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, FieldError{field: "from", err: "must be before to"}
+	}
+
+	events, err := c.store.Query(ctx, db.Filter(filter), page, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make([]Event, len(events))
+	for i, e := range events {
+		result[i] = convertToEvent(e)
+	}
+
+	return result, nil
+}
+
+// Purge removes events recorded before the given time. It returns the number of
+// removed events.
+func (c Core) Purge(ctx context.Context, before time.Time) (int64, error) {
+	n, err := c.store.DeleteBefore(ctx, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("purge failed: %w", err)
+	}
+	return n, nil
+}
+
+// Retain purges events older than retention every interval, until the context is
+// canceled. Zero retention or interval disables purging, so events are kept forever.
+func (c Core) Retain(ctx context.Context, retention time.Duration, interval time.Duration) {
+	if retention <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := c.Purge(ctx, time.Now().Add(-retention))
+		switch {
+		case err != nil:
+			c.logger.Errorw("purging security events", "error", err)
+		case n > 0:
+			c.logger.Infow("purged security events", "count", n, "retention", retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// private
+
+func convertToEvent(event db.Event) Event {
+	e := Event{
+		ID:        event.ID,
+		Type:      event.Type,
+		Outcome:   event.Outcome,
+		IP:        event.IP,
+		UserAgent: event.UserAgent,
+		TraceID:   event.TraceID,
+		Details:   event.Details,
+		CreatedAt: event.CreatedAt,
+	}
+	if event.UserUUID.Valid {
+		e.UserUUID = &event.UserUUID.String
+	}
+	if event.Actor.Valid {
+		e.Actor = &event.Actor.String
+	}
+	return e
+}
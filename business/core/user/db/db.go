@@ -2,12 +2,31 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/tchorzewski1991/bds/business/sys/database"
 	"go.uber.org/zap"
 )
 
+// ErrReused is returned when already used or revoked refresh token is presented again.
+var ErrReused = errors.New("refresh token reused")
+
+// selectUsers loads users together with their roles and permissions granted by these roles.
+const selectUsers = `
+	select
+		u.*,
+		array(select ur.role_name from user_roles ur where ur.user_uuid = u.uuid order by 1) as roles,
+		array(
+			select distinct unnest(r.permissions) from user_roles ur
+			join roles r on r.name = ur.role_name
+			where ur.user_uuid = u.uuid
+		) as role_permissions
+	from users u`
+
 type Store struct {
 	db *database.ExtContext
 }
@@ -17,7 +36,7 @@ func NewStore(db *sqlx.DB, logger *zap.SugaredLogger) Store {
 }
 
 func (s Store) QueryByUUID(ctx context.Context, uuid string) (User, error) {
-	const q = `select * from users where uuid = :uuid`
+	q := selectUsers + ` where uuid = :uuid`
 
 	data := struct {
 		Uuid string `db:"uuid"`
@@ -46,8 +65,9 @@ func (s Store) QueryByUUID(ctx context.Context, uuid string) (User, error) {
 	return user, err
 }
 
+// QueryByEmail finds the user by email, ignoring case.
 func (s Store) QueryByEmail(ctx context.Context, email string) (User, error) {
-	const q = `select * from users where email = :email`
+	q := selectUsers + ` where lower(email) = lower(:email)`
 
 	data := struct {
 		Email string `db:"email"`
@@ -75,3 +95,788 @@ func (s Store) QueryByEmail(ctx context.Context, email string) (User, error) {
 
 	return user, err
 }
+
+func (s Store) Create(ctx context.Context, user User) error {
+	const q = `
+		insert into users
+			(uuid, email, permissions, password_hash, verified_at, date_created, date_updated)
+		values
+			(:uuid, :email, :permissions, :password_hash, :verified_at, :date_created, :date_updated)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "Create"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, user)
+	if err != nil {
+		// Checks if the error is of code 23505 (unique_violation).
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == database.UniqueViolation {
+			return database.ErrNotUnique
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Query returns users ordered by email. Search matches the email partially.
+func (s Store) Query(ctx context.Context, search string, page int, rowsPerPage int) ([]User, error) {
+	page, rowsPerPage = paging(page, rowsPerPage)
+
+	data := map[string]any{
+		"offset":        (page - 1) * rowsPerPage,
+		"rows_per_page": rowsPerPage,
+	}
+
+	q := selectUsers
+	if search != "" {
+		data["search"] = "%" + search + "%"
+		q += ` where email ilike :search`
+	}
+	q += ` order by email offset :offset rows fetch next :rows_per_page rows only`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "Query"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, data)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+
+	for rows.Next() {
+		var user User
+		err = rows.StructScan(&user)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// Update saves the editable fields of the user.
+func (s Store) Update(ctx context.Context, user User) error {
+	const q = `
+		update users set
+			email = :email,
+			permissions = :permissions,
+			branch = :branch,
+			disabled_at = :disabled_at,
+			date_updated = :date_updated
+		where uuid = :uuid
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "Update"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, user)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == database.UniqueViolation {
+			return database.ErrNotUnique
+		}
+		return err
+	}
+
+	return affected(res)
+}
+
+// GrantPermission adds the permission to the user unless the user already holds it.
+// Permissions are modified in place, so concurrent changes don't overwrite each other.
+func (s Store) GrantPermission(ctx context.Context, uuid string, permission string) error {
+	const q = `
+		update users set
+			permissions = array(select distinct unnest(array_append(permissions, cast(:permission as text))) order by 1),
+			date_updated = now()
+		where uuid = :uuid
+	`
+	return s.updatePermissions(ctx, "GrantPermission", q, uuid, permission)
+}
+
+// RevokePermission removes the permission from the user.
+func (s Store) RevokePermission(ctx context.Context, uuid string, permission string) error {
+	const q = `
+		update users set
+			permissions = array_remove(permissions, cast(:permission as text)),
+			date_updated = now()
+		where uuid = :uuid
+	`
+	return s.updatePermissions(ctx, "RevokePermission", q, uuid, permission)
+}
+
+func (s Store) AssignRole(ctx context.Context, uuid string, role string) error {
+	const q = `
+		insert into user_roles (user_uuid, role_name) values (:uuid, :role)
+		on conflict do nothing
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_roles", "AssignRole"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid": uuid,
+		"role": role,
+	})
+	if err != nil {
+		// Checks if the error is of code 23503 (foreign_key_violation).
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return database.ErrNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s Store) UnassignRole(ctx context.Context, uuid string, role string) error {
+	const q = `delete from user_roles where user_uuid = :uuid and role_name = :role`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_roles", "UnassignRole"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid": uuid,
+		"role": role,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+func (s Store) MarkVerified(ctx context.Context, uuid string) error {
+	const q = `update users set verified_at = now(), date_updated = now() where uuid = :uuid and verified_at is null`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "MarkVerified"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid": uuid,
+	})
+	return err
+}
+
+// UpdatePassword sets the new password hash and marks all outstanding tokens of the
+// user, refresh tokens included, as used. Both happen in a single statement, so no token survives the change.
+// Password change proves the ownership of the email, so user is verified as well.
+func (s Store) UpdatePassword(ctx context.Context, uuid string, hash []byte, changedAt time.Time) error {
+	const q = `
+		with revoked as (
+			update user_tokens set used_at = :changed_at
+			where user_uuid = :uuid and used_at is null
+		), revoked_refresh as (
+			update refresh_tokens set revoked_at = :changed_at
+			where user_uuid = :uuid and revoked_at is null
+		)
+		update users set
+			password_hash = :password_hash,
+			password_changed_at = :changed_at,
+			verified_at = coalesce(verified_at, :changed_at),
+			date_updated = :changed_at
+		where uuid = :uuid
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "UpdatePassword"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":          uuid,
+		"password_hash": hash,
+		"changed_at":    changedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+func (s Store) CreateToken(ctx context.Context, token Token) error {
+	const q = `
+		insert into user_tokens
+			(token_hash, user_uuid, purpose, expires_at, created_at)
+		values
+			(:token_hash, :user_uuid, :purpose, :expires_at, :created_at)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_tokens", "CreateToken"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, token)
+	return err
+}
+
+// QueryToken returns the valid token without using it.
+func (s Store) QueryToken(ctx context.Context, tokenHash string, purpose string) (Token, error) {
+	const q = `
+		select * from user_tokens
+		where token_hash = :token_hash and purpose = :purpose and used_at is null and expires_at > now()
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_tokens", "QueryToken"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Token{}, database.ErrNotFound
+	}
+
+	var token Token
+	err = rows.StructScan(&token)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return token, nil
+}
+
+// UseToken marks the valid token as used and returns it. The update is conditional,
+// so a token can't be used twice even by concurrent requests.
+func (s Store) UseToken(ctx context.Context, tokenHash string, purpose string) (Token, error) {
+	const q = `
+		update user_tokens set used_at = now()
+		where token_hash = :token_hash and purpose = :purpose and used_at is null and expires_at > now()
+		returning *
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_tokens", "UseToken"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Token{}, database.ErrNotFound
+	}
+
+	var token Token
+	err = rows.StructScan(&token)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return token, nil
+}
+
+func (s Store) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	const q = `
+		insert into refresh_tokens
+			(token_hash, family_id, user_uuid, expires_at, created_at)
+		values
+			(:token_hash, :family_id, :user_uuid, :expires_at, :created_at)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("refresh_tokens", "CreateRefreshToken"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, token)
+	return err
+}
+
+// UseRefreshToken marks the valid refresh token as used and returns it. When the token
+// exists but can't be used anymore it returns the token together with ErrReused,
+// as it means that the token has been presented more than once.
+func (s Store) UseRefreshToken(ctx context.Context, tokenHash string) (RefreshToken, error) {
+	const q = `
+		update refresh_tokens set used_at = now()
+		where token_hash = :token_hash and used_at is null and revoked_at is null and expires_at > now()
+		returning *
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("refresh_tokens", "UseRefreshToken"))
+
+	data := map[string]any{
+		"token_hash": tokenHash,
+	}
+
+	token, err := s.queryRefreshToken(ctx, ext, q, data)
+	if !errors.Is(err, database.ErrNotFound) {
+		return token, err
+	}
+
+	const reused = `select * from refresh_tokens where token_hash = :token_hash and (used_at is not null or revoked_at is not null)`
+
+	token, err = s.queryRefreshToken(ctx, ext, reused, data)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	return token, ErrReused
+}
+
+// RevokeRefreshFamily revokes all refresh tokens descending from the same login.
+func (s Store) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	const q = `update refresh_tokens set revoked_at = now() where family_id = :family_id and revoked_at is null`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("refresh_tokens", "RevokeRefreshFamily"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"family_id": familyID,
+	})
+	return err
+}
+
+// SetTOTPSecret starts the TOTP enrolment of the user. It fails with database.ErrNotFound
+// when two-factor authentication is already enabled.
+func (s Store) SetTOTPSecret(ctx context.Context, uuid string, secret string) error {
+	const q = `
+		update users set totp_secret = :totp_secret, totp_last_counter = null, date_updated = now()
+		where uuid = :uuid and totp_enabled_at is null
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "SetTOTPSecret"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":        uuid,
+		"totp_secret": secret,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+// EnableTOTP confirms the pending TOTP enrolment and replaces recovery codes of the user.
+// Codes are only stored when the enrolment is confirmed by the same statement.
+func (s Store) EnableTOTP(ctx context.Context, uuid string, counter int64, codeHashes []string, enabledAt time.Time) error {
+	const q = `
+		with enabled as (
+			update users set totp_enabled_at = :enabled_at, totp_last_counter = :counter, date_updated = :enabled_at
+			where uuid = :uuid and totp_secret is not null and totp_enabled_at is null
+			returning uuid
+		), deleted as (
+			delete from recovery_codes where user_uuid in (select uuid from enabled)
+		)
+		insert into recovery_codes (code_hash, user_uuid, created_at)
+		select unnest(cast(:code_hashes as text[])), uuid, cast(:enabled_at as timestamp) from enabled
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "EnableTOTP"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":        uuid,
+		"counter":     counter,
+		"code_hashes": pq.Array(codeHashes),
+		"enabled_at":  enabledAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+// UseTOTPCounter records the time step of the accepted code. It fails with database.ErrNotFound
+// when the same or a later step has already been used.
+func (s Store) UseTOTPCounter(ctx context.Context, uuid string, counter int64) error {
+	const q = `
+		update users set totp_last_counter = :counter
+		where uuid = :uuid and totp_enabled_at is not null and (totp_last_counter is null or totp_last_counter < :counter)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "UseTOTPCounter"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":    uuid,
+		"counter": counter,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+// UseRecoveryCode marks the unused recovery code of the user as used.
+func (s Store) UseRecoveryCode(ctx context.Context, uuid string, codeHash string) error {
+	const q = `update recovery_codes set used_at = now() where code_hash = :code_hash and user_uuid = :uuid and used_at is null`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("recovery_codes", "UseRecoveryCode"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":      uuid,
+		"code_hash": codeHash,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+// QueryByIdentity returns the user linked to the account at external identity provider.
+func (s Store) QueryByIdentity(ctx context.Context, issuer string, subject string) (User, error) {
+	q := selectUsers + ` where uuid = (select user_uuid from user_identities where issuer = :issuer and subject = :subject)`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "QueryByIdentity"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"issuer":  issuer,
+		"subject": subject,
+	})
+	if err != nil {
+		return User{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return User{}, database.ErrNotFound
+	}
+
+	var user User
+	err = rows.StructScan(&user)
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
+
+func (s Store) CreateIdentity(ctx context.Context, identity Identity) error {
+	const q = `
+		insert into user_identities
+			(issuer, subject, user_uuid, email, created_at)
+		values
+			(:issuer, :subject, :user_uuid, :email, :created_at)
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_identities", "CreateIdentity"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, identity)
+	if err != nil {
+		// Checks if the error is of code 23505 (unique_violation).
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == database.UniqueViolation {
+			return database.ErrNotUnique
+		}
+		return err
+	}
+
+	return nil
+}
+
+// QueryIdentity returns the account at external identity provider.
+func (s Store) QueryIdentity(ctx context.Context, issuer string, subject string) (Identity, error) {
+	const q = `select * from user_identities where issuer = :issuer and subject = :subject`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_identities", "QueryIdentity"))
+
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, map[string]any{
+		"issuer":  issuer,
+		"subject": subject,
+	})
+	if err != nil {
+		return Identity{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return Identity{}, database.ErrNotFound
+	}
+
+	var identity Identity
+	err = rows.StructScan(&identity)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	return identity, nil
+}
+
+// UpdateIdentityPermissions stores the permissions granted by the identity provider.
+func (s Store) UpdateIdentityPermissions(ctx context.Context, identity Identity) error {
+	const q = `
+		update user_identities set
+			permissions = :permissions
+		where issuer = :issuer and subject = :subject
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("user_identities", "UpdateIdentityPermissions"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, identity)
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+// UpdateProfile stores the profile and preferences of the user.
+func (s Store) UpdateProfile(ctx context.Context, user User) error {
+	const q = `
+		update users set
+			display_name = :display_name,
+			locale = :locale,
+			notifications = :notifications,
+			date_updated = :date_updated
+		where uuid = :uuid
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "UpdateProfile"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, user)
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+func (s Store) QueryIdentities(ctx context.Context, uuid string) ([]Identity, error) {
+	const q = `select * from user_identities where user_uuid = :uuid order by created_at`
+
+	var identities []Identity
+	err := s.selectAll(ctx, "user_identities", "QueryIdentities", q, map[string]any{"uuid": uuid}, &identities)
+	return identities, err
+}
+
+func (s Store) QueryTokens(ctx context.Context, uuid string) ([]Token, error) {
+	const q = `select * from user_tokens where user_uuid = :uuid order by created_at`
+
+	var tokens []Token
+	err := s.selectAll(ctx, "user_tokens", "QueryTokens", q, map[string]any{"uuid": uuid}, &tokens)
+	return tokens, err
+}
+
+func (s Store) QueryRefreshTokens(ctx context.Context, uuid string) ([]RefreshToken, error) {
+	const q = `select * from refresh_tokens where user_uuid = :uuid order by created_at`
+
+	var tokens []RefreshToken
+	err := s.selectAll(ctx, "refresh_tokens", "QueryRefreshTokens", q, map[string]any{"uuid": uuid}, &tokens)
+	return tokens, err
+}
+
+func (s Store) QueryRecoveryCodes(ctx context.Context, uuid string) ([]RecoveryCode, error) {
+	const q = `select * from recovery_codes where user_uuid = :uuid order by created_at`
+
+	var codes []RecoveryCode
+	err := s.selectAll(ctx, "recovery_codes", "QueryRecoveryCodes", q, map[string]any{"uuid": uuid}, &codes)
+	return codes, err
+}
+
+// QueryAPIKeys returns api keys created by the user.
+func (s Store) QueryAPIKeys(ctx context.Context, uuid string) ([]APIKey, error) {
+	const q = `select id, name, prefix, created_at, revoked_at from api_keys where created_by = :uuid order by created_at`
+
+	var keys []APIKey
+	err := s.selectAll(ctx, "api_keys", "QueryAPIKeys", q, map[string]any{"uuid": uuid}, &keys)
+	return keys, err
+}
+
+// QueryMail returns messages queued for the email.
+func (s Store) QueryMail(ctx context.Context, email string) ([]Mail, error) {
+	const q = `select id, subject, body, created_at, sent_at from mail_outbox where recipient = :email order by created_at`
+
+	var mail []Mail
+	err := s.selectAll(ctx, "mail_outbox", "QueryMail", q, map[string]any{"email": email}, &mail)
+	return mail, err
+}
+
+// QueryEvents returns security events about the user and the ones done by the user.
+func (s Store) QueryEvents(ctx context.Context, uuid string) ([]Event, error) {
+	const q = `
+		select id, type, outcome, actor, ip, user_agent, details, created_at
+		from security_events
+		where user_uuid = :uuid or actor = :uuid
+		order by created_at`
+
+	var events []Event
+	err := s.selectAll(ctx, "security_events", "QueryEvents", q, map[string]any{"uuid": uuid}, &events)
+	return events, err
+}
+
+// Erase removes personal data of the user from all tables in a single statement.
+// The row of the user is kept with the email replaced, so data referring to the user,
+// like aggregates, stays intact. Credentials are dropped, so the user can't sign in.
+// Security events are kept as well, but without the client IP and the user agent of
+// requests made by the user, and with the user removed as the actor.
+func (s Store) Erase(ctx context.Context, uuid string, email string, erasedAt time.Time) error {
+	const q = `
+		with tokens as (
+			delete from user_tokens where user_uuid = :uuid
+		), refresh as (
+			delete from refresh_tokens where user_uuid = :uuid
+		), codes as (
+			delete from recovery_codes where user_uuid = :uuid
+		), identities as (
+			delete from user_identities where user_uuid = :uuid
+		), roles as (
+			delete from user_roles where user_uuid = :uuid
+		), keys as (
+			update api_keys set created_by = null where created_by = :uuid
+		), mail as (
+			delete from mail_outbox where recipient = (select email from users where uuid = :uuid)
+		), events as (
+			update security_events set
+				ip = '',
+				user_agent = '',
+				actor = nullif(actor, :uuid)
+			where actor = :uuid or (actor is null and user_uuid = :uuid)
+		)
+		update users set
+			email = :email,
+			permissions = '{}',
+			password_hash = null,
+			password_changed_at = :erased_at,
+			totp_secret = null,
+			totp_enabled_at = null,
+			totp_last_counter = null,
+			display_name = '',
+			locale = 'en',
+			notifications = '{}',
+			disabled_at = coalesce(disabled_at, :erased_at),
+			erased_at = :erased_at,
+			date_updated = :erased_at
+		where uuid = :uuid and erased_at is null
+	`
+
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", "Erase"))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":      uuid,
+		"email":     email,
+		"erased_at": erasedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+// private
+
+func (s Store) queryRefreshToken(ctx context.Context, ext *database.ExtContext, q string, data map[string]any) (RefreshToken, error) {
+	rows, err := sqlx.NamedQueryContext(ctx, ext, q, data)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return RefreshToken{}, database.ErrNotFound
+	}
+
+	var token RefreshToken
+	err = rows.StructScan(&token)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	return token, nil
+}
+
+// foreignKeyViolation lib/pq errorCodeNames
+const foreignKeyViolation = "23503"
+
+func (s Store) updatePermissions(ctx context.Context, name string, q string, uuid string, permission string) error {
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("users", name))
+
+	res, err := sqlx.NamedExecContext(ctx, ext, q, map[string]any{
+		"uuid":       uuid,
+		"permission": permission,
+	})
+	if err != nil {
+		return err
+	}
+
+	return affected(res)
+}
+
+func affected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return database.ErrNotFound
+	}
+	return nil
+}
+
+func paging(page int, rowsPerPage int) (int, int) {
+	// Ensure page is set correctly
+	if page < 1 {
+		page = 1
+	}
+
+	// Ensure rowsPerPage is set correctly
+	if rowsPerPage < 1 || rowsPerPage > 20 {
+		rowsPerPage = 20
+	}
+
+	return page, rowsPerPage
+}
+
+// selectAll runs the named query and scans all the rows into dest, which must be a pointer to slice.
+func (s Store) selectAll(ctx context.Context, table, name, q string, data map[string]any, dest any) error {
+	ext := s.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric(table, name))
+
+	query, args, err := ext.BindNamed(q, data)
+	if err != nil {
+		return err
+	}
+
+	return sqlx.SelectContext(ctx, ext, dest, query, args...)
+}
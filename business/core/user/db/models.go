@@ -1,6 +1,7 @@
 package db
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/lib/pq"
@@ -13,4 +14,94 @@ type User struct {
 	PasswordHash []byte         `db:"password_hash"`
 	CreatedAt    time.Time      `db:"date_created"`
 	UpdatedAt    time.Time      `db:"date_updated"`
+	VerifiedAt   sql.NullTime   `db:"verified_at"`
+	// PasswordChangedAt is set every time password changes. Access tokens issued
+	// before that moment are no longer accepted.
+	PasswordChangedAt sql.NullTime `db:"password_changed_at"`
+	DisabledAt        sql.NullTime `db:"disabled_at"`
+	// TOTPSecret is set on enrolment, but two-factor authentication is required
+	// only once the enrolment is confirmed and TOTPEnabledAt is set.
+	TOTPSecret    sql.NullString `db:"totp_secret"`
+	TOTPEnabledAt sql.NullTime   `db:"totp_enabled_at"`
+	// TOTPLastCounter is the time step of the last accepted code, so no code is accepted twice.
+	TOTPLastCounter sql.NullInt64  `db:"totp_last_counter"`
+	DisplayName     string         `db:"display_name"`
+	Locale          string         `db:"locale"`
+	Notifications   pq.StringArray `db:"notifications"`
+	// Branch is the attribute checked by resource policies.
+	Branch string `db:"branch"`
+	// ErasedAt is set once personal data of the user is erased. The row itself
+	// is kept, so data referring to the user stays consistent.
+	ErasedAt sql.NullTime `db:"erased_at"`
+
+	// Roles and RolePermissions are not stored in the users table.
+	Roles           pq.StringArray `db:"roles"`
+	RolePermissions pq.StringArray `db:"role_permissions"`
+}
+
+type Token struct {
+	TokenHash string       `db:"token_hash"`
+	UserUUID  string       `db:"user_uuid"`
+	Purpose   string       `db:"purpose"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+type RefreshToken struct {
+	TokenHash string       `db:"token_hash"`
+	FamilyID  string       `db:"family_id"`
+	UserUUID  string       `db:"user_uuid"`
+	ExpiresAt time.Time    `db:"expires_at"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+// Identity links the user to the account at external identity provider.
+type Identity struct {
+	Issuer    string    `db:"issuer"`
+	Subject   string    `db:"subject"`
+	UserUUID  string    `db:"user_uuid"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+	// Permissions are the ones granted to the user by the identity provider.
+	Permissions pq.StringArray `db:"permissions"`
+}
+
+type RecoveryCode struct {
+	CodeHash  string       `db:"code_hash"`
+	UserUUID  string       `db:"user_uuid"`
+	UsedAt    sql.NullTime `db:"used_at"`
+	CreatedAt time.Time    `db:"created_at"`
+}
+
+// APIKey is the part of api key relevant to its creator.
+type APIKey struct {
+	ID        string       `db:"id"`
+	Name      string       `db:"name"`
+	Prefix    string       `db:"prefix"`
+	CreatedAt time.Time    `db:"created_at"`
+	RevokedAt sql.NullTime `db:"revoked_at"`
+}
+
+// Mail is the message queued for the user.
+type Mail struct {
+	ID        string       `db:"id"`
+	Subject   string       `db:"subject"`
+	Body      string       `db:"body"`
+	CreatedAt time.Time    `db:"created_at"`
+	SentAt    sql.NullTime `db:"sent_at"`
+}
+
+// Event is the security event about the user or done by the user.
+type Event struct {
+	ID        string         `db:"id"`
+	Type      string         `db:"type"`
+	Outcome   string         `db:"outcome"`
+	Actor     sql.NullString `db:"actor"`
+	IP        string         `db:"ip"`
+	UserAgent string         `db:"user_agent"`
+	Details   string         `db:"details"`
+	CreatedAt time.Time      `db:"created_at"`
 }
@@ -0,0 +1,202 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	uid "github.com/google/uuid"
+	"github.com/tchorzewski1991/bds/business/core/user/db"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"github.com/tchorzewski1991/bds/business/sys/oidc"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrEmailNotVerified = errors.New("email is not verified by the identity provider")
+
+// LoginExternal returns claims of the user signed in at external identity provider.
+// The first login links the identity to the user with the same email, or creates
+// a new user when there is none. Linking requires the email to be verified by the
+// provider. Permissions mapped from the claims are granted to the user on every login,
+// and the ones granted by the provider before, which are no longer mapped, are revoked.
+// Second factor is left to the provider, so TOTP is not required here.
+func (c Core) LoginExternal(ctx context.Context, id ExternalIdentity) (auth.Claims, error) {
+	user, err := c.store.QueryByIdentity(ctx, id.Issuer, id.Subject)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		user, err = c.linkIdentity(ctx, id)
+		if err != nil {
+			if errors.Is(err, ErrEmailNotVerified) {
+				c.record(ctx, auth.EventLogin, auth.OutcomeFailure, "", "email not verified by identity provider "+id.Issuer)
+			}
+			return auth.Claims{}, err
+		}
+	case err != nil:
+		return auth.Claims{}, fmt.Errorf("external login failed: %w", err)
+	}
+
+	if user.DisabledAt.Valid {
+		c.record(ctx, auth.EventLogin, auth.OutcomeFailure, user.UUID, "user disabled")
+		return auth.Claims{}, ErrDisabled
+	}
+
+	identity, err := c.store.QueryIdentity(ctx, id.Issuer, id.Subject)
+	if err != nil {
+		return auth.Claims{}, fmt.Errorf("external login failed: %w", err)
+	}
+
+	// Only the permissions granted by the provider are tracked, so the ones the user
+	// got otherwise are never revoked by it.
+	grant, revoke, tracked := oidc.Reconcile(user.Permissions, identity.Permissions, id.Permissions)
+
+	for _, p := range grant {
+		err = checkPermission(p)
+		if err != nil {
+			return auth.Claims{}, err
+		}
+		err = c.store.GrantPermission(ctx, user.UUID, p)
+		if err != nil {
+			return auth.Claims{}, fmt.Errorf("granting permission: %w", err)
+		}
+		c.record(ctx, auth.EventPermissionChange, auth.OutcomeSuccess, user.UUID,
+			fmt.Sprintf("permission %q granted by identity provider %s", p, id.Issuer))
+	}
+
+	for _, p := range revoke {
+		err = c.store.RevokePermission(ctx, user.UUID, p)
+		if err != nil {
+			return auth.Claims{}, fmt.Errorf("revoking permission: %w", err)
+		}
+		c.record(ctx, auth.EventPermissionChange, auth.OutcomeSuccess, user.UUID,
+			fmt.Sprintf("permission %q revoked, no longer granted by identity provider %s", p, id.Issuer))
+	}
+
+	if !equal(identity.Permissions, tracked) {
+		identity.Permissions = tracked
+		err = c.store.UpdateIdentityPermissions(ctx, identity)
+		if err != nil {
+			return auth.Claims{}, fmt.Errorf("external login failed: %w", err)
+		}
+	}
+
+	if len(grant) > 0 || len(revoke) > 0 {
+		c.states.forget(user.UUID)
+
+		user, err = c.store.QueryByUUID(ctx, user.UUID)
+		if err != nil {
+			return auth.Claims{}, fmt.Errorf("external login failed: %w", err)
+		}
+	}
+
+	c.record(ctx, auth.EventLogin, auth.OutcomeSuccess, user.UUID, "identity provider "+id.Issuer)
+
+	return newClaims(user), nil
+}
+
+// private
+
+// linkIdentity links the identity to the user with the same email, creating the user if needed.
+func (c Core) linkIdentity(ctx context.Context, id ExternalIdentity) (db.User, error) {
+	email := strings.TrimSpace(id.Email)
+	if !id.EmailVerified || checkEmail(email) != nil {
+		return db.User{}, ErrEmailNotVerified
+	}
+
+	user, err := c.store.QueryByEmail(ctx, email)
+	switch {
+	case errors.Is(err, database.ErrNotFound):
+		user, err = c.provision(ctx, email)
+		if err != nil {
+			return db.User{}, err
+		}
+	case err != nil:
+		return db.User{}, fmt.Errorf("link identity failed: %w", err)
+	case !user.VerifiedAt.Valid:
+		// The provider has verified the email, which is what the verification token would do.
+		err = c.store.MarkVerified(ctx, user.UUID)
+		if err != nil {
+			return db.User{}, fmt.Errorf("link identity failed: %w", err)
+		}
+	}
+
+	err = c.store.CreateIdentity(ctx, db.Identity{
+		Issuer:    id.Issuer,
+		Subject:   id.Subject,
+		UserUUID:  user.UUID,
+		Email:     email,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil && !errors.Is(err, database.ErrNotUnique) {
+		return db.User{}, fmt.Errorf("link identity failed: %w", err)
+	}
+
+	// The identity may have been linked by concurrent login, so it's read back.
+	user, err = c.store.QueryByIdentity(ctx, id.Issuer, id.Subject)
+	if err != nil {
+		return db.User{}, fmt.Errorf("link identity failed: %w", err)
+	}
+
+	return user, nil
+}
+
+// provision creates verified user without usable password. The password can be
+// set later with the password reset.
+func (c Core) provision(ctx context.Context, email string) (db.User, error) {
+	pass, err := randomToken()
+	if err != nil {
+		return db.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return db.User{}, fmt.Errorf("generating password hash: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	user := db.User{
+		UUID:         uid.NewString(),
+		Email:        email,
+		Permissions:  defaultPermissions,
+		PasswordHash: hash,
+		VerifiedAt:   sql.NullTime{Time: now, Valid: true},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err = c.store.Create(ctx, user)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotUnique) {
+			return db.User{}, fmt.Errorf("provision user failed: %w", err)
+		}
+		// Created by concurrent login in the meantime.
+		return c.store.QueryByEmail(ctx, email)
+	}
+
+	return user, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
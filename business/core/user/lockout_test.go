@@ -0,0 +1,73 @@
+package user
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/business/sys/lockout"
+)
+
+// TestIPLockout checks failed logins are limited per IP, so guessing passwords of
+// many accounts from a single address is stopped before any account gets locked.
+func TestIPLockout(t *testing.T) {
+	c := Core{
+		accounts: lockout.New(accountPolicy),
+		ips:      lockout.New(ipPolicy),
+	}
+
+	const ip = "203.0.113.7"
+
+	for i := 0; i < ipPolicy.Threshold; i++ {
+		account := fmt.Sprintf("user%d@example.com", i)
+		c.fail(account, ip)
+
+		if got := c.accounts.Locked(account); got != 0 {
+			t.Fatalf("expected single failure not to lock %s, got %s", account, got)
+		}
+		if got := c.ips.Locked(ip); i < ipPolicy.Threshold-1 && got != 0 {
+			t.Fatalf("expected ip not to be locked after %d failures, got %s", i+1, got)
+		}
+	}
+
+	if got := c.ips.Locked(ip); got <= 0 || got > ipPolicy.BaseDelay {
+		t.Fatalf("expected ip to be locked for %s, got %s", ipPolicy.BaseDelay, got)
+	}
+	if got := c.ips.Locked("203.0.113.8"); got != 0 {
+		t.Fatalf("expected other ip not to be locked, got %s", got)
+	}
+
+	// Requests without known IP are limited per account only.
+	for i := 0; i < accountPolicy.Threshold; i++ {
+		c.fail("alice@example.com", "")
+	}
+	if got := c.accounts.Locked("alice@example.com"); got <= 0 || got > accountPolicy.BaseDelay {
+		t.Fatalf("expected account to be locked for %s, got %s", accountPolicy.BaseDelay, got)
+	}
+	if got := c.ips.Locked(""); got != 0 {
+		t.Fatalf("expected empty ip not to be locked, got %s", got)
+	}
+}
+
+// TestNormalizeEmail checks that the variants of an address users type at login
+// share the lockout of the account.
+func TestNormalizeEmail(t *testing.T) {
+	c := Core{
+		accounts: lockout.New(accountPolicy),
+		ips:      lockout.New(ipPolicy),
+	}
+
+	variants := []string{"Alice@Example.com", " alice@example.com", "ALICE@EXAMPLE.COM\t"}
+
+	for i := 0; i < accountPolicy.Threshold; i++ {
+		c.fail(normalizeEmail(variants[i%len(variants)]), "")
+	}
+
+	for _, email := range variants {
+		if got := normalizeEmail(email); got != "alice@example.com" {
+			t.Errorf("expected %q normalized to alice@example.com, got %q", email, got)
+		}
+		if got := c.accounts.Locked(normalizeEmail(email)); got <= 0 {
+			t.Errorf("expected %q to be locked out, got %s", email, got)
+		}
+	}
+}
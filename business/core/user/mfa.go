@@ -0,0 +1,235 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	uid "github.com/google/uuid"
+	"github.com/tchorzewski1991/bds/base/qr"
+	"github.com/tchorzewski1991/bds/base/totp"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/core/user/db"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+)
+
+var (
+	ErrTOTPEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication enrolment has not been started")
+	ErrInvalidCode     = errors.New("code is not valid")
+)
+
+const (
+	totpIssuer      = "bds"
+	mfaChallengeTTL = 5 * time.Minute
+	recoveryCodes   = 10
+)
+
+// EnrollTOTP starts the TOTP enrolment of the user. Two-factor authentication
+// is not required until the enrolment is confirmed with ConfirmTOTP.
+// Starting again replaces the pending secret.
+func (c Core) EnrollTOTP(ctx context.Context, uuid string) (TOTPEnrollment, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	if user.TOTPEnabledAt.Valid {
+		return TOTPEnrollment{}, ErrTOTPEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return TOTPEnrollment{}, err
+	}
+
+	err = c.store.SetTOTPSecret(ctx, user.UUID, secret)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return TOTPEnrollment{}, ErrTOTPEnabled
+		}
+		return TOTPEnrollment{}, fmt.Errorf("enroll totp failed: %w", err)
+	}
+
+	uri := totp.URI(totpIssuer, user.Email, secret)
+
+	// The lowest error correction level leaves the most space for long emails.
+	code, err := qr.Encode([]byte(uri), qr.L)
+	if err != nil {
+		return TOTPEnrollment{}, fmt.Errorf("encoding qr code: %w", err)
+	}
+
+	return TOTPEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: code.SVGElement(qr.DefaultModule),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves the authenticator
+// app generates valid codes. It returns one-time recovery codes, which replace the
+// app when it's lost. Recovery codes are shown only once, as only their hashes are stored.
+func (c Core) ConfirmTOTP(ctx context.Context, uuid string, code string) ([]string, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case user.TOTPEnabledAt.Valid:
+		return nil, ErrTOTPEnabled
+	case !user.TOTPSecret.Valid:
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	now := time.Now().UTC()
+
+	counter, ok := totp.Validate(user.TOTPSecret.String, strings.TrimSpace(code), now)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes := make([]string, recoveryCodes)
+	hashes := make([]string, recoveryCodes)
+	for i := range codes {
+		codes[i], err = recoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	err = c.store.EnableTOTP(ctx, user.UUID, counter, hashes, now)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil, ErrTOTPNotEnrolled
+		}
+		return nil, fmt.Errorf("confirm totp failed: %w", err)
+	}
+
+	c.record(ctx, auth.EventAccountChange, auth.OutcomeSuccess, user.UUID, "two-factor authentication enabled")
+
+	return codes, nil
+}
+
+// CompleteMFA exchanges the challenge claims returned by Authenticate for access claims.
+// Code is either the current TOTP code or one of the unused recovery codes. Failures
+// count towards the same lockout as wrong passwords.
+func (c Core) CompleteMFA(ctx context.Context, challenge auth.Claims, code string) (auth.Claims, error) {
+	if challenge.Kind != auth.KindMFA {
+		return auth.Claims{}, ErrInvalidToken
+	}
+
+	user, err := c.store.QueryByUUID(ctx, challenge.Subject)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return auth.Claims{}, ErrInvalidToken
+		}
+		return auth.Claims{}, fmt.Errorf("complete mfa failed: %w", err)
+	}
+
+	account := normalizeEmail(user.Email)
+	ip := web.GetClientIP(ctx)
+
+	wait := c.accounts.Locked(account)
+	if w := c.ips.Locked(ip); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		c.record(ctx, auth.EventMFA, auth.OutcomeFailure, user.UUID, fmt.Sprintf("locked out for %s", wait.Round(time.Second)))
+		return auth.Claims{}, &LockedError{RetryAfter: wait}
+	}
+
+	switch {
+	case user.DisabledAt.Valid:
+		c.record(ctx, auth.EventMFA, auth.OutcomeFailure, user.UUID, "user disabled")
+		return auth.Claims{}, ErrDisabled
+	case !user.TOTPEnabledAt.Valid:
+		return auth.Claims{}, ErrInvalidToken
+	}
+
+	err = c.checkSecondFactor(ctx, user, strings.TrimSpace(code))
+	if err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			c.fail(account, ip)
+			c.record(ctx, auth.EventMFA, auth.OutcomeFailure, user.UUID, "invalid code")
+		}
+		return auth.Claims{}, err
+	}
+
+	c.accounts.Reset(account)
+	c.record(ctx, auth.EventMFA, auth.OutcomeSuccess, user.UUID, "second factor confirmed")
+
+	return newClaims(user), nil
+}
+
+// private
+
+func (c Core) checkSecondFactor(ctx context.Context, user db.User, code string) error {
+	var err error
+
+	if len(code) == totp.Digits {
+		counter, ok := totp.Validate(user.TOTPSecret.String, code, time.Now().UTC())
+		if !ok {
+			return ErrInvalidCode
+		}
+		// Each code is accepted only once, even within its period.
+		err = c.store.UseTOTPCounter(ctx, user.UUID, counter)
+	} else {
+		err = c.store.UseRecoveryCode(ctx, user.UUID, hashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrInvalidCode
+		}
+		return fmt.Errorf("checking second factor: %w", err)
+	}
+
+	return nil
+}
+
+// newChallengeClaims builds claims of the short-lived token proving the password.
+// They carry no permissions and are refused by the authentication middleware.
+func newChallengeClaims(user db.User) auth.Claims {
+	now := time.Now().UTC()
+
+	return auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uid.NewString(),
+			Issuer:    "bds-api",
+			Subject:   user.UUID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Kind: auth.KindMFA,
+	}
+}
+
+// recoveryCode returns random code formatted as two groups of characters, like abcde-fghij.
+func recoveryCode() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz234567"
+
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("generating recovery code: %w", err)
+	}
+
+	// Alphabet has 32 characters, so every byte maps to them evenly.
+	for i := range b {
+		b[i] = alphabet[int(b[i])%len(alphabet)]
+	}
+
+	return string(b[:5]) + "-" + string(b[5:]), nil
+}
+
+// normalizeRecoveryCode accepts codes typed with different case or without the dash.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(code, "-", ""))
+}
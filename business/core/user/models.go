@@ -1,8 +1,172 @@
 package user
 
+import (
+	"fmt"
+	"time"
+)
+
 // User is a business representation of the user entity.
 type User struct {
 	UUID        string
 	Email       string
 	Permissions []string
+	Roles       []string
+	VerifiedAt  time.Time
+	DisabledAt  time.Time
+	// TOTPEnabledAt is zero unless two-factor authentication is enabled.
+	TOTPEnabledAt time.Time
+	DisplayName   string
+	Locale        string
+	Notifications []string
+	// Branch is the attribute of the user checked by resource policies, see auth.SameBranch.
+	Branch    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Disabled reports whether the user has been disabled by an admin.
+func (u User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+// NewUser contains information needed to register a new user.
+type NewUser struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateUser contains information which admins are able to change.
+// Nil fields are left untouched.
+type UpdateUser struct {
+	Email       *string  `json:"email" validate:"email"`
+	Permissions []string `json:"permissions"`
+	// Branch is cleared with the empty string.
+	Branch *string `json:"branch" validate:"max=100"`
+}
+
+// Kinds of notifications users are able to subscribe to.
+const (
+	NotifySecurity   = "security"
+	NotifyNewBooks   = "new_books"
+	NotifyNewsletter = "newsletter"
+)
+
+// Profile is the representation of the user visible to the user itself.
+type Profile struct {
+	UUID          string     `json:"uuid"`
+	Email         string     `json:"email"`
+	DisplayName   string     `json:"display_name"`
+	Locale        string     `json:"locale"`
+	Notifications []string   `json:"notifications"`
+	Permissions   []string   `json:"permissions"`
+	Roles         []string   `json:"roles"`
+	Branch        string     `json:"branch"`
+	VerifiedAt    *time.Time `json:"verified_at"`
+	TOTPEnabled   bool       `json:"totp_enabled"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// UpdateProfile contains information which users are able to change themselves.
+// Nil fields are left untouched.
+type UpdateProfile struct {
+	DisplayName   *string  `json:"display_name" validate:"max=100"`
+	Locale        *string  `json:"locale" validate:"regex=^[a-z]{2,3}(-[A-Z]{2}|-[0-9]{3})?$"`
+	Notifications []string `json:"notifications" validate:"enum=security new_books newsletter"`
+}
+
+// Export holds everything stored about the user. Secrets, like password
+// and token hashes, are left out.
+type Export struct {
+	Profile    Profile          `json:"profile"`
+	Identities []ExportIdentity `json:"identities"`
+	Tokens     []ExportToken    `json:"tokens"`
+	APIKeys    []ExportAPIKey   `json:"api_keys"`
+	Mail       []ExportMail     `json:"mail"`
+	Events     []ExportEvent    `json:"security_events"`
+}
+
+// ExportIdentity is the account at external identity provider linked to the user.
+type ExportIdentity struct {
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	// Permissions are the ones granted by the identity provider.
+	Permissions []string `json:"permissions"`
+}
+
+// ExportToken is any of the tokens issued to the user: email verification and
+// password reset tokens, refresh tokens and recovery codes.
+type ExportToken struct {
+	Kind      string     `json:"kind"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ExportAPIKey is the api key created by the user.
+type ExportAPIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// ExportMail is the message sent to the user.
+type ExportMail struct {
+	ID        string     `json:"id"`
+	Subject   string     `json:"subject"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at"`
+}
+
+// ExportEvent is the security event about the user or done by the user.
+// Actor is set when the action was done by someone else, e.g. the admin.
+type ExportEvent struct {
+	Type      string    `json:"type"`
+	Outcome   string    `json:"outcome"`
+	Actor     *string   `json:"actor"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TOTPEnrollment contains the secret of pending TOTP enrolment in forms accepted
+// by authenticator apps: raw, as otpauth:// URI and as QR code of the URI.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	// QRCode is SVG document.
+	QRCode string `json:"qr_code"`
+}
+
+// ExternalIdentity is the identity of the user asserted by external identity provider.
+type ExternalIdentity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Permissions are mapped from the claims of the identity. They are granted on top
+	// of the ones user already has, the ones mapped at former logins but not anymore
+	// are revoked.
+	Permissions []string
+}
+
+type FieldError struct {
+	field string
+	err   string
+}
+
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("%s %s", fe.field, fe.err)
+}
+
+// Details returns the problem keyed by the JSON name of the field.
+func (fe FieldError) Details() map[string]string {
+	return map[string]string{fe.field: fe.err}
 }
@@ -0,0 +1,27 @@
+package user
+
+import "github.com/tchorzewski1991/bds/business/sys/auth"
+
+// Actions on users checked by Policy.
+const (
+	ActionRead = "read"
+)
+
+// Policy allows users to act on their own account, and admins on every account.
+var Policy = auth.Policy{
+	ActionRead: {auth.OwnerOrAdmin("users.admin")},
+}
+
+// Resource describes the user for policy evaluation. The user owns itself.
+func Resource(u User) auth.Resource {
+	r := auth.Resource{
+		Type:  "user",
+		ID:    u.UUID,
+		Owner: u.UUID,
+		Value: u,
+	}
+	if u.Branch != "" {
+		r.Attributes = map[string]string{"branch": u.Branch}
+	}
+	return r
+}
@@ -0,0 +1,284 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+)
+
+// notificationKinds holds every kind of notification users can subscribe to.
+var notificationKinds = map[string]bool{
+	NotifySecurity:   true,
+	NotifyNewBooks:   true,
+	NotifyNewsletter: true,
+}
+
+// localeRegex accepts language tags like en, pl or pt-BR.
+var localeRegex = regexp.MustCompile(`^[a-z]{2,3}(-[A-Z]{2}|-[0-9]{3})?$`)
+
+const maxDisplayName = 100
+
+// Profile returns the representation of the user visible to the user itself.
+func (u User) Profile() Profile {
+	p := Profile{
+		UUID:          u.UUID,
+		Email:         u.Email,
+		DisplayName:   u.DisplayName,
+		Locale:        u.Locale,
+		Notifications: u.Notifications,
+		Permissions:   u.Permissions,
+		Roles:         u.Roles,
+		Branch:        u.Branch,
+		TOTPEnabled:   !u.TOTPEnabledAt.IsZero(),
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+	}
+	for _, list := range []*[]string{&p.Notifications, &p.Permissions, &p.Roles} {
+		if *list == nil {
+			*list = []string{}
+		}
+	}
+	if !u.VerifiedAt.IsZero() {
+		p.VerifiedAt = &u.VerifiedAt
+	}
+	return p
+}
+
+// UpdateProfile changes the profile and preferences of the user.
+func (c Core) UpdateProfile(ctx context.Context, uuid string, up UpdateProfile) (User, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return User{}, err
+	}
+
+	if up.DisplayName != nil {
+		name := strings.TrimSpace(*up.DisplayName)
+		if err := checkDisplayName(name); err != nil {
+			return User{}, err
+		}
+		user.DisplayName = name
+	}
+
+	if up.Locale != nil {
+		if !localeRegex.MatchString(*up.Locale) {
+			return User{}, FieldError{field: "locale", err: "is not a valid language tag, like en or pt-BR"}
+		}
+		user.Locale = *up.Locale
+	}
+
+	if up.Notifications != nil {
+		seen := make(map[string]bool, len(up.Notifications))
+		notifications := make([]string, 0, len(up.Notifications))
+		for _, n := range up.Notifications {
+			if !notificationKinds[n] {
+				return User{}, FieldError{field: "notifications", err: fmt.Sprintf("%q is not a known kind of notification", n)}
+			}
+			if !seen[n] {
+				seen[n] = true
+				notifications = append(notifications, n)
+			}
+		}
+		user.Notifications = notifications
+	}
+
+	user.UpdatedAt = time.Now().UTC()
+
+	err = c.store.UpdateProfile(ctx, user)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("update profile failed: %w", err)
+	}
+
+	return convertToUser(user), nil
+}
+
+// Export collects everything stored about the user.
+func (c Core) Export(ctx context.Context, uuid string) (Export, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return Export{}, err
+	}
+
+	identities, err := c.store.QueryIdentities(ctx, uuid)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	tokens, err := c.store.QueryTokens(ctx, uuid)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	refreshTokens, err := c.store.QueryRefreshTokens(ctx, uuid)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	codes, err := c.store.QueryRecoveryCodes(ctx, uuid)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	keys, err := c.store.QueryAPIKeys(ctx, uuid)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	mail, err := c.store.QueryMail(ctx, user.Email)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	events, err := c.store.QueryEvents(ctx, uuid)
+	if err != nil {
+		return Export{}, fmt.Errorf("export failed: %w", err)
+	}
+
+	e := Export{
+		Profile:    convertToUser(user).Profile(),
+		Identities: make([]ExportIdentity, 0, len(identities)),
+		Tokens:     make([]ExportToken, 0, len(tokens)+len(refreshTokens)+len(codes)),
+		APIKeys:    make([]ExportAPIKey, 0, len(keys)),
+		Mail:       make([]ExportMail, 0, len(mail)),
+		Events:     make([]ExportEvent, 0, len(events)),
+	}
+
+	for _, id := range identities {
+		e.Identities = append(e.Identities, ExportIdentity{
+			Issuer:      id.Issuer,
+			Subject:     id.Subject,
+			Email:       id.Email,
+			CreatedAt:   id.CreatedAt,
+			Permissions: id.Permissions,
+		})
+	}
+	for _, t := range tokens {
+		expires := t.ExpiresAt
+		e.Tokens = append(e.Tokens, ExportToken{
+			Kind:      t.Purpose,
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: &expires,
+			UsedAt:    timePtr(t.UsedAt),
+		})
+	}
+	for _, t := range refreshTokens {
+		expires := t.ExpiresAt
+		e.Tokens = append(e.Tokens, ExportToken{
+			Kind:      "refresh_token",
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: &expires,
+			UsedAt:    timePtr(t.UsedAt),
+			RevokedAt: timePtr(t.RevokedAt),
+		})
+	}
+	for _, rc := range codes {
+		e.Tokens = append(e.Tokens, ExportToken{
+			Kind:      "recovery_code",
+			CreatedAt: rc.CreatedAt,
+			UsedAt:    timePtr(rc.UsedAt),
+		})
+	}
+	for _, k := range keys {
+		e.APIKeys = append(e.APIKeys, ExportAPIKey{
+			ID:        k.ID,
+			Name:      k.Name,
+			Prefix:    k.Prefix,
+			CreatedAt: k.CreatedAt,
+			RevokedAt: timePtr(k.RevokedAt),
+		})
+	}
+	for _, m := range mail {
+		e.Mail = append(e.Mail, ExportMail{
+			ID:        m.ID,
+			Subject:   m.Subject,
+			Body:      m.Body,
+			CreatedAt: m.CreatedAt,
+			SentAt:    timePtr(m.SentAt),
+		})
+	}
+	for _, ev := range events {
+		ee := ExportEvent{
+			Type:      ev.Type,
+			Outcome:   ev.Outcome,
+			IP:        ev.IP,
+			UserAgent: ev.UserAgent,
+			Details:   ev.Details,
+			CreatedAt: ev.CreatedAt,
+		}
+		if ev.Actor.Valid && ev.Actor.String != uuid {
+			actor := ev.Actor.String
+			ee.Actor = &actor
+		}
+		e.Events = append(e.Events, ee)
+	}
+
+	return e, nil
+}
+
+// Erase anonymizes the user. Personal data is removed from all tables and
+// credentials are dropped, but the user itself is kept with a placeholder email,
+// so aggregates referring to it stay intact. It can't be undone.
+func (c Core) Erase(ctx context.Context, uuid string) error {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return err
+	}
+
+	err = c.store.Erase(ctx, user.UUID, erasedEmail(user.UUID), time.Now().UTC())
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("erase failed: %w", err)
+	}
+	c.states.forget(user.UUID)
+
+	// The request comes from the user, so it's recorded without the client IP and the user agent.
+	c.events.RecordEvent(ctx, auth.Event{
+		Type:      auth.EventAccountChange,
+		Outcome:   auth.OutcomeSuccess,
+		UserUUID:  user.UUID,
+		Details:   "personal data erased",
+		Anonymous: true,
+	})
+
+	return nil
+}
+
+// private
+
+func checkDisplayName(name string) error {
+	if utf8.RuneCountInString(name) > maxDisplayName {
+		return FieldError{field: "display_name", err: fmt.Sprintf("must be at most %d characters long", maxDisplayName)}
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return FieldError{field: "display_name", err: "can't contain control characters"}
+		}
+	}
+	return nil
+}
+
+// erasedEmail returns the placeholder email of erased user. It's unique, as emails
+// have to be, and uses reserved domain, so nothing is ever delivered to it.
+func erasedEmail(uuid string) string {
+	return "erased-" + uuid + "@erased.invalid"
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
@@ -2,16 +2,29 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
 	"github.com/golang-jwt/jwt/v4"
 	uid "github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/base/web"
 	"github.com/tchorzewski1991/bds/business/core/user/db"
 	"github.com/tchorzewski1991/bds/business/sys/auth"
 	"github.com/tchorzewski1991/bds/business/sys/database"
+	"github.com/tchorzewski1991/bds/business/sys/lockout"
+	mailer "github.com/tchorzewski1991/bds/business/sys/mail"
+	"github.com/tchorzewski1991/bds/business/sys/metrics"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -19,22 +32,447 @@ import (
 var (
 	ErrNotFound         = errors.New("user not found")
 	ErrInvalidUUID      = errors.New("UUID is not valid")
-	ErrInvalidEmail     = errors.New("email is not valid")
 	ErrNotAuthenticated = errors.New("user not authenticated")
+	ErrNotVerified      = errors.New("user email not verified")
+	ErrEmailTaken       = errors.New("email is already taken")
+	ErrInvalidToken     = errors.New("token is not valid")
+	ErrDisabled         = errors.New("user is disabled")
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrTokenReused      = errors.New("refresh token has already been used")
+	ErrLocked           = errors.New("too many failed attempts, try again later")
+)
+
+// LockedError is returned by Authenticate when the account or the IP is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (le *LockedError) Error() string {
+	return ErrLocked.Error()
+}
+
+func (le *LockedError) Unwrap() error {
+	return ErrLocked
+}
+
+// Lockout policies applied to failed logins. IPs get a higher threshold, as many
+// users may share a single address.
+var (
+	accountPolicy = lockout.Policy{Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: 15 * time.Minute, Window: 15 * time.Minute}
+	ipPolicy      = lockout.Policy{Threshold: 20, BaseDelay: 30 * time.Second, MaxDelay: time.Hour, Window: 15 * time.Minute}
+)
+
+// dummyHash is compared with the password when the account doesn't exist,
+// so the response takes the same time in both cases.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+
+// defaultPermissions are granted to every self-registered user.
+var defaultPermissions = []string{"user.profile"}
+
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposePasswordReset = "password_reset"
+	verifyTokenTTL       = 24 * time.Hour
+	resetTokenTTL        = 1 * time.Hour
+	accessTokenTTL       = 1 * time.Hour
+	refreshTokenTTL      = 30 * 24 * time.Hour
 )
 
 // Core manages the set of APIs for user access.
 // Notes:
 // Core does not maintain any state, we should use value semantic.
+// The only exceptions are failed logins and users cached for VerifyClaims, which are
+// shared between copies of Core.
 // Core is responsible for validating user data.
 // Core is responsible for persisting user data.
+// Core records security events of logins, tokens, permissions and passwords.
 type Core struct {
-	store db.Store
+	store    db.Store
+	mailer   mailer.Mailer
+	events   auth.EventRecorder
+	accounts *lockout.Limiter
+	ips      *lockout.Limiter
+	states   *stateCache
+}
+
+// NewCore constructs a Core for user api access. State of users checked by VerifyClaims
+// is cached for syncInterval, changes made by other instances of the service apply
+// after at most this long.
+func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger, m mailer.Mailer, events auth.EventRecorder, syncInterval time.Duration) Core {
+	return Core{
+		store:    db.NewStore(sqlDB, logger),
+		mailer:   m,
+		events:   events,
+		accounts: lockout.New(accountPolicy),
+		ips:      lockout.New(ipPolicy),
+		states: &stateCache{
+			interval: syncInterval,
+			entries:  make(map[string]state),
+		},
+	}
+}
+
+// Register creates an unverified user and sends the verification token to its email.
+// User is not able to authenticate until the token is confirmed with Verify.
+func (c Core) Register(ctx context.Context, nu NewUser) (User, error) {
+	nu.Email = strings.TrimSpace(nu.Email)
+
+	err := checkEmail(nu.Email)
+	if err != nil {
+		return User{}, FieldError{field: "email", err: err.Error()}
+	}
+
+	err = checkPassword(nu.Email, nu.Password)
+	if err != nil {
+		return User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(nu.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return User{}, fmt.Errorf("generating password hash: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	usr := db.User{
+		UUID:         uid.NewString(),
+		Email:        nu.Email,
+		Permissions:  defaultPermissions,
+		PasswordHash: hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	err = c.store.Create(ctx, usr)
+	if err != nil {
+		if errors.Is(err, database.ErrNotUnique) {
+			return User{}, fmt.Errorf("create failed: %w", ErrEmailTaken)
+		}
+		return User{}, fmt.Errorf("create failed: %w", err)
+	}
+
+	token, err := c.issueToken(ctx, usr.UUID, purposeVerifyEmail, verifyTokenTTL)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = c.mailer.Send(ctx, mailer.Message{
+		To:      usr.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Use the following token to verify your email: %s\nIt expires in %s.", token, verifyTokenTTL),
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("sending verification email: %w", err)
+	}
+
+	return convertToUser(usr), nil
+}
+
+// Verify confirms the user's email with the token delivered by Register.
+// Each token can be used only once.
+func (c Core) Verify(ctx context.Context, token string) error {
+	tkn, err := c.store.UseToken(ctx, hashToken(token), purposeVerifyEmail)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("verify failed: %w", err)
+	}
+
+	err = c.store.MarkVerified(ctx, tkn.UserUUID)
+	if err != nil {
+		return fmt.Errorf("verify failed: %w", err)
+	}
+
+	c.record(ctx, auth.EventAccountChange, auth.OutcomeSuccess, tkn.UserUUID, "email verified")
+
+	return nil
+}
+
+// ChangePassword replaces the password of the user after confirming the current one.
+// All tokens issued before the change are invalidated.
+func (c Core) ChangePassword(ctx context.Context, uuid, current, next string) error {
+	err := checkUUID(uuid)
+	if err != nil {
+		return ErrInvalidUUID
+	}
+
+	user, err := c.store.QueryByUUID(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("change password failed: %w", err)
+	}
+
+	err = checkPass(user, current)
+	if err != nil {
+		c.record(ctx, auth.EventPasswordChange, auth.OutcomeFailure, user.UUID, "current password is not valid")
+		return ErrNotAuthenticated
+	}
+
+	err = c.updatePassword(ctx, user, next)
+	if err != nil {
+		return err
+	}
+
+	c.record(ctx, auth.EventPasswordChange, auth.OutcomeSuccess, user.UUID, "password changed")
+
+	return nil
+}
+
+// RequestPasswordReset sends the password reset token to the user. It doesn't report
+// unknown emails, so the caller can't learn which accounts exist.
+func (c Core) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(email)
+
+	err := checkEmail(email)
+	if err != nil {
+		return FieldError{field: "email", err: err.Error()}
+	}
+
+	user, err := c.store.QueryByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return nil
+		}
+		return fmt.Errorf("password reset failed: %w", err)
+	}
+
+	token, err := c.issueToken(ctx, user.UUID, purposePasswordReset, resetTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	err = c.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Use the following token to set a new password: %s\nIt expires in %s.", token, resetTokenTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("sending password reset email: %w", err)
+	}
+
+	c.record(ctx, auth.EventPasswordReset, auth.OutcomeSuccess, user.UUID, "reset requested")
+
+	return nil
+}
+
+// ResetPassword sets the new password using the token delivered by RequestPasswordReset.
+// All tokens issued before the change are invalidated.
+func (c Core) ResetPassword(ctx context.Context, token, next string) error {
+	// Token is only looked up first, so it isn't lost when the new password
+	// doesn't satisfy the policy.
+	tkn, err := c.store.QueryToken(ctx, hashToken(token), purposePasswordReset)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			c.record(ctx, auth.EventPasswordReset, auth.OutcomeFailure, "", "reset token is not valid")
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("password reset failed: %w", err)
+	}
+
+	user, err := c.store.QueryByUUID(ctx, tkn.UserUUID)
+	if err != nil {
+		return fmt.Errorf("password reset failed: %w", err)
+	}
+
+	err = checkPassword(user.Email, next)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.store.UseToken(ctx, tkn.TokenHash, purposePasswordReset)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			c.record(ctx, auth.EventPasswordReset, auth.OutcomeFailure, user.UUID, "reset token is not valid")
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("password reset failed: %w", err)
+	}
+
+	err = c.updatePassword(ctx, user, next)
+	if err != nil {
+		return err
+	}
+
+	c.record(ctx, auth.EventPasswordReset, auth.OutcomeSuccess, user.UUID, "password reset")
+
+	return nil
 }
 
-// NewCore constructs a Core for user api access.
-func NewCore(sqlDB *sqlx.DB, logger *zap.SugaredLogger) Core {
-	return Core{store: db.NewStore(sqlDB, logger)}
+// VerifyClaims implements auth.Verifier. It refuses tokens of users who no longer
+// exist or have been disabled, and tokens issued before the last password change.
+// The state of the user is cached, so checking the token doesn't hit the DB on every request.
+func (c Core) VerifyClaims(ctx context.Context, claims auth.Claims) (auth.Claims, error) {
+	st, err := c.state(ctx, claims.Subject)
+	if err != nil {
+		return auth.Claims{}, err
+	}
+
+	if !st.exists {
+		return auth.Claims{}, auth.ErrTokenRevoked
+	}
+
+	if st.disabled {
+		return auth.Claims{}, fmt.Errorf("user is disabled: %w", auth.ErrTokenRevoked)
+	}
+
+	if !st.passwordChangedAt.IsZero() {
+		// Token timestamps have one second precision, so tokens issued within
+		// the same second as the change are refused as well.
+		changed := st.passwordChangedAt.Truncate(time.Second)
+		if claims.IssuedAt == nil || !claims.IssuedAt.Time.After(changed) {
+			return auth.Claims{}, auth.ErrTokenRevoked
+		}
+	}
+
+	// Current roles, permissions and attributes take precedence over the ones from
+	// the token, so changes apply without logging in again.
+	claims.Roles = st.roles
+	claims.Permissions = st.permissions
+	claims.Attributes = st.attributes
+
+	return claims, nil
+}
+
+// Query returns users matching the search, which is a part of their email.
+func (c Core) Query(ctx context.Context, search string, page int, rowsPerPage int) ([]User, error) {
+	users, err := c.store.Query(ctx, search, page, rowsPerPage)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+
+	result := make([]User, len(users))
+	for i, u := range users {
+		result[i] = convertToUser(u)
+	}
+
+	return result, nil
+}
+
+// Update changes the user with the fields set in the UpdateUser.
+func (c Core) Update(ctx context.Context, uuid string, uu UpdateUser) (User, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return User{}, err
+	}
+	previous := user
+
+	if uu.Email != nil {
+		email := strings.TrimSpace(*uu.Email)
+		err = checkEmail(email)
+		if err != nil {
+			return User{}, FieldError{field: "email", err: err.Error()}
+		}
+		user.Email = email
+	}
+
+	if uu.Permissions != nil {
+		for _, p := range uu.Permissions {
+			err = checkPermission(p)
+			if err != nil {
+				return User{}, err
+			}
+		}
+		user.Permissions = uu.Permissions
+	}
+
+	if uu.Branch != nil {
+		user.Branch = strings.TrimSpace(*uu.Branch)
+	}
+
+	user.UpdatedAt = time.Now().UTC()
+
+	err = c.store.Update(ctx, user)
+	if err != nil {
+		if errors.Is(err, database.ErrNotUnique) {
+			return User{}, fmt.Errorf("update failed: %w", ErrEmailTaken)
+		}
+		return User{}, fmt.Errorf("update failed: %w", err)
+	}
+	c.states.forget(user.UUID)
+
+	if user.Email != previous.Email {
+		c.record(ctx, auth.EventAccountChange, auth.OutcomeSuccess, user.UUID, "email changed")
+	}
+	if user.Branch != previous.Branch {
+		c.record(ctx, auth.EventAccountChange, auth.OutcomeSuccess, user.UUID,
+			fmt.Sprintf("branch changed from %q to %q", previous.Branch, user.Branch))
+	}
+	if uu.Permissions != nil {
+		c.record(ctx, auth.EventPermissionChange, auth.OutcomeSuccess, user.UUID,
+			fmt.Sprintf("permissions set to %q", []string(user.Permissions)))
+	}
+
+	return convertToUser(user), nil
+}
+
+// Disable prevents the user from authenticating. Tokens already issued to the user
+// are refused as well.
+func (c Core) Disable(ctx context.Context, uuid string) (User, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return User{}, err
+	}
+
+	now := time.Now().UTC()
+
+	if !user.DisabledAt.Valid {
+		user.DisabledAt = sql.NullTime{Time: now, Valid: true}
+		user.UpdatedAt = now
+
+		err = c.store.Update(ctx, user)
+		if err != nil {
+			return User{}, fmt.Errorf("disable failed: %w", err)
+		}
+		c.states.forget(user.UUID)
+
+		c.record(ctx, auth.EventAccountChange, auth.OutcomeSuccess, user.UUID, "user disabled")
+	}
+
+	return convertToUser(user), nil
+}
+
+// Enable reverts Disable.
+func (c Core) Enable(ctx context.Context, uuid string) (User, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return User{}, err
+	}
+
+	if user.DisabledAt.Valid {
+		user.DisabledAt = sql.NullTime{}
+		user.UpdatedAt = time.Now().UTC()
+
+		err = c.store.Update(ctx, user)
+		if err != nil {
+			return User{}, fmt.Errorf("enable failed: %w", err)
+		}
+		c.states.forget(user.UUID)
+
+		c.record(ctx, auth.EventAccountChange, auth.OutcomeSuccess, user.UUID, "user enabled")
+	}
+
+	return convertToUser(user), nil
+}
+
+func (c Core) GrantPermission(ctx context.Context, uuid string, permission string) (User, error) {
+	return c.changePermission(ctx, uuid, permission, c.store.GrantPermission, "granted")
+}
+
+func (c Core) RevokePermission(ctx context.Context, uuid string, permission string) (User, error) {
+	return c.changePermission(ctx, uuid, permission, c.store.RevokePermission, "revoked")
+}
+
+// AssignRole grants all permissions of the role to the user.
+func (c Core) AssignRole(ctx context.Context, uuid string, role string) (User, error) {
+	return c.changeRole(ctx, uuid, role, c.store.AssignRole, "assigned")
+}
+
+// UnassignRole takes the role back from the user.
+func (c Core) UnassignRole(ctx context.Context, uuid string, role string) (User, error) {
+	return c.changeRole(ctx, uuid, role, c.store.UnassignRole, "unassigned")
 }
 
 func (c Core) QueryByUUID(ctx context.Context, uuid string) (User, error) {
@@ -51,41 +489,147 @@ func (c Core) QueryByUUID(ctx context.Context, uuid string) (User, error) {
 		return User{}, fmt.Errorf("query failed: %w", err)
 	}
 
-	return User{
-		UUID:        user.UUID,
-		Email:       user.Email,
-		Permissions: user.Permissions,
-	}, nil
+	return convertToUser(user), nil
 }
 
+// Authenticate checks the credentials and returns claims of the user. It doesn't reveal
+// whether the account exists: unknown emails and wrong passwords result in the same
+// error and take the same time. Repeated failures lock out the account and the IP
+// of the client for a growing period of time. Users with two-factor authentication
+// enabled get challenge claims of auth.KindMFA kind, to be completed with CompleteMFA.
 func (c Core) Authenticate(ctx context.Context, email, pass string) (auth.Claims, error) {
-	err := checkEmail(email)
+	account := normalizeEmail(email)
+	ip := web.GetClientIP(ctx)
+
+	wait := c.accounts.Locked(account)
+	if w := c.ips.Locked(ip); w > wait {
+		wait = w
+	}
+	if wait > 0 {
+		metrics.AddLoginFailure("locked")
+		c.record(ctx, auth.EventLogin, auth.OutcomeFailure, "", fmt.Sprintf("locked out for %s", wait.Round(time.Second)))
+		return auth.Claims{}, &LockedError{RetryAfter: wait}
+	}
+
+	user, err := c.store.QueryByEmail(ctx, account)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return auth.Claims{}, fmt.Errorf("authenticate failed: %w", err)
+	}
+
+	hash := dummyHash
+	if err == nil {
+		hash = user.PasswordHash
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(pass)) != nil || err != nil {
+		c.fail(account, ip)
+		c.record(ctx, auth.EventLogin, auth.OutcomeFailure, user.UUID, "invalid credentials")
+		return auth.Claims{}, ErrNotAuthenticated
+	}
+
+	// With two-factor authentication failures are reset only once the second factor
+	// is confirmed, otherwise the password alone would allow guessing codes without limit.
+	if !user.TOTPEnabledAt.Valid {
+		c.accounts.Reset(account)
+	}
+
+	if !user.VerifiedAt.Valid {
+		c.record(ctx, auth.EventLogin, auth.OutcomeFailure, user.UUID, "email not verified")
+		return auth.Claims{}, ErrNotVerified
+	}
+
+	if user.DisabledAt.Valid {
+		c.record(ctx, auth.EventLogin, auth.OutcomeFailure, user.UUID, "user disabled")
+		return auth.Claims{}, ErrDisabled
+	}
+
+	// The password alone only earns the challenge.
+	if user.TOTPEnabledAt.Valid {
+		c.record(ctx, auth.EventLogin, auth.OutcomeSuccess, user.UUID, "second factor required")
+		return newChallengeClaims(user), nil
+	}
+
+	c.record(ctx, auth.EventLogin, auth.OutcomeSuccess, user.UUID, "password")
+
+	return newClaims(user), nil
+}
+
+// IssueRefreshToken starts a new family of refresh tokens for the user. Every
+// refresh rotates the token within the family.
+func (c Core) IssueRefreshToken(ctx context.Context, uuid string) (string, error) {
+	token, err := c.issueRefreshToken(ctx, uuid, uid.NewString())
 	if err != nil {
-		return auth.Claims{}, ErrInvalidEmail
+		return "", err
 	}
 
-	user, err := c.store.QueryByEmail(ctx, email)
+	c.record(ctx, auth.EventTokenIssue, auth.OutcomeSuccess, uuid, "access and refresh tokens issued")
+
+	return token, nil
+}
+
+// Refresh exchanges the refresh token for new access claims and a new refresh token.
+// Presenting the same refresh token twice revokes the whole family, as it means the
+// token has leaked.
+func (c Core) Refresh(ctx context.Context, refreshToken string) (auth.Claims, string, error) {
+	tkn, err := c.store.UseRefreshToken(ctx, hashToken(refreshToken))
 	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrReused):
+			if err := c.store.RevokeRefreshFamily(ctx, tkn.FamilyID); err != nil {
+				return auth.Claims{}, "", fmt.Errorf("revoking refresh tokens: %w", err)
+			}
+			c.record(ctx, auth.EventTokenRefresh, auth.OutcomeFailure, tkn.UserUUID, "refresh token reused, token family revoked")
+			return auth.Claims{}, "", ErrTokenReused
+		case errors.Is(err, database.ErrNotFound):
+			c.record(ctx, auth.EventTokenRefresh, auth.OutcomeFailure, "", "refresh token is not valid")
+			return auth.Claims{}, "", ErrInvalidToken
+		default:
+			return auth.Claims{}, "", fmt.Errorf("refresh failed: %w", err)
+		}
+	}
+
+	user, err := c.store.QueryByUUID(ctx, tkn.UserUUID)
+	if err != nil {
+		return auth.Claims{}, "", fmt.Errorf("refresh failed: %w", err)
+	}
+
+	if user.DisabledAt.Valid {
+		c.record(ctx, auth.EventTokenRefresh, auth.OutcomeFailure, user.UUID, "user disabled")
+		return auth.Claims{}, "", ErrDisabled
+	}
+
+	next, err := c.issueRefreshToken(ctx, user.UUID, tkn.FamilyID)
+	if err != nil {
+		return auth.Claims{}, "", err
+	}
+
+	c.record(ctx, auth.EventTokenRefresh, auth.OutcomeSuccess, user.UUID, "access and refresh tokens issued")
+
+	return newClaims(user), next, nil
+}
+
+// RevokeRefreshToken revokes the whole family of the refresh token owned by the user.
+func (c Core) RevokeRefreshToken(ctx context.Context, uuid string, refreshToken string) error {
+	tkn, err := c.store.UseRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil && !errors.Is(err, db.ErrReused) {
 		if errors.Is(err, database.ErrNotFound) {
-			return auth.Claims{}, ErrNotFound
+			return ErrInvalidToken
 		}
-		return auth.Claims{}, fmt.Errorf("authenticate failed: %w", err)
+		return fmt.Errorf("revoke failed: %w", err)
+	}
+
+	if tkn.UserUUID != uuid {
+		return ErrInvalidToken
 	}
 
-	err = checkPass(user, pass)
+	err = c.store.RevokeRefreshFamily(ctx, tkn.FamilyID)
 	if err != nil {
-		return auth.Claims{}, ErrNotAuthenticated
+		return fmt.Errorf("revoke failed: %w", err)
 	}
 
-	return auth.Claims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "bds-api",
-			Subject:   user.UUID,
-			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(1 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		},
-		Permissions: user.Permissions,
-	}, nil
+	c.record(ctx, auth.EventTokenRevoke, auth.OutcomeSuccess, uuid, "refresh token family revoked")
+
+	return nil
 }
 
 // private
@@ -106,7 +650,377 @@ func checkPass(user db.User, pass string) error {
 	return nil
 }
 
-// TODO.
-func checkEmail(_ string) error {
+// normalizeEmail returns the form of the email accounts are looked up and locked out by.
+// Addresses differing only by case belong to the same account.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func checkEmail(email string) error {
+	if email == "" {
+		return errors.New("can't be blank")
+	}
+
+	// ParseAddress accepts display names as well, like "John <john@example.com>".
+	// We want bare address only.
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return errors.New("is not a valid address")
+	}
+
+	at := strings.LastIndex(email, "@")
+	if !strings.Contains(email[at+1:], ".") {
+		return errors.New("is not a valid address")
+	}
+
+	return nil
+}
+
+// checkPermission ensures the permission has the resource.action form.
+func checkPermission(permission string) error {
+	if !auth.ValidPermission(permission) {
+		return FieldError{field: "permissions", err: fmt.Sprintf("%q is not a valid permission", permission)}
+	}
+	return nil
+}
+
+// checkPassword applies the password policy. Upper limit comes from bcrypt which
+// ignores everything past 72 bytes.
+func checkPassword(email, pass string) error {
+	const minLen, maxLen = 10, 72
+
+	switch {
+	case len(pass) < minLen:
+		return FieldError{field: "password", err: fmt.Sprintf("must be at least %d characters long", minLen)}
+	case len(pass) > maxLen:
+		return FieldError{field: "password", err: fmt.Sprintf("must be at most %d bytes long", maxLen)}
+	}
+
+	var letter, digit bool
+	for _, r := range pass {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return FieldError{field: "password", err: "must contain both letters and digits"}
+	}
+
+	local := strings.ToLower(email[:strings.LastIndex(email, "@")])
+	if len(local) >= 3 && strings.Contains(strings.ToLower(pass), local) {
+		return FieldError{field: "password", err: "can't contain the email"}
+	}
+
+	return nil
+}
+
+// fail records the failed login of the account from the IP.
+func (c Core) fail(account, ip string) {
+	metrics.AddLoginFailure("invalid_credentials")
+
+	if c.accounts.Fail(account) {
+		metrics.AddLoginLockout("account")
+	}
+	if ip != "" && c.ips.Fail(ip) {
+		metrics.AddLoginLockout("ip")
+	}
+}
+
+// record passes the security event to the recorder.
+func (c Core) record(ctx context.Context, typ, outcome, userUUID, details string) {
+	c.events.RecordEvent(ctx, auth.Event{Type: typ, Outcome: outcome, UserUUID: userUUID, Details: details})
+}
+
+func (c Core) queryForUpdate(ctx context.Context, uuid string) (db.User, error) {
+	err := checkUUID(uuid)
+	if err != nil {
+		return db.User{}, ErrInvalidUUID
+	}
+
+	user, err := c.store.QueryByUUID(ctx, uuid)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return db.User{}, ErrNotFound
+		}
+		return db.User{}, fmt.Errorf("query failed: %w", err)
+	}
+
+	return user, nil
+}
+
+// changePermission applies the change and records it as the event, e.g. permission "books.write" granted.
+func (c Core) changePermission(ctx context.Context, uuid, permission string, change func(context.Context, string, string) error, action string) (User, error) {
+	err := checkUUID(uuid)
+	if err != nil {
+		return User{}, ErrInvalidUUID
+	}
+
+	err = checkPermission(permission)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = change(ctx, uuid, permission)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return User{}, ErrNotFound
+		}
+		return User{}, fmt.Errorf("change permission failed: %w", err)
+	}
+	c.states.forget(uuid)
+
+	c.record(ctx, auth.EventPermissionChange, auth.OutcomeSuccess, uuid, fmt.Sprintf("permission %q %s", permission, action))
+
+	return c.QueryByUUID(ctx, uuid)
+}
+
+// changeRole applies the change and records it as the event, e.g. role "librarian" assigned.
+func (c Core) changeRole(ctx context.Context, uuid, role string, change func(context.Context, string, string) error, action string) (User, error) {
+	user, err := c.queryForUpdate(ctx, uuid)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = change(ctx, user.UUID, role)
+	if err != nil {
+		if errors.Is(err, database.ErrNotFound) {
+			return User{}, ErrRoleNotFound
+		}
+		return User{}, fmt.Errorf("change role failed: %w", err)
+	}
+	c.states.forget(user.UUID)
+
+	c.record(ctx, auth.EventPermissionChange, auth.OutcomeSuccess, user.UUID, fmt.Sprintf("role %q %s", role, action))
+
+	return c.QueryByUUID(ctx, uuid)
+}
+
+func (c Core) updatePassword(ctx context.Context, user db.User, pass string) error {
+	err := checkPassword(user.Email, pass)
+	if err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("generating password hash: %w", err)
+	}
+
+	err = c.store.UpdatePassword(ctx, user.UUID, hash, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("update password failed: %w", err)
+	}
+	c.states.forget(user.UUID)
+
 	return nil
 }
+
+func (c Core) issueRefreshToken(ctx context.Context, uuid, familyID string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	err = c.store.CreateRefreshToken(ctx, db.RefreshToken{
+		TokenHash: hashToken(token),
+		FamilyID:  familyID,
+		UserUUID:  uuid,
+		ExpiresAt: now.Add(refreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("storing refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// issueToken stores hash of a new random token and returns the token itself.
+// Raw token is never persisted.
+func (c Core) issueToken(ctx context.Context, userUUID, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+
+	err = c.store.CreateToken(ctx, db.Token{
+		TokenHash: hashToken(token),
+		UserUUID:  userUUID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("storing token: %w", err)
+	}
+
+	return token, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newClaims builds claims of the access token. Every token gets unique ID,
+// so it can be revoked before it expires.
+func newClaims(user db.User) auth.Claims {
+	now := time.Now().UTC()
+
+	return auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uid.NewString(),
+			Issuer:    "bds-api",
+			Subject:   user.UUID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		Permissions: user.Permissions,
+		Roles:       user.Roles,
+		Attributes:  attributes(user),
+	}
+}
+
+// attributes returns the attributes of the user checked by resource policies.
+func attributes(user db.User) map[string]string {
+	if user.Branch == "" {
+		return nil
+	}
+	return map[string]string{"branch": user.Branch}
+}
+
+// permissions returns permissions granted to the user directly and through roles.
+func permissions(user db.User) []string {
+	seen := make(map[string]bool, len(user.Permissions)+len(user.RolePermissions))
+	result := make([]string, 0, len(seen))
+
+	for _, list := range [][]string{user.Permissions, user.RolePermissions} {
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+
+	return result
+}
+
+func convertToUser(user db.User) User {
+	return User{
+		UUID:          user.UUID,
+		Email:         user.Email,
+		Permissions:   user.Permissions,
+		Roles:         user.Roles,
+		VerifiedAt:    user.VerifiedAt.Time,
+		DisabledAt:    user.DisabledAt.Time,
+		TOTPEnabledAt: user.TOTPEnabledAt.Time,
+		DisplayName:   user.DisplayName,
+		Locale:        user.Locale,
+		Notifications: user.Notifications,
+		Branch:        user.Branch,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+	}
+}
+
+// state is the part of the user checked by VerifyClaims.
+type state struct {
+	exists            bool
+	disabled          bool
+	passwordChangedAt time.Time
+	roles             []string
+	permissions       []string
+	attributes        map[string]string
+	loaded            time.Time
+}
+
+// state returns the cached state of the user, loading it when it's older than
+// the sync interval. Users which don't exist are cached as well, so tokens of
+// deleted users don't hit the DB either.
+func (c Core) state(ctx context.Context, uuid string) (state, error) {
+	now := time.Now()
+
+	if st, ok := c.states.get(uuid, now); ok {
+		return st, nil
+	}
+
+	st := state{loaded: now}
+
+	user, err := c.store.QueryByUUID(ctx, uuid)
+	switch {
+	case err == nil:
+		st.exists = true
+		st.disabled = user.DisabledAt.Valid
+		st.passwordChangedAt = user.PasswordChangedAt.Time
+		st.roles = user.Roles
+		st.permissions = permissions(user)
+		st.attributes = attributes(user)
+	case !errors.Is(err, database.ErrNotFound):
+		return state{}, fmt.Errorf("verify claims failed: %w", err)
+	}
+
+	c.states.put(uuid, st)
+
+	return st, nil
+}
+
+type stateCache struct {
+	mu       sync.Mutex
+	interval time.Duration
+	purged   time.Time
+	entries  map[string]state
+}
+
+func (c *stateCache) get(uuid string, now time.Time) (state, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st, ok := c.entries[uuid]
+	if !ok || now.Sub(st.loaded) >= c.interval {
+		return state{}, false
+	}
+	return st, true
+}
+
+// put stores the state, dropping stale entries once per interval, so users who
+// stopped sending requests don't stay in memory.
+func (c *stateCache) put(uuid string, st state) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if st.loaded.Sub(c.purged) >= c.interval {
+		for k, v := range c.entries {
+			if st.loaded.Sub(v.loaded) >= c.interval {
+				delete(c.entries, k)
+			}
+		}
+		c.purged = st.loaded
+	}
+
+	c.entries[uuid] = st
+}
+
+// forget drops the state of the user changed by this instance, so the change
+// applies to the next request instead of after the sync interval.
+func (c *stateCache) forget(uuid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, uuid)
+}
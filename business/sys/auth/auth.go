@@ -1,15 +1,43 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func ValidateToken(tkn string) (Claims, error) {
+// Config holds the settings of token signing.
+// KeysDir contains PEM files named after the key id, e.g. 54bb2165.pem.
+// ActiveKID selects the private key used for signing, the other keys are used
+// only for verification. It may be left empty when there is a single private key.
+type Config struct {
+	KeysDir   string
+	ActiveKID string
+}
+
+// Auth signs and validates tokens. Every token carries the kid header pointing to
+// the key which signed it, so keys can be rotated without invalidating issued tokens.
+type Auth struct {
+	keys *KeyStore
+}
+
+// New constructs Auth with the keys loaded from the configured folder.
+func New(cfg Config) (*Auth, error) {
+	keys, err := LoadKeys(cfg.KeysDir, cfg.ActiveKID)
+	if err != nil {
+		return nil, err
+	}
+	return &Auth{keys: keys}, nil
+}
+
+func (a *Auth) ValidateToken(tkn string) (Claims, error) {
 	var claims Claims
 
-	token, err := jwt.ParseWithClaims(tkn, &claims, keyFunc)
+	token, err := jwt.ParseWithClaims(tkn, &claims, a.keyFunc)
 	if err != nil {
 		return Claims{}, fmt.Errorf("parsing token failed: %w", err)
 	}
@@ -21,10 +49,18 @@ func ValidateToken(tkn string) (Claims, error) {
 	return claims, nil
 }
 
-func GenerateToken(claims Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+func (a *Auth) GenerateToken(claims Claims) (string, error) {
+	kid, key := a.keys.Active()
+
+	method, err := signingMethod(key.Public())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
 
-	tkn, err := token.SignedString([]byte(secret))
+	tkn, err := token.SignedString(key)
 	if err != nil {
 		return "", fmt.Errorf("signing token failed: %w", err)
 	}
@@ -32,14 +68,43 @@ func GenerateToken(claims Claims) (string, error) {
 	return tkn, nil
 }
 
+// JWKS returns the public keys used to verify tokens.
+func (a *Auth) JWKS() JWKS {
+	return a.keys.JWKS()
+}
+
 // private
 
-// TODO: extract it to ENV or somewhere else.
-const secret = "secret"
+func (a *Auth) keyFunc(token *jwt.Token) (any, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errors.New("kid header is missing")
+	}
+
+	key, ok := a.keys.PublicKey(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown kid: %s", kid)
+	}
 
-func keyFunc(token *jwt.Token) (any, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	// The algorithm is dictated by the key, never by the token.
+	method, err := signingMethod(key)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != method.Alg() {
 		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
-	return []byte(secret), nil
+
+	return key, nil
+}
+
+func signingMethod(key crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %T", key)
+	}
 }
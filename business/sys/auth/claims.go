@@ -10,10 +10,40 @@ import (
 
 var ErrClaimsNotFound = errors.New("claims not found")
 var ErrActionNotAllowed = errors.New("action not allowed")
+var ErrTokenRevoked = errors.New("token has been revoked")
+var ErrKeyNotValid = errors.New("api key is not valid")
+
+// Kinds of claims. Claims of access tokens have no kind.
+const (
+	KindAccess = ""
+	KindAPIKey = "api_key"
+	// KindMFA claims only prove the password. They are exchanged for access
+	// claims once the second factor is confirmed.
+	KindMFA = "mfa_required"
+)
 
+// Claims are carried by every token. Roles are expanded into permissions by
+// a Verifier when the token is checked, so changes of roles apply without logging in again.
 type Claims struct {
 	jwt.RegisteredClaims
 	Permissions []string
+	Roles       []string `json:",omitempty"`
+	Kind        string   `json:",omitempty"`
+	// Attributes are facts about the user checked by resource policies, like the branch.
+	// They are refreshed by a Verifier, the same way as permissions.
+	Attributes map[string]string `json:",omitempty"`
+}
+
+// Verifier checks claims of a correctly signed token against the state kept
+// outside the token. It returns ErrTokenRevoked when token must not be accepted.
+type Verifier interface {
+	VerifyClaims(ctx context.Context, claims Claims) (Claims, error)
+}
+
+// KeyAuthenticator turns API keys into claims. It returns ErrKeyNotValid when the key
+// doesn't exist, expired, has been revoked or is used from the IP which isn't allowed.
+type KeyAuthenticator interface {
+	AuthenticateKey(ctx context.Context, key string, ip string) (Claims, error)
 }
 
 type ctxKey int
@@ -31,38 +61,75 @@ func GetClaims(ctx context.Context) (Claims, error) {
 	return Claims{}, ErrClaimsNotFound
 }
 
-// TODO: Make permission mechanism a bit more flexible
-
+// Authorize checks whether claims hold the permission. Permissions granted to
+// claims may use wildcards: '*' grants everything and 'books.*' grants every
+// action of books resource, including nested ones like 'books.files.upload'.
 func Authorize(claims Claims, permission string) error {
-	resource, action := decodePermission(permission)
-
 	for _, cp := range claims.Permissions {
-		cr, ca := decodePermission(cp)
+		if match(cp, permission) {
+			return nil
+		}
+	}
+	return ErrActionNotAllowed
+}
 
-		if resource == cr && action == ca {
+// AuthorizeAny checks whether claims hold at least one of the permissions.
+func AuthorizeAny(claims Claims, permissions ...string) error {
+	for _, p := range permissions {
+		if Authorize(claims, p) == nil {
 			return nil
 		}
 	}
 	return ErrActionNotAllowed
 }
 
-// private
+// AuthorizeAll checks whether claims hold every one of the permissions.
+func AuthorizeAll(claims Claims, permissions ...string) error {
+	for _, p := range permissions {
+		if err := Authorize(claims, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidPermission reports whether the permission has the resource.action form.
+// Segments may be replaced with '*' wildcard and '*' alone is valid as well.
+func ValidPermission(permission string) bool {
+	if permission == "*" {
+		return true
+	}
 
-func decodePermission(permission string) (string, string) {
 	parts := strings.Split(permission, ".")
+	if len(parts) < 2 {
+		return false
+	}
 
-	// Example: 'flights.protected'
-	if len(parts) >= 2 {
-		resource := parts[0]
-		action := parts[1]
-		return resource, action
+	for _, p := range parts {
+		if p == "" || (p != "*" && strings.ContainsAny(p, "* \t\r\n")) {
+			return false
+		}
 	}
 
-	// Example: 'flights'
-	if len(parts) == 1 {
-		resource := parts[0]
-		return resource, ""
+	return true
+}
+
+// private
+
+// match reports whether the granted permission covers the requested one.
+func match(granted, requested string) bool {
+	gs := strings.Split(granted, ".")
+	rs := strings.Split(requested, ".")
+
+	for i, g := range gs {
+		// Trailing wildcard matches any number of remaining segments, but at least one.
+		if g == "*" && i == len(gs)-1 {
+			return len(rs) > i
+		}
+		if i >= len(rs) || (g != "*" && g != rs[i]) {
+			return false
+		}
 	}
 
-	return "", ""
+	return len(gs) == len(rs)
 }
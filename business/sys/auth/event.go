@@ -0,0 +1,41 @@
+package auth
+
+import "context"
+
+// Types of security events.
+const (
+	EventLogin            = "login"
+	EventMFA              = "mfa"
+	EventAuthentication   = "authentication"
+	EventTokenIssue       = "token.issue"
+	EventTokenRefresh     = "token.refresh"
+	EventTokenRevoke      = "token.revoke"
+	EventPermissionChange = "permission.change"
+	EventPasswordChange   = "password.change"
+	EventPasswordReset    = "password.reset"
+	EventAccountChange    = "account.change"
+)
+
+// Outcomes of security events.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event describes the security relevant action, like the login or the permission change.
+// UserUUID is the user affected by the action, it's empty when the user is unknown.
+// Anonymous events are recorded without details of the request and the actor,
+// e.g. the erasure of personal data.
+type Event struct {
+	Type      string
+	Outcome   string
+	UserUUID  string
+	Details   string
+	Anonymous bool
+}
+
+// EventRecorder keeps the record of security events. Details of the request, like
+// the client IP, are taken from the context. Recording never fails the action itself.
+type EventRecorder interface {
+	RecordEvent(ctx context.Context, e Event)
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeyStore holds private keys able to sign tokens and public keys able to verify them.
+// Retired keys can be kept as public keys only, so tokens they signed stay valid
+// until they expire.
+type KeyStore struct {
+	active  string
+	private map[string]crypto.Signer
+	public  map[string]crypto.PublicKey
+}
+
+// LoadKeys reads all PEM files from the folder. Name of the file without the extension
+// is used as the key id. Supported blocks are PKCS#8 and PKCS#1 private keys and
+// PKIX public keys, either RSA or Ed25519.
+func LoadKeys(dir string, activeKID string) (*KeyStore, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("listing keys: %w", err)
+	}
+
+	ks := KeyStore{
+		private: make(map[string]crypto.Signer),
+		public:  make(map[string]crypto.PublicKey),
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading key: %w", err)
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+		err = ks.Add(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("loading key %s: %w", path, err)
+		}
+	}
+
+	err = ks.Activate(activeKID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ks, nil
+}
+
+// Add parses the PEM encoded key and stores it under the kid.
+func (ks *KeyStore) Add(kid string, data []byte) error {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return fmt.Errorf("unsupported PEM block: %s", block.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		ks.private[kid] = k
+		ks.public[kid] = k.Public()
+	case ed25519.PrivateKey:
+		ks.private[kid] = k
+		ks.public[kid] = k.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		ks.public[kid] = k
+	default:
+		return fmt.Errorf("unsupported key type: %T", key)
+	}
+
+	return nil
+}
+
+// Activate selects the private key used for signing. Empty kid is accepted only
+// when there is exactly one private key.
+func (ks *KeyStore) Activate(kid string) error {
+	if kid == "" {
+		if len(ks.private) != 1 {
+			return fmt.Errorf("active kid must be set, %d private keys found", len(ks.private))
+		}
+		for k := range ks.private {
+			kid = k
+		}
+	}
+
+	if _, ok := ks.private[kid]; !ok {
+		return fmt.Errorf("private key %q not found", kid)
+	}
+
+	ks.active = kid
+	return nil
+}
+
+// Active returns the key used for signing.
+func (ks *KeyStore) Active() (string, crypto.Signer) {
+	return ks.active, ks.private[ks.active]
+}
+
+// PublicKey returns the key verifying tokens signed with the kid.
+func (ks *KeyStore) PublicKey(kid string) (crypto.PublicKey, bool) {
+	key, ok := ks.public[kid]
+	return key, ok
+}
+
+// JWK represents a public key as described by RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519 keys.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS represents a set of public keys.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns all public keys ordered by kid.
+func (ks *KeyStore) JWKS() JWKS {
+	kids := make([]string, 0, len(ks.public))
+	for kid := range ks.public {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKS{Keys: make([]JWK, 0, len(kids))}
+
+	for _, kid := range kids {
+		switch k := ks.public[kid].(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: kid,
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: kid,
+				Use: "sig",
+				Alg: "EdDSA",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(k),
+			})
+		}
+	}
+
+	return set
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrResourceNotFound = errors.New("resource not found")
+
+// Subject is the party performing the action.
+type Subject struct {
+	ID          string
+	Permissions []string
+	// Attributes hold facts about the subject used by rules, like the branch.
+	Attributes map[string]string
+}
+
+// NewSubject builds the subject out of the claims.
+func NewSubject(claims Claims) Subject {
+	return Subject{
+		ID:          claims.Subject,
+		Permissions: claims.Permissions,
+		Attributes:  claims.Attributes,
+	}
+}
+
+// Resource is the object the action is performed on.
+type Resource struct {
+	Type  string
+	ID    string
+	Owner string
+	// Attributes hold facts about the resource used by rules, like the branch.
+	Attributes map[string]string
+	// Value is the loaded resource itself, so it doesn't have to be loaded again.
+	Value any
+}
+
+// Rule decides whether the subject may perform the action on the resource.
+type Rule func(s Subject, action string, r Resource) bool
+
+// Policy maps actions to rules. The action is allowed when any of its rules allows it.
+// Actions without rules are never allowed.
+type Policy map[string][]Rule
+
+// Evaluate returns ErrActionNotAllowed unless the policy allows the subject to perform
+// the action on the resource.
+func (p Policy) Evaluate(s Subject, action string, r Resource) error {
+	for _, rule := range p[action] {
+		if rule(s, action, r) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s on %s %s: %w", action, r.Type, r.ID, ErrActionNotAllowed)
+}
+
+// Owner allows subjects owning the resource.
+func Owner() Rule {
+	return func(s Subject, _ string, r Resource) bool {
+		return s.ID != "" && s.ID == r.Owner
+	}
+}
+
+// Permission allows subjects holding the permission, wildcards included.
+func Permission(permission string) Rule {
+	return func(s Subject, _ string, _ Resource) bool {
+		return Authorize(Claims{Permissions: s.Permissions}, permission) == nil
+	}
+}
+
+// SameAttribute allows subjects with the attribute equal to the one of the resource.
+// Missing attributes never match.
+func SameAttribute(name string) Rule {
+	return func(s Subject, _ string, r Resource) bool {
+		v := s.Attributes[name]
+		return v != "" && v == r.Attributes[name]
+	}
+}
+
+// SameBranch allows subjects from the same branch as the resource.
+func SameBranch() Rule {
+	return SameAttribute("branch")
+}
+
+// OwnerOrAdmin allows owners of the resource and subjects holding the admin permission.
+func OwnerOrAdmin(adminPermission string) Rule {
+	return AnyOf(Owner(), Permission(adminPermission))
+}
+
+// AnyOf allows the action when any of the rules allows it.
+func AnyOf(rules ...Rule) Rule {
+	return func(s Subject, action string, r Resource) bool {
+		for _, rule := range rules {
+			if rule(s, action, r) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// AllOf allows the action when all the rules allow it.
+func AllOf(rules ...Rule) Rule {
+	return func(s Subject, action string, r Resource) bool {
+		for _, rule := range rules {
+			if !rule(s, action, r) {
+				return false
+			}
+		}
+		return len(rules) > 0
+	}
+}
+
+type resourceKey struct{}
+
+// SetResource stores the resource the request acts on.
+func SetResource(ctx context.Context, r Resource) context.Context {
+	return context.WithValue(ctx, resourceKey{}, r)
+}
+
+// GetResource returns the resource stored by SetResource.
+func GetResource(ctx context.Context) (Resource, error) {
+	if v, ok := ctx.Value(resourceKey{}).(Resource); ok {
+		return v, nil
+	}
+	return Resource{}, ErrResourceNotFound
+}
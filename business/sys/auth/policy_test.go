@@ -0,0 +1,106 @@
+package auth_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+)
+
+func TestPolicy(t *testing.T) {
+	policy := auth.Policy{
+		"read":   {auth.AnyOf(auth.OwnerOrAdmin("shelves.admin"), auth.SameBranch())},
+		"update": {auth.OwnerOrAdmin("shelves.admin")},
+		"close":  {auth.AllOf(auth.SameBranch(), auth.Permission("shelves.close"))},
+	}
+
+	shelf := auth.Resource{
+		Type:       "shelf",
+		ID:         "1",
+		Owner:      "alice",
+		Attributes: map[string]string{"branch": "north"},
+	}
+
+	alice := auth.Subject{ID: "alice"}
+	admin := auth.Subject{ID: "root", Permissions: []string{"*"}}
+	colleague := auth.Subject{ID: "bob", Permissions: []string{"shelves.close"}, Attributes: map[string]string{"branch": "north"}}
+	stranger := auth.Subject{ID: "eve", Permissions: []string{"shelves.close"}, Attributes: map[string]string{"branch": "south"}}
+	anonymous := auth.Subject{}
+
+	tests := []struct {
+		name    string
+		subject auth.Subject
+		action  string
+		allowed bool
+	}{
+		{"owner reads", alice, "read", true},
+		{"owner updates", alice, "update", true},
+		{"owner without permission closes", alice, "close", false},
+		{"admin updates", admin, "update", true},
+		{"admin from no branch closes", admin, "close", false},
+		{"colleague reads", colleague, "read", true},
+		{"colleague updates", colleague, "update", false},
+		{"colleague closes", colleague, "close", true},
+		{"stranger reads", stranger, "read", false},
+		{"stranger closes", stranger, "close", false},
+		{"anonymous reads resource without owner", anonymous, "read", false},
+		{"unknown action", admin, "delete", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Evaluate(tt.subject, tt.action, shelf)
+			if tt.allowed && err != nil {
+				t.Fatalf("expected action to be allowed, got %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, auth.ErrActionNotAllowed) {
+				t.Fatalf("expected %v, got %v", auth.ErrActionNotAllowed, err)
+			}
+		})
+	}
+}
+
+func TestOwnerRequiresSubject(t *testing.T) {
+	policy := auth.Policy{"read": {auth.Owner()}}
+
+	// Resources without owner must not be readable by subjects without ID.
+	err := policy.Evaluate(auth.Subject{}, "read", auth.Resource{Type: "shelf"})
+	if !errors.Is(err, auth.ErrActionNotAllowed) {
+		t.Fatalf("expected %v, got %v", auth.ErrActionNotAllowed, err)
+	}
+}
+
+func TestNewSubject(t *testing.T) {
+	policy := auth.Policy{"read": {auth.SameBranch()}}
+	shelf := auth.Resource{Type: "shelf", ID: "1", Attributes: map[string]string{"branch": "north"}}
+
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		allowed    bool
+	}{
+		{"same branch", map[string]string{"branch": "north"}, true},
+		{"other branch", map[string]string{"branch": "south"}, false},
+		{"no branch", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := auth.Claims{Permissions: []string{"shelves.read"}, Attributes: tt.attributes}
+			claims.Subject = "bob"
+
+			s := auth.NewSubject(claims)
+			if s.ID != "bob" {
+				t.Fatalf("expected subject bob, got %q", s.ID)
+			}
+
+			err := policy.Evaluate(s, "read", shelf)
+			if tt.allowed && err != nil {
+				t.Fatalf("expected action to be allowed, got %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, auth.ErrActionNotAllowed) {
+				t.Fatalf("expected %v, got %v", auth.ErrActionNotAllowed, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+// Package blob provides the abstraction over storage of binary objects, e.g. uploaded files.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("blob key is not valid")
+)
+
+// Store represents a storage of binary objects addressed by keys.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// FS stores objects as files within the root directory.
+type FS struct {
+	root string
+}
+
+// NewFS constructs FS store, creating the root directory if necessary.
+func NewFS(root string) (FS, error) {
+	err := os.MkdirAll(root, 0o750)
+	if err != nil {
+		return FS{}, fmt.Errorf("creating blob root dir failed: %w", err)
+	}
+	return FS{root: root}, nil
+}
+
+func (fs FS) Put(_ context.Context, key string, r io.Reader) error {
+	p, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0o750)
+	if err != nil {
+		return err
+	}
+
+	// Write to the temporary file first, so readers never see partial objects.
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (fs FS) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := fs.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(p) // nolint:gosec
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (fs FS) Delete(_ context.Context, key string) error {
+	p, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// private
+
+// path maps the key onto the file path, refusing keys escaping the root directory.
+func (fs FS) path(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(fs.root, filepath.FromSlash(key)), nil
+}
@@ -30,4 +30,226 @@ CREATE TABLE books (
 
    PRIMARY KEY (id),
    CONSTRAINT books_unique UNIQUE (isbn, title)
-);
\ No newline at end of file
+);
+-- Version: 1.4
+-- Description: Add cover url to books
+ALTER TABLE books ADD COLUMN cover_url TEXT;
+
+-- Version: 1.5
+-- Description: Create table book_files
+CREATE TABLE book_files (
+   id         UUID,
+   book_id    INT NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+   kind       TEXT NOT NULL,
+   media_type TEXT NOT NULL,
+   size       BIGINT NOT NULL,
+   sha256     TEXT NOT NULL,
+   blob_key   TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (id)
+);
+
+-- Version: 1.6
+-- Description: Add email verification of users
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+UPDATE users SET verified_at = date_created;
+
+CREATE TABLE user_tokens (
+   token_hash TEXT,
+   user_uuid  UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+   purpose    TEXT NOT NULL,
+   expires_at TIMESTAMP NOT NULL,
+   used_at    TIMESTAMP,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (token_hash)
+);
+
+-- Version: 1.7
+-- Description: Create table mail_outbox
+CREATE TABLE mail_outbox (
+   id         UUID,
+   recipient  TEXT NOT NULL,
+   subject    TEXT NOT NULL,
+   body       TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+   sent_at    TIMESTAMP,
+
+   PRIMARY KEY (id)
+);
+
+-- Version: 1.8
+-- Description: Track password changes of users
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMP;
+
+-- Version: 1.9
+-- Description: Allow users to be disabled
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP;
+
+-- Version: 2.1
+-- Description: Create tables roles and user_roles
+CREATE TABLE roles (
+   name         TEXT,
+   description  TEXT NOT NULL DEFAULT '',
+   permissions  TEXT[] NOT NULL DEFAULT '{}',
+   date_created TIMESTAMP NOT NULL DEFAULT now(),
+   date_updated TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (name)
+);
+
+CREATE TABLE user_roles (
+   user_uuid    UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+   role_name    TEXT NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+   date_created TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (user_uuid, role_name)
+);
+
+INSERT INTO roles (name, description, permissions) VALUES
+   ('librarian', 'Manages the catalogue', '{books.*,labels.*,user.profile}'),
+   ('admin', 'Has access to everything', '{*}');
+
+-- Version: 2.2
+-- Description: Create tables refresh_tokens and revoked_tokens
+CREATE TABLE refresh_tokens (
+   token_hash TEXT,
+   family_id  UUID NOT NULL,
+   user_uuid  UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+   expires_at TIMESTAMP NOT NULL,
+   used_at    TIMESTAMP,
+   revoked_at TIMESTAMP,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (token_hash)
+);
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+CREATE TABLE revoked_tokens (
+   jti        TEXT,
+   expires_at TIMESTAMP NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (jti)
+);
+
+-- Version: 2.3
+-- Description: Create table api_keys
+CREATE TABLE api_keys (
+   id            UUID,
+   name          TEXT NOT NULL,
+   prefix        TEXT NOT NULL UNIQUE,
+   key_hash      TEXT NOT NULL,
+   permissions   TEXT[] NOT NULL DEFAULT '{}',
+   ip_allowlist  TEXT[] NOT NULL DEFAULT '{}',
+   expires_at    TIMESTAMP,
+   created_by    UUID REFERENCES users (uuid) ON DELETE SET NULL,
+   created_at    TIMESTAMP NOT NULL DEFAULT now(),
+   revoked_at    TIMESTAMP,
+   last_used_at  TIMESTAMP,
+   request_count BIGINT NOT NULL DEFAULT 0,
+
+   PRIMARY KEY (id)
+);
+
+-- Version: 2.4
+-- Description: Add TOTP two-factor authentication of users
+ALTER TABLE users
+   ADD COLUMN totp_secret       TEXT,
+   ADD COLUMN totp_enabled_at   TIMESTAMP,
+   ADD COLUMN totp_last_counter BIGINT;
+
+CREATE TABLE recovery_codes (
+   code_hash  TEXT,
+   user_uuid  UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+   used_at    TIMESTAMP,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (code_hash)
+);
+
+CREATE INDEX recovery_codes_user_uuid_idx ON recovery_codes (user_uuid);
+
+-- Version: 2.5
+-- Description: Create table user_identities
+CREATE TABLE user_identities (
+   issuer     TEXT,
+   subject    TEXT,
+   user_uuid  UUID NOT NULL REFERENCES users (uuid) ON DELETE CASCADE,
+   email      TEXT NOT NULL,
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (issuer, subject)
+);
+
+-- Version: 2.6
+-- Description: Add profile and preferences of users, track erasure
+ALTER TABLE users
+   ADD COLUMN display_name  TEXT NOT NULL DEFAULT '',
+   ADD COLUMN locale        TEXT NOT NULL DEFAULT 'en',
+   ADD COLUMN notifications TEXT[] NOT NULL DEFAULT '{security}',
+   ADD COLUMN erased_at     TIMESTAMP;
+
+-- Version: 2.7
+-- Description: Create append-only table security_events
+CREATE TABLE security_events (
+   id         UUID,
+   type       TEXT NOT NULL,
+   outcome    TEXT NOT NULL,
+   user_uuid  UUID,
+   actor      TEXT,
+   ip         TEXT NOT NULL DEFAULT '',
+   user_agent TEXT NOT NULL DEFAULT '',
+   trace_id   TEXT NOT NULL DEFAULT '',
+   details    TEXT NOT NULL DEFAULT '',
+   created_at TIMESTAMP NOT NULL DEFAULT now(),
+
+   PRIMARY KEY (id)
+);
+
+CREATE INDEX security_events_created_at_idx ON security_events (created_at);
+CREATE INDEX security_events_user_uuid_idx ON security_events (user_uuid);
+
+-- Events can't be changed once recorded. They are only deleted by the retention.
+CREATE FUNCTION security_events_append_only() RETURNS trigger AS $$
+   BEGIN RAISE EXCEPTION 'security_events is append-only'; END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER security_events_append_only
+   BEFORE UPDATE ON security_events
+   FOR EACH ROW EXECUTE FUNCTION security_events_append_only();
+
+-- Version: 2.8
+-- Description: Allow anonymising security events of erased users
+-- The only change allowed is clearing the client IP, the user agent and the actor.
+CREATE OR REPLACE FUNCTION security_events_append_only() RETURNS trigger AS $$
+   BEGIN
+      IF NEW.ip = '' AND NEW.user_agent = '' AND (NEW.actor IS NULL OR NEW.actor = OLD.actor)
+         AND (NEW.id, NEW.type, NEW.outcome, NEW.user_uuid, NEW.trace_id, NEW.details, NEW.created_at)
+         IS NOT DISTINCT FROM (OLD.id, OLD.type, OLD.outcome, OLD.user_uuid, OLD.trace_id, OLD.details, OLD.created_at)
+      THEN
+         RETURN NEW;
+      END IF;
+      RAISE EXCEPTION 'security_events is append-only';
+   END;
+$$ LANGUAGE plpgsql;
+
+-- Version: 2.9
+-- Description: Track permissions granted by identity providers
+-- They are revoked once the claims of the identity no longer map to them.
+ALTER TABLE user_identities
+   ADD COLUMN permissions TEXT[] NOT NULL DEFAULT '{}';
+
+-- Version: 3.1
+-- Description: Add branch of users
+-- It's the attribute of the user checked by resource policies, e.g. auth.SameBranch.
+ALTER TABLE users
+   ADD COLUMN branch TEXT NOT NULL DEFAULT '';
+
+-- Version: 3.2
+-- Description: Match emails of users ignoring case
+-- Lookups by email ignore case, so addresses differing only by case can't be taken twice.
+CREATE UNIQUE INDEX users_email_lower_idx
+   ON users (lower(email));
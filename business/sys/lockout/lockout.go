@@ -0,0 +1,118 @@
+// Package lockout tracks failed attempts and locks out keys, like accounts or IPs,
+// which fail too often. Lock duration grows exponentially with every further failure.
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy describes when and for how long keys are locked.
+// Reaching Threshold failures locks the key for BaseDelay, which is doubled with every
+// further failure, but never gets longer than MaxDelay.
+// Failures are forgotten after Window without any failure.
+type Policy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Limiter keeps failures in memory. It's safe for concurrent use.
+type Limiter struct {
+	policy  Policy
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]*entry
+	swept   time.Time
+}
+
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// New constructs a Limiter applying the policy.
+func New(policy Policy) *Limiter {
+	return &Limiter{
+		policy:  policy,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Locked returns how long the key stays locked, zero when it isn't locked.
+func (l *Limiter) Locked(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	left := e.lockedUntil.Sub(l.now())
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Fail records the failed attempt. It reports whether the key got locked.
+func (l *Limiter) Fail(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.lastFailure) > l.policy.Window {
+		e = &entry{}
+		l.entries[key] = e
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	over := e.failures - l.policy.Threshold
+	if over < 0 {
+		return false
+	}
+
+	delay := l.policy.MaxDelay
+	if over < 32 {
+		if d := l.policy.BaseDelay << over; d > 0 && d < delay {
+			delay = d
+		}
+	}
+	e.lockedUntil = now.Add(delay)
+
+	return true
+}
+
+// Reset forgets failures of the key, e.g. after successful attempt.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+}
+
+// private
+
+// sweep removes entries which are neither locked nor within the window.
+// It runs at most once per window, so memory doesn't grow without bounds.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.policy.Window {
+		return
+	}
+	l.swept = now
+
+	for key, e := range l.entries {
+		if now.Sub(e.lastFailure) > l.policy.Window && now.After(e.lockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package lockout
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var policy = Policy{Threshold: 3, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute, Window: 15 * time.Minute}
+
+// clock is advanced by tests instead of sleeping.
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newLimiter(p Policy) (*Limiter, *clock) {
+	c := clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(p)
+	l.now = c.Now
+	return l, &c
+}
+
+func TestBackoff(t *testing.T) {
+	l, _ := newLimiter(policy)
+
+	tests := []struct {
+		failure int
+		locked  bool
+		delay   time.Duration
+	}{
+		{failure: 1},
+		{failure: 2},
+		{failure: 3, locked: true, delay: 30 * time.Second},
+		{failure: 4, locked: true, delay: time.Minute},
+		{failure: 5, locked: true, delay: 2 * time.Minute},
+		{failure: 6, locked: true, delay: 4 * time.Minute},
+		{failure: 7, locked: true, delay: 5 * time.Minute},
+		{failure: 40, locked: true, delay: 5 * time.Minute},
+	}
+
+	failures := 0
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("failure %d", tt.failure), func(t *testing.T) {
+			var locked bool
+			for failures < tt.failure {
+				locked = l.Fail("alice")
+				failures++
+			}
+
+			if locked != tt.locked {
+				t.Fatalf("expected locked %t, got %t", tt.locked, locked)
+			}
+			if got := l.Locked("alice"); got != tt.delay {
+				t.Fatalf("expected delay %s, got %s", tt.delay, got)
+			}
+		})
+	}
+}
+
+func TestLockExpires(t *testing.T) {
+	l, c := newLimiter(policy)
+
+	for i := 0; i < policy.Threshold; i++ {
+		l.Fail("alice")
+	}
+
+	c.Advance(20 * time.Second)
+	if got := l.Locked("alice"); got != 10*time.Second {
+		t.Fatalf("expected %s left, got %s", 10*time.Second, got)
+	}
+
+	c.Advance(10 * time.Second)
+	if got := l.Locked("alice"); got != 0 {
+		t.Fatalf("expected lock to expire, got %s left", got)
+	}
+
+	// Failures within the window keep counting, so the next one doubles the delay.
+	if !l.Fail("alice") {
+		t.Fatal("expected failure after the lock to lock again")
+	}
+	if got := l.Locked("alice"); got != time.Minute {
+		t.Fatalf("expected delay %s, got %s", time.Minute, got)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	l, c := newLimiter(policy)
+
+	for i := 0; i < policy.Threshold-1; i++ {
+		l.Fail("alice")
+	}
+
+	c.Advance(policy.Window + time.Second)
+
+	if l.Fail("alice") {
+		t.Fatal("expected failures older than the window to be forgotten")
+	}
+}
+
+func TestReset(t *testing.T) {
+	l, _ := newLimiter(policy)
+
+	for i := 0; i < policy.Threshold; i++ {
+		l.Fail("alice")
+	}
+	if l.Locked("alice") == 0 {
+		t.Fatal("expected key to be locked")
+	}
+
+	l.Reset("alice")
+
+	if got := l.Locked("alice"); got != 0 {
+		t.Fatalf("expected reset key not to be locked, got %s", got)
+	}
+	for i := 0; i < policy.Threshold-1; i++ {
+		if l.Fail("alice") {
+			t.Fatalf("expected failures to count from zero after reset, locked after %d", i+1)
+		}
+	}
+}
+
+func TestKeys(t *testing.T) {
+	l, _ := newLimiter(policy)
+
+	for i := 0; i < policy.Threshold; i++ {
+		l.Fail("alice")
+	}
+
+	if l.Locked("alice") == 0 {
+		t.Fatal("expected alice to be locked")
+	}
+	if got := l.Locked("bob"); got != 0 {
+		t.Fatalf("expected bob not to be locked, got %s", got)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, c := newLimiter(policy)
+
+	l.Fail("alice")
+	c.Advance(policy.Window + time.Second)
+	l.Fail("bob")
+
+	if _, ok := l.entries["alice"]; ok {
+		t.Fatal("expected stale entry to be swept")
+	}
+	if _, ok := l.entries["bob"]; !ok {
+		t.Fatal("expected recent entry to be kept")
+	}
+}
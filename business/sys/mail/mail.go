@@ -0,0 +1,73 @@
+// Package mail provides the abstraction over delivering emails to users.
+package mail
+
+import (
+	"context"
+	"time"
+
+	uid "github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/sys/database"
+	"go.uber.org/zap"
+)
+
+// Message represents a single email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages to their recipients.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Outbox is the default Mailer. It doesn't need any SMTP server, messages are stored
+// in the mail_outbox table and logged, so they can be delivered by a separate process.
+type Outbox struct {
+	db     *database.ExtContext
+	logger *zap.SugaredLogger
+}
+
+// NewOutbox constructs the Outbox mailer.
+func NewOutbox(db *sqlx.DB, logger *zap.SugaredLogger) Outbox {
+	return Outbox{db: database.NewExtContext(db).WithLogger(logger), logger: logger}
+}
+
+func (o Outbox) Send(ctx context.Context, msg Message) error {
+	const q = `
+		insert into mail_outbox
+			(id, recipient, subject, body, created_at)
+		values
+			(:id, :recipient, :subject, :body, :created_at)
+	`
+
+	data := struct {
+		ID        string    `db:"id"`
+		Recipient string    `db:"recipient"`
+		Subject   string    `db:"subject"`
+		Body      string    `db:"body"`
+		CreatedAt time.Time `db:"created_at"`
+	}{
+		ID:        uid.NewString(),
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		Body:      msg.Body,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	ext := o.db.
+		WithErrorMapper(database.NewErrorMapper()).
+		WithMetric(database.NewMetric("mail_outbox", "Send"))
+
+	_, err := sqlx.NamedExecContext(ctx, ext, q, data)
+	if err != nil {
+		return err
+	}
+
+	o.logger.Infow("mail queued", "trace_id", web.GetTraceID(ctx), "id", data.ID, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+
+	return nil
+}
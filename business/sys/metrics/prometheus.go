@@ -21,10 +21,33 @@ var httpHist = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 	Buckets: prometheus.DefBuckets,
 }, []string{"handler", "method", "code"})
 
+var loginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "login_failures_total",
+	Help: "The number of failed logins.",
+}, []string{"reason"})
+
+var loginLockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "login_lockouts_total",
+	Help: "The number of lockouts caused by failed logins.",
+}, []string{"scope"})
+
 func init() {
 	// TODO: change the registration of metrics and handle err properly
 	_ = prometheus.Register(dbHist)
 	_ = prometheus.Register(httpHist)
+	_ = prometheus.Register(loginFailures)
+	_ = prometheus.Register(loginLockouts)
+}
+
+// AddLoginFailure increments number of failed logins by 1.
+// Reason is either invalid_credentials or locked.
+func AddLoginFailure(reason string) {
+	loginFailures.WithLabelValues(reason).Inc()
+}
+
+// AddLoginLockout increments number of lockouts by 1. Scope is either account or ip.
+func AddLoginLockout(scope string) {
+	loginLockouts.WithLabelValues(scope).Inc()
 }
 
 type Histogram struct {
@@ -0,0 +1,133 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+)
+
+// Rule grants permissions to users whose ID token has the claim with the value.
+// Claims holding a list match when any of the elements equals the value.
+type Rule struct {
+	Claim       string
+	Value       string
+	Permissions []string
+}
+
+// Mapping is the set of rules applied to every ID token.
+type Mapping []Rule
+
+// ParseRule parses rule in the claim=value:permission,permission form,
+// e.g. groups=librarians:books.*,labels.*
+func ParseRule(s string) (Rule, error) {
+	cond, perms, ok := strings.Cut(s, ":")
+	if !ok {
+		return Rule{}, fmt.Errorf("rule %q has no permissions", s)
+	}
+
+	claim, value, ok := strings.Cut(cond, "=")
+	if !ok || strings.TrimSpace(claim) == "" {
+		return Rule{}, fmt.Errorf("rule %q has no claim", s)
+	}
+
+	r := Rule{
+		Claim: strings.TrimSpace(claim),
+		Value: strings.TrimSpace(value),
+	}
+	for _, p := range strings.Split(perms, ",") {
+		p = strings.TrimSpace(p)
+		if !auth.ValidPermission(p) {
+			return Rule{}, fmt.Errorf("rule %q has invalid permission %q", s, p)
+		}
+		r.Permissions = append(r.Permissions, p)
+	}
+	return r, nil
+}
+
+// ParseMapping parses every rule with ParseRule.
+func ParseMapping(rules []string) (Mapping, error) {
+	m := make(Mapping, 0, len(rules))
+	for _, s := range rules {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		r, err := ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		m = append(m, r)
+	}
+	return m, nil
+}
+
+// Permissions returns permissions of all the rules matching the claims, without duplicates.
+func (m Mapping) Permissions(claims map[string]any) []string {
+	var result []string
+	seen := make(map[string]bool)
+
+	for _, r := range m {
+		if !r.matches(claims[r.Claim]) {
+			continue
+		}
+		for _, p := range r.Permissions {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+
+	return result
+}
+
+// Reconcile compares permissions mapped from the claims of the current login with the
+// ones the user holds, of which granted were granted by the provider at former logins.
+// Mapped permissions the user lacks are to be granted. Permissions granted by the provider
+// which are no longer mapped are to be revoked, e.g. when the user has left the group.
+// Permissions the user got otherwise are never revoked. Tracked are the permissions
+// granted by the provider once the changes are made, to be passed as granted next time.
+func Reconcile(held []string, granted []string, mapped []string) (grant []string, revoke []string, tracked []string) {
+	for _, p := range mapped {
+		switch {
+		case !contains(held, p):
+			grant = append(grant, p)
+			tracked = append(tracked, p)
+		case contains(granted, p):
+			tracked = append(tracked, p)
+		}
+	}
+	for _, p := range granted {
+		if !contains(mapped, p) && contains(held, p) {
+			revoke = append(revoke, p)
+		}
+	}
+	return grant, revoke, tracked
+}
+
+// private
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) matches(claim any) bool {
+	switch v := claim.(type) {
+	case string:
+		return v == r.Value
+	case bool:
+		return fmt.Sprint(v) == r.Value
+	case []any:
+		for _, e := range v {
+			if s, ok := e.(string); ok && s == r.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
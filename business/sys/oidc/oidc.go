@@ -0,0 +1,329 @@
+// Package oidc implements the OpenID Connect authorization code flow with PKCE
+// against a single identity provider. The provider is configured by its issuer
+// URL, the rest is read from its discovery document.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidState = errors.New("oidc login state is not valid")
+	ErrInvalidToken = errors.New("oidc id token is not valid")
+)
+
+// loginTTL limits the time user has to sign in at the provider.
+const loginTTL = 10 * time.Minute
+
+// Config holds the settings of the identity provider and the client registered there.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes requested in addition to openid.
+	Scopes []string
+	// Mapping grants permissions based on claims of the ID token.
+	Mapping Mapping
+	// Client is used for all calls to the provider, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// IDToken holds the verified claims of the ID token.
+type IDToken struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Permissions are granted by the configured mapping.
+	Permissions []string
+	// Claims holds all the claims, including the ones above.
+	Claims map[string]any
+}
+
+// Provider runs the login flow. Logins are started with Begin and finished with Finish,
+// pending logins are kept in memory, so both calls have to reach the same instance.
+type Provider struct {
+	cfg  Config
+	keys *keySet
+
+	discoveryMu sync.Mutex
+	discovery   *discovery
+
+	mu      sync.Mutex
+	pending map[string]login
+}
+
+// New constructs the Provider. The discovery document is fetched on first use,
+// so the service starts even when the provider is not reachable.
+func New(cfg Config) *Provider {
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+
+	return &Provider{
+		cfg:     cfg,
+		keys:    &keySet{client: cfg.Client},
+		pending: make(map[string]login),
+	}
+}
+
+// Begin starts a new login. It returns the URL of the provider the user has to be
+// redirected to and the state, which comes back with the callback.
+func (p *Provider) Begin(ctx context.Context) (authURL string, state string, err error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	var l login
+	for _, v := range []*string{&state, &l.nonce, &l.verifier} {
+		*v, err = random()
+		if err != nil {
+			return "", "", err
+		}
+	}
+	l.expires = time.Now().Add(loginTTL)
+
+	p.mu.Lock()
+	p.sweep()
+	p.pending[state] = l
+	p.mu.Unlock()
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", l.nonce)
+	q.Set("code_challenge", Challenge(l.verifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return d.AuthorizationEndpoint + sep + q.Encode(), state, nil
+}
+
+// Finish completes the login started with the state. It exchanges the code
+// for the ID token and verifies it. Each state can be finished only once.
+func (p *Provider) Finish(ctx context.Context, state string, code string) (IDToken, error) {
+	p.mu.Lock()
+	l, ok := p.pending[state]
+	delete(p.pending, state)
+	p.mu.Unlock()
+
+	if !ok || time.Now().After(l.expires) {
+		return IDToken{}, ErrInvalidState
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	raw, err := p.exchange(ctx, d, code, l.verifier)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	return p.Verify(ctx, raw, l.nonce)
+}
+
+// Verify checks the signature of the ID token against the provider's keys,
+// as well as its issuer, audience, expiry and nonce.
+func (p *Provider) Verify(ctx context.Context, raw string, nonce string) (IDToken, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return IDToken{}, err
+	}
+
+	claims, err := p.keys.verify(ctx, d.JWKSURI, raw)
+	if err != nil {
+		return IDToken{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	now := time.Now()
+	switch {
+	case !claims.VerifyIssuer(p.cfg.Issuer, true):
+		return IDToken{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	case !claims.VerifyAudience(p.cfg.ClientID, true):
+		return IDToken{}, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	case !claims.VerifyExpiresAt(now.Add(-leeway).Unix(), true):
+		return IDToken{}, fmt.Errorf("%w: token is expired", ErrInvalidToken)
+	case claims["nonce"] != nonce:
+		return IDToken{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidToken)
+	}
+
+	t := IDToken{
+		Claims:      claims,
+		Permissions: p.cfg.Mapping.Permissions(claims),
+	}
+	t.Issuer, _ = claims["iss"].(string)
+	t.Subject, _ = claims["sub"].(string)
+	t.Email, _ = claims["email"].(string)
+
+	// Some providers send the flag as string.
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		t.EmailVerified = v
+	case string:
+		t.EmailVerified = v == "true"
+	}
+
+	if t.Subject == "" {
+		return IDToken{}, fmt.Errorf("%w: subject is missing", ErrInvalidToken)
+	}
+
+	return t, nil
+}
+
+// Challenge returns the S256 PKCE code challenge of the verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// private
+
+// leeway tolerates clock drift between the service and the provider.
+const leeway = time.Minute
+
+type login struct {
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// discover fetches the discovery document once. Failures are not cached,
+// so the next call tries again.
+func (p *Provider) discover(ctx context.Context) (*discovery, error) {
+	p.discoveryMu.Lock()
+	defer p.discoveryMu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	err := getJSON(ctx, p.cfg.Client, p.cfg.Issuer+"/.well-known/openid-configuration", &d)
+	if err != nil {
+		return nil, fmt.Errorf("fetching discovery document: %w", err)
+	}
+
+	if strings.TrimSuffix(d.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("discovery document issuer %q doesn't match %q", d.Issuer, p.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document is incomplete")
+	}
+
+	p.discovery = &d
+	return p.discovery, nil
+}
+
+func (p *Provider) exchange(ctx context.Context, d *discovery, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", verifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.cfg.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("exchanging code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("%w: provider refused the code: %s %s", ErrInvalidState, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("%w: token response has no id_token", ErrInvalidToken)
+	}
+
+	return body.IDToken, nil
+}
+
+// sweep drops expired logins. It must be called with the lock held.
+func (p *Provider) sweep() {
+	now := time.Now()
+	for state, l := range p.pending {
+		if now.After(l.expires) {
+			delete(p.pending, state)
+		}
+	}
+}
+
+func random() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("generating random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(dest)
+}
@@ -0,0 +1,366 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/tchorzewski1991/bds/business/sys/oidc"
+	"github.com/tchorzewski1991/bds/business/sys/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost:3000/v1/auth/oidc/callback"
+
+func newProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	t.Helper()
+
+	srv := oidctest.NewServer("bds", "secret")
+	t.Cleanup(srv.Close)
+
+	mapping, err := oidc.ParseMapping([]string{
+		"groups=librarians:books.*,labels.*",
+		"groups=admins:users.admin",
+	})
+	if err != nil {
+		t.Fatalf("parsing mapping: %v", err)
+	}
+
+	p := oidc.New(oidc.Config{
+		Issuer:       srv.URL,
+		ClientID:     srv.ClientID,
+		ClientSecret: srv.ClientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"email", "profile"},
+		Mapping:      mapping,
+		Client:       srv.Client(),
+	})
+
+	return p, srv
+}
+
+// signIn starts the login and follows the provider's redirect back to the callback,
+// returning the code and the state from its query.
+func signIn(t *testing.T, p *oidc.Provider, srv *oidctest.Server) (code string, state string) {
+	t.Helper()
+
+	authURL, state, err := p.Begin(context.Background())
+	if err != nil {
+		t.Fatalf("beginning login: %v", err)
+	}
+
+	client := srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("calling authorize endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status: got %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing redirect: %v", err)
+	}
+	if got := loc.Query().Get("state"); got != state {
+		t.Fatalf("state: got %q, want %q", got, state)
+	}
+
+	return loc.Query().Get("code"), state
+}
+
+func TestLogin(t *testing.T) {
+	p, srv := newProvider(t)
+	srv.SetClaims(map[string]any{
+		"sub":            "42",
+		"email":          "staff@example.com",
+		"email_verified": true,
+		"groups":         []string{"staff", "librarians"},
+	})
+
+	code, state := signIn(t, p, srv)
+
+	tkn, err := p.Finish(context.Background(), state, code)
+	if err != nil {
+		t.Fatalf("finishing login: %v", err)
+	}
+
+	if tkn.Subject != "42" || tkn.Email != "staff@example.com" || !tkn.EmailVerified {
+		t.Errorf("unexpected token: %+v", tkn)
+	}
+	if tkn.Issuer != srv.URL {
+		t.Errorf("issuer: got %q, want %q", tkn.Issuer, srv.URL)
+	}
+
+	want := []string{"books.*", "labels.*"}
+	if len(tkn.Permissions) != len(want) || tkn.Permissions[0] != want[0] || tkn.Permissions[1] != want[1] {
+		t.Errorf("permissions: got %v, want %v", tkn.Permissions, want)
+	}
+
+	// The state can be used only once.
+	_, err = p.Finish(context.Background(), state, code)
+	if !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("finishing twice: got %v, want %v", err, oidc.ErrInvalidState)
+	}
+}
+
+func TestLoginUnknownState(t *testing.T) {
+	p, srv := newProvider(t)
+	srv.SetClaims(map[string]any{"sub": "42"})
+
+	code, _ := signIn(t, p, srv)
+
+	_, err := p.Finish(context.Background(), "forged", code)
+	if !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("got %v, want %v", err, oidc.ErrInvalidState)
+	}
+}
+
+func TestLoginCodeFromOtherLogin(t *testing.T) {
+	p, srv := newProvider(t)
+	srv.SetClaims(map[string]any{"sub": "42"})
+
+	code, _ := signIn(t, p, srv)
+	_, state := signIn(t, p, srv)
+
+	// The code was issued for a different PKCE challenge.
+	_, err := p.Finish(context.Background(), state, code)
+	if !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("got %v, want %v", err, oidc.ErrInvalidState)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	p, srv := newProvider(t)
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":   srv.URL,
+			"sub":   "42",
+			"aud":   srv.ClientID,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token func() string
+		ok    bool
+	}{
+		{
+			name:  "valid",
+			token: func() string { return srv.Sign(valid()) },
+			ok:    true,
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				c := valid()
+				c["iss"] = "https://evil.example.com"
+				return srv.Sign(c)
+			},
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				c := valid()
+				c["aud"] = "other-client"
+				return srv.Sign(c)
+			},
+		},
+		{
+			name: "expired",
+			token: func() string {
+				c := valid()
+				c["exp"] = time.Now().Add(-time.Hour).Unix()
+				return srv.Sign(c)
+			},
+		},
+		{
+			name: "no expiry",
+			token: func() string {
+				c := valid()
+				delete(c, "exp")
+				return srv.Sign(c)
+			},
+		},
+		{
+			name: "wrong nonce",
+			token: func() string {
+				c := valid()
+				c["nonce"] = "other"
+				return srv.Sign(c)
+			},
+		},
+		{
+			name: "signed by other key",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, valid())
+				token.Header["kid"] = oidctest.KID
+				tkn, _ := token.SignedString(other)
+				return tkn
+			},
+		},
+		{
+			name: "signed with client secret",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, valid())
+				token.Header["kid"] = oidctest.KID
+				tkn, _ := token.SignedString([]byte(srv.ClientSecret))
+				return tkn
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Verify(context.Background(), tt.token(), "nonce")
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, oidc.ErrInvalidToken) {
+				t.Fatalf("got %v, want %v", err, oidc.ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestMapping(t *testing.T) {
+	m, err := oidc.ParseMapping([]string{
+		"groups=staff:books.read",
+		"department=it:users.admin,books.read",
+		"admin=true:*",
+	})
+	if err != nil {
+		t.Fatalf("parsing mapping: %v", err)
+	}
+
+	got := m.Permissions(map[string]any{
+		"groups":     []any{"staff"},
+		"department": "it",
+		"admin":      false,
+	})
+
+	want := []string{"books.read", "users.admin"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, rule := range []string{"groups=staff", "=staff:books.read", "groups=staff:"} {
+		if _, err := oidc.ParseRule(rule); err == nil {
+			t.Errorf("rule %q: expected error", rule)
+		}
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	m, err := oidc.ParseMapping([]string{
+		"groups=librarians:books.*,labels.*",
+		"groups=admins:users.admin",
+	})
+	if err != nil {
+		t.Fatalf("parsing mapping: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		held    []string
+		granted []string
+		grant   []string
+		revoke  []string
+		tracked []string
+	}{
+		{
+			name:    "first login",
+			claims:  map[string]any{"groups": []any{"librarians"}},
+			held:    []string{"user.profile"},
+			grant:   []string{"books.*", "labels.*"},
+			tracked: []string{"books.*", "labels.*"},
+		},
+		{
+			name:    "nothing changed",
+			claims:  map[string]any{"groups": []any{"librarians"}},
+			held:    []string{"books.*", "labels.*", "user.profile"},
+			granted: []string{"books.*", "labels.*"},
+			tracked: []string{"books.*", "labels.*"},
+		},
+		{
+			name:    "user left the group",
+			claims:  map[string]any{"groups": []any{"admins"}},
+			held:    []string{"books.*", "labels.*", "user.profile"},
+			granted: []string{"books.*", "labels.*"},
+			grant:   []string{"users.admin"},
+			revoke:  []string{"books.*", "labels.*"},
+			tracked: []string{"users.admin"},
+		},
+		{
+			name:    "permission dropped from the mapping",
+			claims:  map[string]any{"groups": []any{"librarians"}},
+			held:    []string{"books.*", "books.read", "labels.*"},
+			granted: []string{"books.*", "books.read", "labels.*"},
+			revoke:  []string{"books.read"},
+			tracked: []string{"books.*", "labels.*"},
+		},
+		{
+			name:    "user left every group",
+			claims:  map[string]any{},
+			held:    []string{"users.admin"},
+			granted: []string{"users.admin"},
+			revoke:  []string{"users.admin"},
+		},
+		{
+			name:   "permissions granted otherwise are kept",
+			claims: map[string]any{"groups": []any{"librarians"}},
+			held:   []string{"books.*", "labels.*", "users.admin"},
+		},
+		{
+			name:    "permissions revoked otherwise are granted again",
+			claims:  map[string]any{"groups": []any{"admins"}},
+			held:    []string{"user.profile"},
+			granted: []string{"users.admin"},
+			grant:   []string{"users.admin"},
+			tracked: []string{"users.admin"},
+		},
+		{
+			name:    "permissions revoked otherwise are not revoked again",
+			claims:  map[string]any{},
+			held:    []string{"user.profile"},
+			granted: []string{"users.admin"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			grant, revoke, tracked := oidc.Reconcile(tt.held, tt.granted, m.Permissions(tt.claims))
+
+			if !reflect.DeepEqual(grant, tt.grant) {
+				t.Errorf("expected to grant %v, got %v", tt.grant, grant)
+			}
+			if !reflect.DeepEqual(revoke, tt.revoke) {
+				t.Errorf("expected to revoke %v, got %v", tt.revoke, revoke)
+			}
+			if !reflect.DeepEqual(tracked, tt.tracked) {
+				t.Errorf("expected to track %v, got %v", tt.tracked, tracked)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package mid
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -11,7 +12,20 @@ import (
 	v1 "github.com/tchorzewski1991/bds/business/web/v1"
 )
 
-func Authenticate() web.Middleware {
+// AuthConfig holds everything needed to authenticate requests.
+// Verifiers check claims of bearer tokens, so tokens can be revoked before they expire.
+// APIKeys is optional, when it's nil API keys are not accepted.
+// Events is optional, when it's set rejected credentials are recorded as security events.
+type AuthConfig struct {
+	Auth      *auth.Auth
+	Verifiers []auth.Verifier
+	APIKeys   auth.KeyAuthenticator
+	Events    auth.EventRecorder
+}
+
+// Authenticate validates the bearer token or the API key and stores its claims in the context.
+// API key is accepted from the X-API-Key header or as the Authorization: ApiKey KEY header.
+func Authenticate(cfg AuthConfig) web.Middleware {
 
 	// m is the middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
@@ -19,21 +33,26 @@ func Authenticate() web.Middleware {
 		// h is the handler that will be attached in the middleware chain.
 		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
 
-			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				err := errors.New("authorization header is not set")
-				return v1.NewRequestError(err, http.StatusUnauthorized)
+			scheme, credentials, err := credentials(r)
+			if err != nil {
+				return reject(ctx, cfg, scheme, v1.NewRequestError(err, http.StatusUnauthorized))
 			}
 
-			parts := strings.Split(authHeader, " ")
-			if len(parts) < 2 && strings.ToLower(parts[0]) != "bearer" {
-				err := errors.New("authorization header has invalid format. Expected: Bearer TOKEN")
-				return v1.NewRequestError(err, http.StatusUnauthorized)
-			}
+			var claims auth.Claims
 
-			claims, err := auth.ValidateToken(parts[1])
+			switch {
+			case scheme == "bearer":
+				claims, err = bearer(ctx, cfg, credentials)
+			case scheme == "apikey" && cfg.APIKeys != nil:
+				claims, err = cfg.APIKeys.AuthenticateKey(ctx, credentials, web.ClientIP(r))
+				if errors.Is(err, auth.ErrKeyNotValid) {
+					err = v1.NewRequestError(auth.ErrKeyNotValid, http.StatusUnauthorized)
+				}
+			default:
+				err = v1.NewRequestError(fmt.Errorf("authorization scheme %q is not supported", scheme), http.StatusUnauthorized)
+			}
 			if err != nil {
-				return v1.NewRequestError(err, http.StatusUnauthorized)
+				return reject(ctx, cfg, scheme, err)
 			}
 
 			ctx = auth.SetClaims(ctx, claims)
@@ -46,3 +65,64 @@ func Authenticate() web.Middleware {
 
 	return m
 }
+
+// private
+
+// reject records the failed authentication and returns the error unchanged.
+func reject(ctx context.Context, cfg AuthConfig, scheme string, err error) error {
+	if cfg.Events != nil {
+		details := err.Error()
+		if scheme != "" {
+			details = scheme + ": " + details
+		}
+		cfg.Events.RecordEvent(ctx, auth.Event{
+			Type:    auth.EventAuthentication,
+			Outcome: auth.OutcomeFailure,
+			Details: details,
+		})
+	}
+	return err
+}
+
+// credentials returns the lowercase authorization scheme together with the credentials.
+func credentials(r *http.Request) (string, string, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return "apikey", key, nil
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return "", "", errors.New("authorization header is not set")
+	}
+
+	parts := strings.Fields(authHeader)
+	if len(parts) != 2 {
+		return "", "", errors.New("authorization header has invalid format. Expected: Bearer TOKEN or ApiKey KEY")
+	}
+
+	return strings.ToLower(parts[0]), parts[1], nil
+}
+
+func bearer(ctx context.Context, cfg AuthConfig, tkn string) (auth.Claims, error) {
+	claims, err := cfg.Auth.ValidateToken(tkn)
+	if err != nil {
+		return auth.Claims{}, v1.NewRequestError(err, http.StatusUnauthorized)
+	}
+
+	// Only access tokens can be used as the bearer token.
+	if claims.Kind != auth.KindAccess {
+		return auth.Claims{}, v1.NewRequestError(errors.New("token is not an access token"), http.StatusUnauthorized)
+	}
+
+	for _, v := range cfg.Verifiers {
+		claims, err = v.VerifyClaims(ctx, claims)
+		if err != nil {
+			if errors.Is(err, auth.ErrTokenRevoked) {
+				return auth.Claims{}, v1.NewRequestError(err, http.StatusUnauthorized)
+			}
+			return auth.Claims{}, fmt.Errorf("verifying claims: %w", err)
+		}
+	}
+
+	return claims, nil
+}
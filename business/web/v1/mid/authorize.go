@@ -10,7 +10,30 @@ import (
 	v1 "github.com/tchorzewski1991/bds/business/web/v1"
 )
 
+// Authorize requires the claims to hold the permission.
 func Authorize(permission string) web.Middleware {
+	return authorize(func(claims auth.Claims) error {
+		return auth.Authorize(claims, permission)
+	})
+}
+
+// AuthorizeAny requires the claims to hold at least one of the permissions.
+func AuthorizeAny(permissions ...string) web.Middleware {
+	return authorize(func(claims auth.Claims) error {
+		return auth.AuthorizeAny(claims, permissions...)
+	})
+}
+
+// AuthorizeAll requires the claims to hold every one of the permissions.
+func AuthorizeAll(permissions ...string) web.Middleware {
+	return authorize(func(claims auth.Claims) error {
+		return auth.AuthorizeAll(claims, permissions...)
+	})
+}
+
+// private
+
+func authorize(check func(claims auth.Claims) error) web.Middleware {
 
 	// m is the middleware function to be executed.
 	m := func(handler web.Handler) web.Handler {
@@ -24,7 +47,7 @@ func Authorize(permission string) web.Middleware {
 				return v1.NewRequestError(err, http.StatusForbidden)
 			}
 
-			err = auth.Authorize(claims, permission)
+			err = check(claims)
 			if err != nil {
 				err = fmt.Errorf("you are not authorized to perform this action, permissions missing")
 				return v1.NewRequestError(err, http.StatusForbidden)
@@ -0,0 +1,58 @@
+package mid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+)
+
+// ResourceLoader loads the resource the request acts on. Errors are returned
+// to the client as they are, so loaders should report missing resources
+// with NotFound.
+type ResourceLoader func(ctx context.Context, r *http.Request) (auth.Resource, error)
+
+// NotFound is the error reporting the missing resource of the type.
+func NotFound(resourceType string) error {
+	return v1.NewRequestError(fmt.Errorf("%s not found", resourceType), http.StatusNotFound)
+}
+
+// Enforce loads the resource and requires the policy to allow the action on it.
+// Denied actions are reported with NotFound, the same as missing resources, so
+// clients can't learn which resources exist by acting on ones they may not access.
+// The resource is available to the handler through auth.GetResource.
+func Enforce(policy auth.Policy, action string, load ResourceLoader) web.Middleware {
+
+	// m is the middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// h is the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+
+			claims, err := auth.GetClaims(ctx)
+			if err != nil {
+				err = fmt.Errorf("you are not authorized to perform this action, no claims")
+				return v1.NewRequestError(err, http.StatusForbidden)
+			}
+
+			resource, err := load(ctx, r)
+			if err != nil {
+				return err
+			}
+
+			err = policy.Evaluate(auth.NewSubject(claims), action, resource)
+			if err != nil {
+				return NotFound(resource.Type)
+			}
+
+			return handler(auth.SetResource(ctx, resource), w, r)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,89 @@
+package mid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/tchorzewski1991/bds/base/web"
+	"github.com/tchorzewski1991/bds/business/sys/auth"
+	"github.com/tchorzewski1991/bds/business/web/v1/mid"
+	"go.uber.org/zap"
+)
+
+func TestEnforce(t *testing.T) {
+	policy := auth.Policy{"read": {auth.OwnerOrAdmin("shelves.admin")}}
+
+	load := func(ctx context.Context, r *http.Request) (auth.Resource, error) {
+		id := httptreemux.ContextParams(r.Context())["id"]
+		if id != "1" {
+			return auth.Resource{}, mid.NotFound("shelf")
+		}
+		return auth.Resource{Type: "shelf", ID: id, Owner: "alice"}, nil
+	}
+
+	// authenticate stands for mid.Authenticate, the subject comes from the header.
+	authenticate := func(handler web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			if sub := r.Header.Get("X-Subject"); sub != "" {
+				claims := auth.Claims{Permissions: r.Header.Values("X-Permission")}
+				claims.Subject = sub
+				ctx = auth.SetClaims(ctx, claims)
+			}
+			return handler(ctx, w, r)
+		}
+	}
+
+	log := zap.NewNop().Sugar()
+	app := web.NewApp(make(chan os.Signal, 1), log, mid.Errors(log))
+	app.Handle(http.MethodGet, "v1", "/shelves/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		resource, err := auth.GetResource(ctx)
+		if err != nil {
+			return err
+		}
+		return web.Response(ctx, w, http.StatusOK, map[string]string{"id": resource.ID})
+	}, authenticate, mid.Enforce(policy, "read", load))
+
+	notFound := `{"error":"shelf not found","status":404}`
+
+	tests := []struct {
+		name        string
+		path        string
+		subject     string
+		permissions []string
+		status      int
+		body        string
+	}{
+		{"owner", "/v1/shelves/1", "alice", nil, http.StatusOK, `{"id":"1"}`},
+		{"admin", "/v1/shelves/1", "root", []string{"shelves.admin"}, http.StatusOK, `{"id":"1"}`},
+		{"denied", "/v1/shelves/1", "eve", nil, http.StatusNotFound, notFound},
+		{"missing", "/v1/shelves/2", "eve", nil, http.StatusNotFound, notFound},
+		{"missing for admin", "/v1/shelves/2", "root", []string{"shelves.admin"}, http.StatusNotFound, notFound},
+		{"no claims", "/v1/shelves/1", "", nil, http.StatusForbidden, `{"error":"you are not authorized to perform this action, no claims","status":403}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.subject != "" {
+				r.Header.Set("X-Subject", tt.subject)
+			}
+			for _, p := range tt.permissions {
+				r.Header.Add("X-Permission", p)
+			}
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("expected body %s, got %s", tt.body, got)
+			}
+		})
+	}
+}
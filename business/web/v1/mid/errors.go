@@ -32,6 +32,33 @@ func Errors(logger *zap.SugaredLogger) web.Middleware {
 
 				// Build out error response.
 				switch {
+				case web.IsFieldErrors(err):
+					// Validation failures are reported the same way for every endpoint,
+					// no matter which status the handler has chosen.
+					er = v1.ErrorResponse{
+						Err:    "data validation error",
+						Status: http.StatusUnprocessableEntity,
+					}
+				case web.IsDecodeError(err):
+					// Bodies which can't be decoded carry their own status: 400, 413 or 415.
+					dErr := web.GetDecodeError(err)
+					er = v1.ErrorResponse{
+						Err:    dErr.Error(),
+						Status: dErr.Status,
+					}
+				case web.IsRouteError(err):
+					rErr := web.GetRouteError(err)
+					er = v1.ErrorResponse{
+						Err:    rErr.Error(),
+						Status: rErr.Status,
+					}
+				case web.IsNotAcceptableError(err):
+					// The error itself is sent in the default format, see web.Response.
+					nErr := web.GetNotAcceptableError(err)
+					er = v1.ErrorResponse{
+						Err:    nErr.Error(),
+						Status: http.StatusNotAcceptable,
+					}
 				case v1.IsFieldError(err):
 					fErr := v1.GetFieldError(err)
 					er = v1.ErrorResponse{
@@ -51,10 +78,14 @@ func Errors(logger *zap.SugaredLogger) web.Middleware {
 					}
 				}
 
-				// Send error response back to the client.
-				err = web.Response(ctx, w, er.Status, er)
-				if err != nil {
-					return err
+				// Problems with particular fields are not reported for internal errors.
+				if er.Status != http.StatusInternalServerError {
+					er.Details = v1.GetDetails(err)
+				}
+
+				// Send error response back to the client, in the format it has asked for.
+				if respErr := web.Response(ctx, w, er.Status, er); respErr != nil {
+					return respErr
 				}
 
 				// If we receive the shutdown error we need to return it back to
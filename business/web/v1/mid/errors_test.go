@@ -0,0 +1,114 @@
+package mid_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+	"github.com/tchorzewski1991/bds/business/web/v1/mid"
+	"go.uber.org/zap"
+)
+
+// TestErrors pins the bodies of v1 error responses byte for byte, as v1 clients
+// depend on them. Changes to the errors of the v2 API must not alter any of these.
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		err    error
+		status int
+		body   string
+	}{
+		{
+			name:   "field errors",
+			method: http.MethodGet,
+			path:   "/v1/fail",
+			err:    web.FieldErrors{"title": "title is required", "isbn": "isbn must be 13 characters long"},
+			status: http.StatusUnprocessableEntity,
+			body:   `{"error":"data validation error","status":422,"details":{"isbn":"isbn must be 13 characters long","title":"title is required"}}`,
+		},
+		{
+			name:   "field error",
+			method: http.MethodGet,
+			path:   "/v1/fail",
+			err:    v1.NewFieldError("email", "is taken"),
+			status: http.StatusUnprocessableEntity,
+			body:   `{"error":"email is taken","status":422,"details":{"email":"is taken"}}`,
+		},
+		{
+			name:   "decode error",
+			method: http.MethodGet,
+			path:   "/v1/fail",
+			err:    &web.DecodeError{Err: errors.New("content type must be application/json"), Status: http.StatusUnsupportedMediaType},
+			status: http.StatusUnsupportedMediaType,
+			body:   `{"error":"content type must be application/json","status":415}`,
+		},
+		{
+			name:   "request error",
+			method: http.MethodGet,
+			path:   "/v1/fail",
+			err:    v1.NewRequestError(errors.New("book not found"), http.StatusNotFound),
+			status: http.StatusNotFound,
+			body:   `{"error":"book not found","status":404}`,
+		},
+		{
+			name:   "not acceptable",
+			method: http.MethodGet,
+			path:   "/v1/fail",
+			err:    &web.NotAcceptableError{Offers: []string{"application/json", "text/csv"}},
+			status: http.StatusNotAcceptable,
+			body:   `{"error":"none of the accepted media types can be produced, available: application/json, text/csv","status":406}`,
+		},
+		{
+			name:   "internal error",
+			method: http.MethodGet,
+			path:   "/v1/fail",
+			err:    errors.New("connection refused"),
+			status: http.StatusInternalServerError,
+			body:   `{"error":"Internal Server Error","status":500}`,
+		},
+		{
+			name:   "route not found",
+			method: http.MethodGet,
+			path:   "/v1/missing",
+			status: http.StatusNotFound,
+			body:   `{"error":"path /v1/missing not found","status":404}`,
+		},
+		{
+			name:   "method not allowed",
+			method: http.MethodDelete,
+			path:   "/v1/fail",
+			status: http.StatusMethodNotAllowed,
+			body:   `{"error":"method DELETE not allowed","status":405}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := zap.NewNop().Sugar()
+			app := web.NewApp(make(chan os.Signal, 1), log, mid.Errors(log))
+			app.Handle(http.MethodGet, "v1", "/fail", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				return tt.err
+			})
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected content type application/json, got %q", ct)
+			}
+			if got := w.Body.String(); got != tt.body {
+				t.Errorf("expected body\n%s\ngot\n%s", tt.body, got)
+			}
+		})
+	}
+}
@@ -21,6 +21,10 @@ func (re *RequestError) Error() string {
 	return re.Err.Error()
 }
 
+func (re *RequestError) Unwrap() error {
+	return re.Err
+}
+
 func IsRequestError(err error) bool {
 	var re *RequestError
 	return errors.As(err, &re)
@@ -54,6 +58,10 @@ func (fe FieldError) Error() string {
 	return fmt.Sprintf("%s %s", fe.Field, fe.Message)
 }
 
+func (fe FieldError) Details() map[string]string {
+	return map[string]string{fe.Field: fe.Message}
+}
+
 func IsFieldError(err error) bool {
 	var fe FieldError
 	return errors.As(err, &fe)
@@ -67,6 +75,25 @@ func GetFieldError(err error) FieldError {
 	return fe
 }
 
+// Details
+
+// DetailedError is implemented by errors describing problems with particular
+// fields of the request, like web.FieldErrors and field errors of core packages.
+type DetailedError interface {
+	error
+	Details() map[string]string
+}
+
+// GetDetails returns the problems with fields keyed by the JSON field name, or nil
+// when err doesn't describe any.
+func GetDetails(err error) map[string]string {
+	var de DetailedError
+	if !errors.As(err, &de) {
+		return nil
+	}
+	return de.Details()
+}
+
 // Response
 
 type ErrorResponse struct {
@@ -0,0 +1,73 @@
+// Package mid holds middleware specific to the v2 API. Middleware shared with v1,
+// like Panics and Authenticate, stays in the v1 mid package.
+package mid
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+	v2 "github.com/tchorzewski1991/bds/business/web/v2"
+	"go.uber.org/zap"
+)
+
+// Problems answers errors of the v2 routes with problem details described by RFC 7807.
+// It recognises the same errors as the v1 Errors middleware, which keeps answering v1
+// routes and requests not matching any route. Shutdown errors are left to it as well.
+func Problems(logger *zap.SugaredLogger) web.Middleware {
+
+	// m is the middleware function to be executed.
+	m := func(handler web.Handler) web.Handler {
+
+		// h is the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+			err := handler(ctx, w, r)
+			if err == nil || web.IsShutdownError(err) {
+				return err
+			}
+
+			traceID := web.GetTraceID(ctx)
+			logger.Errorw(err.Error(), "trace_id", traceID)
+
+			var p v2.Problem
+
+			switch {
+			case web.IsFieldErrors(err), v1.IsFieldError(err):
+				p = v2.NewProblem(v2.ValidationError, "", traceID)
+			case web.IsDecodeError(err):
+				dErr := web.GetDecodeError(err)
+				p = v2.NewProblem(v2.TypeForStatus(dErr.Status), dErr.Error(), traceID)
+			case web.IsRouteError(err):
+				rErr := web.GetRouteError(err)
+				p = v2.NewProblem(v2.TypeForStatus(rErr.Status), rErr.Error(), traceID)
+			case web.IsNotAcceptableError(err):
+				p = v2.NewProblem(v2.NotAcceptable, err.Error(), traceID)
+			case v1.IsRequestError(err):
+				rErr := v1.GetRequestError(err)
+				p = v2.NewProblem(v2.TypeForStatus(rErr.Status), rErr.Error(), traceID)
+			default:
+				// The cause stays in the logs, the client gets the instance to refer to it.
+				p = v2.NewProblem(v2.InternalError, "", traceID)
+			}
+
+			if p.Status != http.StatusInternalServerError {
+				if details := v1.GetDetails(err); len(details) > 0 {
+					p.InvalidParams = v2.NewInvalidParams(details)
+				}
+			}
+
+			body, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+
+			return web.RawResponse(ctx, w, p.Status, v2.ContentType, body)
+		}
+
+		return h
+	}
+
+	return m
+}
@@ -0,0 +1,271 @@
+package mid_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/tchorzewski1991/bds/base/web"
+	v1 "github.com/tchorzewski1991/bds/business/web/v1"
+	v1mid "github.com/tchorzewski1991/bds/business/web/v1/mid"
+	v2 "github.com/tchorzewski1991/bds/business/web/v2"
+	"github.com/tchorzewski1991/bds/business/web/v2/mid"
+	"go.uber.org/zap"
+)
+
+func TestProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want v2.Problem
+	}{
+		{
+			name: "field errors",
+			err:  web.FieldErrors{"title": "title is required", "isbn": "isbn must be 13 characters long"},
+			want: v2.Problem{
+				Type:   "/v2/problems/validation-error",
+				Title:  "Request is not valid",
+				Status: http.StatusUnprocessableEntity,
+				InvalidParams: []v2.InvalidParam{
+					{Name: "isbn", Reason: "isbn must be 13 characters long"},
+					{Name: "title", Reason: "title is required"},
+				},
+			},
+		},
+		{
+			name: "field error",
+			err:  v1.NewFieldError("email", "is taken"),
+			want: v2.Problem{
+				Type:   "/v2/problems/validation-error",
+				Title:  "Request is not valid",
+				Status: http.StatusUnprocessableEntity,
+				InvalidParams: []v2.InvalidParam{
+					{Name: "email", Reason: "is taken"},
+				},
+			},
+		},
+		{
+			name: "decode error",
+			err:  &web.DecodeError{Err: errors.New("request body too large"), Status: http.StatusRequestEntityTooLarge},
+			want: v2.Problem{
+				Type:   "/v2/problems/payload-too-large",
+				Title:  "Request body too large",
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: "request body too large",
+			},
+		},
+		{
+			name: "route not found",
+			err:  &web.RouteError{Err: errors.New("path /v2/missing not found"), Status: http.StatusNotFound},
+			want: v2.Problem{
+				Type:   "/v2/problems/not-found",
+				Title:  "Resource not found",
+				Status: http.StatusNotFound,
+				Detail: "path /v2/missing not found",
+			},
+		},
+		{
+			name: "method not allowed",
+			err:  &web.RouteError{Err: errors.New("method DELETE not allowed"), Status: http.StatusMethodNotAllowed},
+			want: v2.Problem{
+				Type:   "/v2/problems/method-not-allowed",
+				Title:  "Method not allowed",
+				Status: http.StatusMethodNotAllowed,
+				Detail: "method DELETE not allowed",
+			},
+		},
+		{
+			name: "not acceptable",
+			err:  &web.NotAcceptableError{Offers: []string{"application/json"}},
+			want: v2.Problem{
+				Type:   "/v2/problems/not-acceptable",
+				Title:  "Response format not acceptable",
+				Status: http.StatusNotAcceptable,
+				Detail: "none of the accepted media types can be produced, available: application/json",
+			},
+		},
+		{
+			name: "request error",
+			err:  v1.NewRequestError(errors.New("book already exists"), http.StatusConflict),
+			want: v2.Problem{
+				Type:   "/v2/problems/conflict",
+				Title:  "Resource conflict",
+				Status: http.StatusConflict,
+				Detail: "book already exists",
+			},
+		},
+		{
+			name: "request error outside catalogue",
+			err:  v1.NewRequestError(errors.New("book is gone"), http.StatusGone),
+			want: v2.Problem{
+				Type:   "about:blank",
+				Title:  "Gone",
+				Status: http.StatusGone,
+				Detail: "book is gone",
+			},
+		},
+		{
+			name: "internal error",
+			err:  errors.New("connection refused"),
+			want: v2.Problem{
+				Type:   "/v2/problems/internal-error",
+				Title:  "Internal server error",
+				Status: http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var traceID string
+
+			log := zap.NewNop().Sugar()
+			app := web.NewApp(make(chan os.Signal, 1), log)
+			app.Group("/v2", mid.Problems(log)).Handle(http.MethodGet, "/fail", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+				traceID = web.GetTraceID(ctx)
+				return tt.err
+			})
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/fail", nil))
+
+			if w.Code != tt.want.Status {
+				t.Errorf("expected status %d, got %d", tt.want.Status, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != v2.ContentType {
+				t.Errorf("expected content type %s, got %q", v2.ContentType, ct)
+			}
+
+			var got v2.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid problem: %v", err)
+			}
+
+			tt.want.Instance = "urn:uuid:" + traceID
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected problem %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProblemsPassesSuccess(t *testing.T) {
+	log := zap.NewNop().Sugar()
+	app := web.NewApp(make(chan os.Signal, 1), log)
+	app.Group("/v2", mid.Problems(log)).Handle(http.MethodGet, "/ok", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	w := httptest.NewRecorder()
+	app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/ok", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got, want := w.Body.String(), `{"status":"ok"}`; got != want {
+		t.Errorf("expected body %s, got %s", want, got)
+	}
+}
+
+// TestProblemsUnmatched checks that unknown v2 paths and methods get problem details,
+// while the rest of the app keeps the errors of the app level middleware.
+func TestProblemsUnmatched(t *testing.T) {
+	log := zap.NewNop().Sugar()
+	app := web.NewApp(make(chan os.Signal, 1), log, v1mid.Errors(log))
+
+	g := app.Group("/v2", mid.Problems(log))
+	g.HandleUnmatched()
+	g.Handle(http.MethodGet, "/books", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, []string{})
+	})
+	app.Handle(http.MethodGet, "v1", "/books", func(ctx context.Context, w http.ResponseWriter, r *http.Request) error {
+		return web.Response(ctx, w, http.StatusOK, []string{})
+	})
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		contentType string
+		problem     string
+		allow       string
+	}{
+		{
+			name:        "unknown v2 path",
+			method:      http.MethodGet,
+			path:        "/v2/missing",
+			status:      http.StatusNotFound,
+			contentType: v2.ContentType,
+			problem:     "/v2/problems/not-found",
+		},
+		{
+			name:        "unknown v2 method",
+			method:      http.MethodDelete,
+			path:        "/v2/books",
+			status:      http.StatusMethodNotAllowed,
+			contentType: v2.ContentType,
+			problem:     "/v2/problems/method-not-allowed",
+			allow:       "GET, HEAD, OPTIONS",
+		},
+		{
+			name:        "unknown v1 path",
+			method:      http.MethodGet,
+			path:        "/v1/missing",
+			status:      http.StatusNotFound,
+			contentType: "application/json",
+		},
+		{
+			name:        "path only sharing the prefix",
+			method:      http.MethodGet,
+			path:        "/v2books",
+			status:      http.StatusNotFound,
+			contentType: "application/json",
+		},
+		{
+			name:        "unknown v1 method",
+			method:      http.MethodDelete,
+			path:        "/v1/books",
+			status:      http.StatusMethodNotAllowed,
+			contentType: "application/json",
+			allow:       "GET, HEAD, OPTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("expected content type %s, got %q", tt.contentType, ct)
+			}
+			if allow := w.Header().Get("Allow"); allow != tt.allow {
+				t.Errorf("expected Allow %q, got %q", tt.allow, allow)
+			}
+
+			if tt.problem == "" {
+				return
+			}
+
+			var got v2.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid problem: %v", err)
+			}
+			if got.Type != tt.problem {
+				t.Errorf("expected type %s, got %s", tt.problem, got.Type)
+			}
+			if got.Status != tt.status {
+				t.Errorf("expected status %d in the problem, got %d", tt.status, got.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package v2 describes errors of the v2 API as problem details defined by RFC 7807.
+// Every problem has the type from the Catalogue, so clients can act on the type instead
+// of parsing messages, and the instance holding the trace ID of the request, so the
+// problem can be correlated with the logs of the service.
+package v2
+
+import (
+	"net/http"
+	"sort"
+)
+
+// ContentType is the media type of problem details sent as JSON.
+const ContentType = "application/problem+json"
+
+// TypePrefix is the path under which the catalogue is served. Types of problems are
+// relative URIs resolving to their catalogue entries, e.g. /v2/problems/not-found.
+const TypePrefix = "/v2/problems/"
+
+// ProblemType is the entry of the Catalogue.
+type ProblemType struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+// Problem types of the v2 API. Titles are the same for every occurrence of the type,
+// details of the particular occurrence go to the detail member of Problem.
+var (
+	ValidationError = newType("validation-error", "Request is not valid", http.StatusUnprocessableEntity,
+		"Some of the request fields or params don't meet their constraints. Each of them is listed in invalid_params with the reason.")
+	MalformedRequest = newType("malformed-request", "Request is malformed", http.StatusBadRequest,
+		"The request can't be read, e.g. the body is not valid JSON or the param has the wrong type.")
+	Unauthorized = newType("unauthorized", "Authentication is required", http.StatusUnauthorized,
+		"The request lacks valid credentials: the access token or the API key.")
+	Forbidden = newType("forbidden", "Access is denied", http.StatusForbidden,
+		"The credentials are valid, but they don't grant access to the resource.")
+	NotFound = newType("not-found", "Resource not found", http.StatusNotFound,
+		"The resource, or the route, doesn't exist.")
+	MethodNotAllowed = newType("method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed,
+		"The route doesn't support the method. The Allow header lists the ones it supports.")
+	NotAcceptable = newType("not-acceptable", "Response format not acceptable", http.StatusNotAcceptable,
+		"None of the media types accepted by the client can be produced.")
+	Conflict = newType("conflict", "Resource conflict", http.StatusConflict,
+		"The request conflicts with the current state of the resource, e.g. it already exists.")
+	PayloadTooLarge = newType("payload-too-large", "Request body too large", http.StatusRequestEntityTooLarge,
+		"The request body exceeds the limit of the route.")
+	UnsupportedMediaType = newType("unsupported-media-type", "Unsupported media type", http.StatusUnsupportedMediaType,
+		"The route doesn't accept the body in the sent format.")
+	TooManyRequests = newType("too-many-requests", "Too many requests", http.StatusTooManyRequests,
+		"The client has sent too many requests. The Retry-After header tells when to try again.")
+	InternalError = newType("internal-error", "Internal server error", http.StatusInternalServerError,
+		"The service failed to handle the request. Quote the instance when reporting the problem.")
+)
+
+// Catalogue lists all the problem types, sorted by type.
+func Catalogue() []ProblemType {
+	list := make([]ProblemType, 0, len(catalogue))
+	for _, pt := range catalogue {
+		list = append(list, pt)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Type < list[j].Type
+	})
+	return list
+}
+
+// LookupType returns the problem type of the catalogue entry, e.g. not-found.
+func LookupType(name string) (ProblemType, bool) {
+	pt, ok := catalogue[name]
+	return pt, ok
+}
+
+// TypeForStatus returns the problem type matching the HTTP status, statuses are unique
+// within the catalogue. Statuses outside the catalogue get about:blank, which means
+// the status says it all.
+func TypeForStatus(status int) ProblemType {
+	for _, pt := range catalogue {
+		if pt.Status == status {
+			return pt
+		}
+	}
+
+	return ProblemType{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+	}
+}
+
+// Problem is the body of error responses of the v2 API.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// InvalidParams is the extension member of validation errors.
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// InvalidParam describes the field or param which failed validation.
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// NewProblem builds the problem of the type for the request with the trace ID.
+func NewProblem(pt ProblemType, detail string, traceID string) Problem {
+	return Problem{
+		Type:     pt.Type,
+		Title:    pt.Title,
+		Status:   pt.Status,
+		Detail:   detail,
+		Instance: "urn:uuid:" + traceID,
+	}
+}
+
+// NewInvalidParams turns problems with fields, e.g. the result of v1.GetDetails,
+// into invalid params sorted by name.
+func NewInvalidParams(details map[string]string) []InvalidParam {
+	params := make([]InvalidParam, 0, len(details))
+	for name, reason := range details {
+		params = append(params, InvalidParam{Name: name, Reason: reason})
+	}
+	sort.Slice(params, func(i, j int) bool {
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+// private
+
+var catalogue = make(map[string]ProblemType)
+
+func newType(name string, title string, status int, description string) ProblemType {
+	pt := ProblemType{
+		Type:        TypePrefix + name,
+		Title:       title,
+		Status:      status,
+		Description: description,
+	}
+	catalogue[name] = pt
+	return pt
+}